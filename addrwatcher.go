@@ -0,0 +1,756 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"sync"
+
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/txscript"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// WatchedUTXO describes an unspent output paying to an address tracked by an
+// AddressWatcher.
+type WatchedUTXO struct {
+	OutPoint wire.OutPoint
+	Address  btcutil.Address
+	Amount   btcutil.Amount
+
+	// BlockHash and BlockHeight describe the block the output is mined in.
+	// BlockHash is nil and BlockHeight is -1 while the output is
+	// unconfirmed.
+	BlockHash   *wire.ShaHash
+	BlockHeight int32
+}
+
+// Confirmed returns whether or not the output is mined in a block.
+func (u *WatchedUTXO) Confirmed() bool {
+	return u.BlockHash != nil
+}
+
+// AddressBalance houses the balance of an address tracked by an AddressWatcher.
+type AddressBalance struct {
+	Address     btcutil.Address
+	Confirmed   btcutil.Amount
+	Unconfirmed btcutil.Amount
+}
+
+// addrWatcherPruneDepth is the number of blocks after which the spends of
+// tracked outputs are forgotten by an AddressWatcher, either because they are
+// deep enough in the chain to no longer be disconnected, or because the
+// spending transaction never made it into a block.
+const addrWatcherPruneDepth = 100
+
+// watchedSpend houses a tracked output which has been spent, so it can be
+// restored if the spend does not make it into the main chain.
+type watchedSpend struct {
+	utxo    *WatchedUTXO
+	owner   string // encoded address
+	spender wire.ShaHash
+
+	// height is the height of the block the spend is mined in, or -1
+	// while the spend is in the memory pool.  seenHeight is the best
+	// height when the spend was last seen in the memory pool.
+	height     int32
+	seenHeight int32
+}
+
+// watchedAddress houses the unspent outputs of an address tracked by an
+// AddressWatcher.
+type watchedAddress struct {
+	address btcutil.Address
+	utxos   map[wire.OutPoint]*WatchedUTXO
+}
+
+// AddressWatcher tracks the balances and unspent outputs of a set of addresses
+// without the need for a wallet.  The addresses and the outputs paying to them
+// are registered for notifications by the client, so the registrations are
+// re-established on reconnect, and the balances are kept up to date with the
+// recvtx, redeemingtx, and block (dis)connected notifications.  The history of
+// newly added addresses is rebuilt with Rescan.  The registration of an output
+// is released once its spend is pruned, and all registrations made by the
+// watcher are released when it is stopped, except for those the caller made
+// itself with NotifyReceived and NotifySpent.
+//
+// An output is unconfirmed until a recvtx notification with block details is
+// received for its transaction.  Outputs spent by a transaction in the memory
+// pool are removed from the balances immediately, but are kept as pending
+// spends until the spend is mined.  They are restored if the spending
+// transaction is evicted from the memory pool or disconnected from the main
+// chain without returning to the memory pool.
+//
+// When the watcher falls so far behind the notifications that some of them are
+// dropped, it resyncs by rescanning the blocks connected since the last block
+// it processed.
+type AddressWatcher struct {
+	client *Client
+	queue  *ntfnQueue
+	quit   chan struct{}
+	wg     sync.WaitGroup
+
+	quitOnce sync.Once
+
+	mtx    sync.RWMutex
+	addrs  map[string]*watchedAddress
+	owners map[wire.OutPoint]string // outpoint to encoded address
+
+	// spent holds the tracked outputs which have been spent so they are
+	// not tracked again when the transaction creating them is notified
+	// again once it is mined, and so they can be restored if the spend is
+	// evicted or disconnected.  Spends are pruned once they are
+	// addrWatcherPruneDepth blocks deep, or have been pending for as many
+	// blocks.
+	spent      map[wire.OutPoint]*watchedSpend
+	bestHeight int32
+
+	// pruned holds the outpoints of the spends pruned since their
+	// registrations were last released.
+	pruned []wire.OutPoint
+
+	// blocks holds the hashes of the blocks connected during the last
+	// addrWatcherPruneDepth blocks so a resync can find the blocks which
+	// were disconnected while notifications were dropped.
+	blocks map[int32]wire.ShaHash
+}
+
+// Enforce AddressWatcher satisfies the ntfnObserver interface.
+var _ ntfnObserver = (*AddressWatcher)(nil)
+
+// NewAddressWatcher returns a new watcher which tracks the passed addresses.
+// The history of the addresses is rebuilt by rescanning the block chain from
+// the passed start block, or from the genesis block when it is nil, before the
+// watcher is returned.  The client must have been created with notification
+// handlers, although none of the handlers need to be set.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) NewAddressWatcher(addresses []btcutil.Address,
+	startBlock *wire.ShaHash) (*AddressWatcher, error) {
+
+	if c.ntfnHandlers == nil {
+		return nil, ErrNoNotificationHandlers
+	}
+
+	w := &AddressWatcher{
+		client: c,
+		queue:  newNtfnQueue(),
+		quit:   make(chan struct{}),
+		addrs:  make(map[string]*watchedAddress),
+		owners: make(map[wire.OutPoint]string),
+		spent:  make(map[wire.OutPoint]*watchedSpend),
+		blocks: make(map[int32]wire.ShaHash),
+	}
+
+	c.ntfnObservers.add(w)
+	w.wg.Add(1)
+	go w.handler()
+
+	// Block notifications are needed to detect outputs becoming
+	// unconfirmed due to a reorganization.
+	if err := c.NotifyBlocks(); err != nil {
+		w.Stop()
+		return nil, err
+	}
+
+	// Start from the current best block so a resync does not need to
+	// rescan the whole chain when notifications are dropped before the
+	// first block is connected.
+	bestHash, bestHeight, err := c.GetBestBlock()
+	if err != nil {
+		w.Stop()
+		return nil, err
+	}
+	w.mtx.Lock()
+	if len(w.blocks) == 0 {
+		w.bestHeight = bestHeight
+		w.blocks[bestHeight] = *bestHash
+	}
+	w.mtx.Unlock()
+	if err := w.AddAddresses(addresses, startBlock); err != nil {
+		w.Stop()
+		return nil, err
+	}
+	return w, nil
+}
+
+// AddAddresses starts tracking the passed addresses.  Their history is rebuilt
+// by rescanning the block chain from the passed start block, or from the
+// genesis block when it is nil.  Addresses which are already tracked are
+// ignored.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (w *AddressWatcher) AddAddresses(addresses []btcutil.Address,
+	startBlock *wire.ShaHash) error {
+
+	var added []btcutil.Address
+	w.mtx.Lock()
+	for _, addr := range addresses {
+		encoded := addr.EncodeAddress()
+		if _, ok := w.addrs[encoded]; ok {
+			continue
+		}
+		w.addrs[encoded] = &watchedAddress{
+			address: addr,
+			utxos:   make(map[wire.OutPoint]*WatchedUTXO),
+		}
+		added = append(added, addr)
+	}
+	w.mtx.Unlock()
+	if len(added) == 0 {
+		return nil
+	}
+
+	c := w.client
+	encoded := make([]string, 0, len(added))
+	for _, addr := range added {
+		encoded = append(encoded, addr.EncodeAddress())
+	}
+	if err := c.retainReceived(encoded); err != nil {
+		return err
+	}
+	if startBlock == nil {
+		genesis, err := c.GetBlockHash(0)
+		if err != nil {
+			return err
+		}
+		startBlock = genesis
+	}
+	return c.Rescan(startBlock, added, nil)
+}
+
+// Stop stops tracking the addresses and releases the registrations made for
+// them and their outputs.  The watcher may still be queried afterwards.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (w *AddressWatcher) Stop() {
+	w.quitOnce.Do(func() {
+		w.client.ntfnObservers.remove(w)
+		close(w.quit)
+		w.wg.Wait()
+
+		w.mtx.RLock()
+		addrs := make([]string, 0, len(w.addrs))
+		for encoded := range w.addrs {
+			addrs = append(addrs, encoded)
+		}
+		outpoints := make([]btcjson.OutPoint, 0,
+			len(w.owners)+len(w.spent)+len(w.pruned))
+		for op := range w.owners {
+			outpoints = append(outpoints, newOutPointFromWire(&op))
+		}
+		for op := range w.spent {
+			outpoints = append(outpoints, newOutPointFromWire(&op))
+		}
+		w.mtx.RUnlock()
+		w.releasePruned()
+
+		if err := w.client.releaseSpent(outpoints); err != nil {
+			log.Warnf("Unable to release watched outputs: %v", err)
+		}
+		if err := w.client.releaseReceived(addrs); err != nil {
+			log.Warnf("Unable to release watched addresses: %v", err)
+		}
+	})
+	w.wg.Wait()
+}
+
+// observeNotification queues the notifications which affect the tracked
+// addresses for processing by the watcher goroutine.  It is part of the
+// ntfnObserver interface implementation.
+func (w *AddressWatcher) observeNotification(ntfn *rawNotification) {
+	switch ntfn.Method {
+	case btcjson.RecvTxNtfnMethod, btcjson.RedeemingTxNtfnMethod,
+		btcjson.BlockConnectedNtfnMethod,
+		btcjson.BlockDisconnectedNtfnMethod:
+
+		w.queue.push(ntfn)
+	}
+}
+
+// handler processes the queued notifications.  It must be run as a goroutine.
+func (w *AddressWatcher) handler() {
+out:
+	for {
+		select {
+		case <-w.queue.signal:
+			var newOutPoints []*wire.OutPoint
+			var checkSpends bool
+			ntfns, dropped := w.queue.popAll()
+			if dropped > 0 {
+				log.Warnf("Address watcher dropped %d "+
+					"notifications, resyncing", dropped)
+				w.resync()
+				checkSpends = true
+			}
+			for _, ntfn := range ntfns {
+				ops, check := w.handleNotification(ntfn)
+				newOutPoints = append(newOutPoints, ops...)
+				checkSpends = checkSpends || check
+			}
+
+			// Restore the outputs whose pending spends are no
+			// longer in the memory pool.
+			if checkSpends {
+				w.checkPendingSpends()
+			}
+
+			// Release the outputs whose spends were pruned, and
+			// register the new outputs so spends from them are
+			// still notified after a reconnect.
+			w.releasePruned()
+			if len(newOutPoints) == 0 {
+				continue
+			}
+			ops := make([]btcjson.OutPoint, 0, len(newOutPoints))
+			for _, op := range newOutPoints {
+				ops = append(ops, newOutPointFromWire(op))
+			}
+			if err := w.client.retainSpent(ops); err != nil {
+				log.Warnf("Unable to register watched outputs: %v",
+					err)
+			}
+
+		case <-w.quit:
+			break out
+
+		case <-w.client.shutdown:
+			break out
+		}
+	}
+	w.wg.Done()
+}
+
+// handleNotification updates the tracked addresses for the passed notification
+// and returns the outpoints of any newly discovered outputs, and whether or not
+// the pending spends need to be checked.
+func (w *AddressWatcher) handleNotification(ntfn *rawNotification) ([]*wire.OutPoint, bool) {
+	switch ntfn.Method {
+	case btcjson.RecvTxNtfnMethod, btcjson.RedeemingTxNtfnMethod:
+		tx, block, err := parseChainTxNtfnParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid %s notification: %v",
+				ntfn.Method, err)
+			return nil, false
+		}
+		var blockHash *wire.ShaHash
+		blockHeight := int32(-1)
+		if block != nil {
+			blockHash, err = wire.NewShaHashFromStr(block.Hash)
+			if err != nil {
+				log.Warnf("Received invalid %s notification: %v",
+					ntfn.Method, err)
+				return nil, false
+			}
+			blockHeight = block.Height
+		}
+		return w.processTx(tx, blockHash, blockHeight), false
+
+	case btcjson.BlockConnectedNtfnMethod:
+		hash, height, _, err := parseChainNtfnParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid block connected "+
+				"notification: %v", err)
+			return nil, false
+		}
+		return nil, w.connectBlock(hash, height)
+
+	case btcjson.BlockDisconnectedNtfnMethod:
+		_, height, _, err := parseChainNtfnParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid block disconnected "+
+				"notification: %v", err)
+			return nil, false
+		}
+		return nil, w.disconnectBlock(height)
+	}
+	return nil, false
+}
+
+// processTx removes the tracked outputs spent by the passed transaction and
+// adds or updates the outputs it pays to tracked addresses.  It returns the
+// outpoints of the outputs which were not tracked before.
+func (w *AddressWatcher) processTx(tx *btcutil.Tx, blockHash *wire.ShaHash,
+	blockHeight int32) []*wire.OutPoint {
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	for _, txIn := range tx.MsgTx().TxIn {
+		op := txIn.PreviousOutPoint
+
+		// Record the block of a spend which was pending, or the
+		// conflicting transaction which replaced it.
+		if spend, ok := w.spent[op]; ok {
+			if blockHash != nil || spend.height == -1 {
+				spend.spender = *tx.Sha()
+				spend.height = blockHeight
+				spend.seenHeight = w.bestHeight
+			}
+			continue
+		}
+
+		encoded, ok := w.owners[op]
+		if !ok {
+			continue
+		}
+		delete(w.owners, op)
+		spend := &watchedSpend{
+			owner:      encoded,
+			spender:    *tx.Sha(),
+			height:     blockHeight,
+			seenHeight: w.bestHeight,
+		}
+		if wa, ok := w.addrs[encoded]; ok {
+			spend.utxo = wa.utxos[op]
+			delete(wa.utxos, op)
+		}
+		w.spent[op] = spend
+	}
+
+	var newOutPoints []*wire.OutPoint
+	params := w.client.chainParams()
+	for i, txOut := range tx.MsgTx().TxOut {
+		// Outputs with non-standard scripts can not pay to a tracked
+		// address.
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(txOut.PkScript,
+			params)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			encoded := addr.EncodeAddress()
+			wa, ok := w.addrs[encoded]
+			if !ok {
+				continue
+			}
+
+			// Outputs which are already spent are not tracked
+			// again, although their confirmation is kept current
+			// in case they are restored.
+			op := wire.OutPoint{Hash: *tx.Sha(), Index: uint32(i)}
+			if spend, ok := w.spent[op]; ok {
+				if spend.utxo != nil && blockHash != nil {
+					spend.utxo.BlockHash = blockHash
+					spend.utxo.BlockHeight = blockHeight
+				}
+				break
+			}
+			utxo, ok := wa.utxos[op]
+			if !ok {
+				utxo = &WatchedUTXO{
+					OutPoint:    op,
+					Address:     wa.address,
+					Amount:      btcutil.Amount(txOut.Value),
+					BlockHeight: -1,
+				}
+				wa.utxos[op] = utxo
+				w.owners[op] = encoded
+				newOutPoints = append(newOutPoints,
+					wire.NewOutPoint(&op.Hash, op.Index))
+			}
+			if blockHash != nil {
+				utxo.BlockHash = blockHash
+				utxo.BlockHeight = blockHeight
+			}
+			break
+		}
+	}
+	return newOutPoints
+}
+
+// connectBlock records the passed block as the best block and prunes the
+// spends which are deep enough in the chain, or have been pending for too long.
+// The pruned outpoints are queued to have their registrations released.  It
+// returns whether or not there are pending spends to check.
+func (w *AddressWatcher) connectBlock(hash *wire.ShaHash, height int32) bool {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.bestHeight = height
+	w.blocks[height] = *hash
+	for blockHeight := range w.blocks {
+		if height-blockHeight >= addrWatcherPruneDepth {
+			delete(w.blocks, blockHeight)
+		}
+	}
+	pending := false
+	for op, spend := range w.spent {
+		switch {
+		case spend.height != -1 &&
+			height-spend.height >= addrWatcherPruneDepth:
+			delete(w.spent, op)
+			w.pruned = append(w.pruned, op)
+		case spend.height == -1 &&
+			height-spend.seenHeight >= addrWatcherPruneDepth:
+			delete(w.spent, op)
+			w.pruned = append(w.pruned, op)
+		case spend.height == -1:
+			pending = true
+		}
+	}
+	return pending
+}
+
+// disconnectBlock marks the outputs mined in the block at the passed height, or
+// above, as unconfirmed, and the spends mined in them as pending.  It returns
+// whether or not there are pending spends to check.
+func (w *AddressWatcher) disconnectBlock(height int32) bool {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	unconfirm := func(utxo *WatchedUTXO) {
+		if utxo.BlockHash != nil && utxo.BlockHeight >= height {
+			utxo.BlockHash = nil
+			utxo.BlockHeight = -1
+		}
+	}
+	for _, wa := range w.addrs {
+		for _, utxo := range wa.utxos {
+			unconfirm(utxo)
+		}
+	}
+
+	w.bestHeight = height - 1
+	for blockHeight := range w.blocks {
+		if blockHeight >= height {
+			delete(w.blocks, blockHeight)
+		}
+	}
+	pending := false
+	for _, spend := range w.spent {
+		if spend.utxo != nil {
+			unconfirm(spend.utxo)
+		}
+		if spend.height >= height {
+			spend.height = -1
+			spend.seenHeight = w.bestHeight
+		}
+		if spend.height == -1 {
+			pending = true
+		}
+	}
+	return pending
+}
+
+// resync corrects the tracked addresses after notifications were dropped.  The
+// outputs and spends mined in blocks which were disconnected in the meantime
+// are marked unconfirmed and pending, and the blocks connected since the last
+// processed block are rescanned for the tracked addresses and outputs, so the
+// missed transactions are notified again.  Missed transactions which are still
+// in the memory pool are only picked up once they are mined.
+//
+// This function issues blocking requests, so it must only be called from the
+// watcher goroutine.
+func (w *AddressWatcher) resync() {
+	c := w.client
+	bestHeight, err := c.GetBlockCount()
+	if err != nil {
+		log.Warnf("Unable to resync address watcher: %v", err)
+		return
+	}
+
+	// Find the lowest processed block which is no longer part of the main
+	// chain by walking back from the last processed block.
+	w.mtx.RLock()
+	processedHeight := w.bestHeight
+	blocks := make(map[int32]wire.ShaHash, len(w.blocks))
+	for blockHeight, hash := range w.blocks {
+		blocks[blockHeight] = hash
+	}
+	w.mtx.RUnlock()
+	forkHeight := processedHeight + 1
+	for height := processedHeight; height >= 0; height-- {
+		hash, ok := blocks[height]
+		if !ok {
+			break
+		}
+		if int64(height) <= bestHeight {
+			mainHash, err := c.GetBlockHash(int64(height))
+			if err != nil {
+				log.Warnf("Unable to resync address watcher: %v",
+					err)
+				return
+			}
+			if mainHash.IsEqual(&hash) {
+				break
+			}
+		}
+		forkHeight = height
+	}
+	if forkHeight <= processedHeight {
+		w.disconnectBlock(forkHeight)
+	}
+	if int64(forkHeight) > bestHeight {
+		return
+	}
+
+	w.mtx.RLock()
+	addrs := make([]btcutil.Address, 0, len(w.addrs))
+	for _, wa := range w.addrs {
+		addrs = append(addrs, wa.address)
+	}
+	outpoints := make([]*wire.OutPoint, 0, len(w.owners)+len(w.spent))
+	for op := range w.owners {
+		outpoints = append(outpoints, wire.NewOutPoint(&op.Hash, op.Index))
+	}
+	for op, spend := range w.spent {
+		if spend.height == -1 {
+			outpoints = append(outpoints, wire.NewOutPoint(&op.Hash,
+				op.Index))
+		}
+	}
+	w.mtx.RUnlock()
+
+	startHash, err := c.GetBlockHash(int64(forkHeight))
+	if err != nil {
+		log.Warnf("Unable to resync address watcher: %v", err)
+		return
+	}
+	bestHash, err := c.GetBlockHash(bestHeight)
+	if err != nil {
+		log.Warnf("Unable to resync address watcher: %v", err)
+		return
+	}
+	if err := c.Rescan(startHash, addrs, outpoints); err != nil {
+		log.Warnf("Unable to resync address watcher: %v", err)
+		return
+	}
+	w.connectBlock(bestHash, int32(bestHeight))
+}
+
+// checkPendingSpends restores the tracked outputs whose pending spends are no
+// longer in the memory pool, such as when the spending transaction was evicted
+// or was disconnected from the main chain without returning to the memory
+// pool.  An output is restored when the server reports it as unspent.
+//
+// This function issues blocking requests, so it must only be called from the
+// watcher goroutine.
+func (w *AddressWatcher) checkPendingSpends() {
+	w.mtx.RLock()
+	var pending []wire.OutPoint
+	for op, spend := range w.spent {
+		if spend.height == -1 && spend.utxo != nil {
+			pending = append(pending, op)
+		}
+	}
+	w.mtx.RUnlock()
+
+	for i := range pending {
+		op := &pending[i]
+		txOut, err := w.client.GetTxOut(&op.Hash, op.Index, true)
+		if err != nil {
+			log.Warnf("Unable to check pending spend of %v: %v", op,
+				err)
+			continue
+		}
+		if txOut == nil {
+			continue
+		}
+
+		w.mtx.Lock()
+		if spend, ok := w.spent[*op]; ok && spend.height == -1 {
+			w.restoreSpend(op, spend)
+		}
+		w.mtx.Unlock()
+	}
+}
+
+// releasePruned releases the registrations of the outpoints whose spends were
+// pruned.
+//
+// This function issues blocking requests, so it must only be called from the
+// watcher goroutine, or once it has stopped.
+func (w *AddressWatcher) releasePruned() {
+	w.mtx.Lock()
+	pruned := w.pruned
+	w.pruned = nil
+	w.mtx.Unlock()
+	if len(pruned) == 0 {
+		return
+	}
+
+	ops := make([]btcjson.OutPoint, 0, len(pruned))
+	for i := range pruned {
+		ops = append(ops, newOutPointFromWire(&pruned[i]))
+	}
+	if err := w.client.releaseSpent(ops); err != nil {
+		log.Warnf("Unable to release spent outputs: %v", err)
+	}
+}
+
+// restoreSpend tracks the output spent by the passed spend as unspent again.
+// It must be called with the mutex held.
+func (w *AddressWatcher) restoreSpend(op *wire.OutPoint, spend *watchedSpend) {
+	delete(w.spent, *op)
+	if wa, ok := w.addrs[spend.owner]; ok && spend.utxo != nil {
+		log.Debugf("Restoring output %v whose spend %v is no longer "+
+			"pending", op, spend.spender)
+		wa.utxos[*op] = spend.utxo
+		w.owners[*op] = spend.owner
+	}
+}
+
+// Balance returns the balance of the passed address.  The returned balance is
+// zero for addresses which are not tracked.
+//
+// This function is safe for concurrent access.
+func (w *AddressWatcher) Balance(addr btcutil.Address) AddressBalance {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+
+	balance := AddressBalance{Address: addr}
+	wa, ok := w.addrs[addr.EncodeAddress()]
+	if !ok {
+		return balance
+	}
+	for _, utxo := range wa.utxos {
+		if utxo.Confirmed() {
+			balance.Confirmed += utxo.Amount
+		} else {
+			balance.Unconfirmed += utxo.Amount
+		}
+	}
+	return balance
+}
+
+// Balances returns the balances of all tracked addresses.
+//
+// This function is safe for concurrent access.
+func (w *AddressWatcher) Balances() []AddressBalance {
+	w.mtx.RLock()
+	addrs := make([]btcutil.Address, 0, len(w.addrs))
+	for _, wa := range w.addrs {
+		addrs = append(addrs, wa.address)
+	}
+	w.mtx.RUnlock()
+
+	balances := make([]AddressBalance, 0, len(addrs))
+	for _, addr := range addrs {
+		balances = append(balances, w.Balance(addr))
+	}
+	return balances
+}
+
+// UTXOs returns the unspent outputs paying to the passed address.
+//
+// This function is safe for concurrent access.
+func (w *AddressWatcher) UTXOs(addr btcutil.Address) []WatchedUTXO {
+	w.mtx.RLock()
+	defer w.mtx.RUnlock()
+
+	wa, ok := w.addrs[addr.EncodeAddress()]
+	if !ok {
+		return nil
+	}
+	utxos := make([]WatchedUTXO, 0, len(wa.utxos))
+	for _, utxo := range wa.utxos {
+		utxos = append(utxos, *utxo)
+	}
+	return utxos
+}
@@ -0,0 +1,380 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/chaincfg"
+	"github.com/ppcsuite/ppcd/txscript"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// newTestWatcherAddress returns a main network pay-to-pubkey-hash address with
+// a hash made of the passed byte.
+func newTestWatcherAddress(t *testing.T, b byte) btcutil.Address {
+	addr, err := btcutil.NewAddressPubKeyHash(bytes.Repeat([]byte{b}, 20),
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: %v", err)
+	}
+	return addr
+}
+
+// testWatcherOutput describes an output of a transaction created by
+// newTestWatcherTx.
+type testWatcherOutput struct {
+	addr  btcutil.Address
+	value int64
+}
+
+// newTestWatcherTx returns a transaction spending the passed outpoints with an
+// output for each of the passed outputs.
+func newTestWatcherTx(t *testing.T, prevOuts []wire.OutPoint,
+	outputs ...testWatcherOutput) *btcutil.Tx {
+
+	msgTx := &wire.MsgTx{Version: 1}
+	for _, op := range prevOuts {
+		msgTx.TxIn = append(msgTx.TxIn, &wire.TxIn{
+			PreviousOutPoint: op,
+			Sequence:         wire.MaxTxInSequenceNum,
+		})
+	}
+	for _, output := range outputs {
+		pkScript, err := txscript.PayToAddrScript(output.addr)
+		if err != nil {
+			t.Fatalf("PayToAddrScript: %v", err)
+		}
+		msgTx.TxOut = append(msgTx.TxOut, &wire.TxOut{
+			Value:    output.value,
+			PkScript: pkScript,
+		})
+	}
+	return btcutil.NewTx(msgTx)
+}
+
+// newTestAddressWatcher returns a watcher tracking the passed addresses which
+// is backed by a client without a connection.  The watcher goroutine is not
+// started.
+func newTestAddressWatcher(addrs ...btcutil.Address) *AddressWatcher {
+	client := &Client{
+		config:        &ConnConfig{},
+		ntfnState:     newNotificationState(),
+		ntfnObservers: newNtfnObservers(),
+		shutdown:      make(chan struct{}),
+	}
+	w := &AddressWatcher{
+		client: client,
+		queue:  newNtfnQueue(),
+		quit:   make(chan struct{}),
+		addrs:  make(map[string]*watchedAddress),
+		owners: make(map[wire.OutPoint]string),
+		spent:  make(map[wire.OutPoint]*watchedSpend),
+		blocks: make(map[int32]wire.ShaHash),
+	}
+	for _, addr := range addrs {
+		w.addrs[addr.EncodeAddress()] = &watchedAddress{
+			address: addr,
+			utxos:   make(map[wire.OutPoint]*WatchedUTXO),
+		}
+	}
+	return w
+}
+
+// checkWatcherBalance ensures the balance of the passed address matches the
+// expected confirmed and unconfirmed amounts.
+func checkWatcherBalance(t *testing.T, step string, w *AddressWatcher,
+	addr btcutil.Address, confirmed, unconfirmed btcutil.Amount) {
+
+	balance := w.Balance(addr)
+	if balance.Confirmed != confirmed || balance.Unconfirmed != unconfirmed {
+		t.Errorf("%s: balance of %v: got %v confirmed and %v "+
+			"unconfirmed, want %v and %v", step, addr,
+			balance.Confirmed, balance.Unconfirmed, confirmed,
+			unconfirmed)
+	}
+}
+
+// TestAddressWatcherBalance ensures the balances of the tracked addresses
+// follow the outputs paying to them as they are received, confirmed, and
+// spent.
+func TestAddressWatcherBalance(t *testing.T) {
+	t.Parallel()
+
+	addrA := newTestWatcherAddress(t, 1)
+	addrB := newTestWatcherAddress(t, 2)
+	other := newTestWatcherAddress(t, 3)
+	w := newTestAddressWatcher(addrA, addrB)
+
+	funding := newTestWatcherTx(t, []wire.OutPoint{{Index: 0}},
+		testWatcherOutput{addrA, 100}, testWatcherOutput{other, 50},
+		testWatcherOutput{addrB, 30})
+	opA := wire.OutPoint{Hash: *funding.Sha(), Index: 0}
+	opB := wire.OutPoint{Hash: *funding.Sha(), Index: 2}
+
+	// The outputs paying to tracked addresses are new and unconfirmed
+	// while the transaction is in the memory pool.
+	newOutPoints := w.processTx(funding, nil, -1)
+	if len(newOutPoints) != 2 || *newOutPoints[0] != opA ||
+		*newOutPoints[1] != opB {
+		t.Fatalf("mempool funding: got new outpoints %v, want %v and %v",
+			newOutPoints, opA, opB)
+	}
+	checkWatcherBalance(t, "mempool funding", w, addrA, 0, 100)
+	checkWatcherBalance(t, "mempool funding", w, addrB, 0, 30)
+	checkWatcherBalance(t, "mempool funding", w, other, 0, 0)
+
+	// The outputs are confirmed, but not new, once the transaction is
+	// mined.
+	blockHash := wire.ShaHash{0x10}
+	newOutPoints = w.processTx(funding, &blockHash, 10)
+	if len(newOutPoints) != 0 {
+		t.Fatalf("mined funding: got new outpoints %v, want none",
+			newOutPoints)
+	}
+	checkWatcherBalance(t, "mined funding", w, addrA, 100, 0)
+	checkWatcherBalance(t, "mined funding", w, addrB, 30, 0)
+	utxos := w.UTXOs(addrA)
+	if len(utxos) != 1 || utxos[0].OutPoint != opA ||
+		utxos[0].BlockHeight != 10 || !utxos[0].Confirmed() {
+		t.Fatalf("mined funding: got UTXOs %v, want %v at height 10",
+			utxos, opA)
+	}
+
+	// Spending an output removes it from the balance immediately, and the
+	// change paying to a tracked address is unconfirmed.
+	spend := newTestWatcherTx(t, []wire.OutPoint{opA},
+		testWatcherOutput{addrB, 40}, testWatcherOutput{other, 50})
+	newOutPoints = w.processTx(spend, nil, -1)
+	change := wire.OutPoint{Hash: *spend.Sha(), Index: 0}
+	if len(newOutPoints) != 1 || *newOutPoints[0] != change {
+		t.Fatalf("mempool spend: got new outpoints %v, want %v",
+			newOutPoints, change)
+	}
+	checkWatcherBalance(t, "mempool spend", w, addrA, 0, 0)
+	checkWatcherBalance(t, "mempool spend", w, addrB, 30, 40)
+	if s, ok := w.spent[opA]; !ok || s.height != -1 ||
+		s.spender != *spend.Sha() {
+		t.Fatalf("mempool spend: got spend %+v, want pending spend "+
+			"by %v", s, spend.Sha())
+	}
+
+	// Notifying the funding transaction again, such as by a rescan, does
+	// not track the spent output again.
+	if newOutPoints := w.processTx(funding, &blockHash, 10); len(newOutPoints) != 0 {
+		t.Fatalf("funding notified again: got new outpoints %v, want "+
+			"none", newOutPoints)
+	}
+	checkWatcherBalance(t, "funding notified again", w, addrA, 0, 0)
+
+	balances := w.Balances()
+	if len(balances) != 2 {
+		t.Fatalf("got %d balances, want 2", len(balances))
+	}
+}
+
+// TestAddressWatcherPendingSpends ensures spends become pending when their
+// block is disconnected, are replaced by conflicting transactions, are
+// restored, and are pruned once they are deep enough or have been pending for
+// too long.
+func TestAddressWatcherPendingSpends(t *testing.T) {
+	t.Parallel()
+
+	addr := newTestWatcherAddress(t, 1)
+	other := newTestWatcherAddress(t, 2)
+	w := newTestAddressWatcher(addr)
+
+	funding := newTestWatcherTx(t, []wire.OutPoint{{Index: 0}},
+		testWatcherOutput{addr, 100}, testWatcherOutput{addr, 20})
+	op := wire.OutPoint{Hash: *funding.Sha(), Index: 0}
+	op2 := wire.OutPoint{Hash: *funding.Sha(), Index: 1}
+	w.processTx(funding, &wire.ShaHash{0x10}, 10)
+	w.connectBlock(&wire.ShaHash{0x10}, 10)
+
+	// A spend mined in a block becomes pending when the block is
+	// disconnected, while the spent output stays confirmed in its older
+	// block.
+	spend := newTestWatcherTx(t, []wire.OutPoint{op},
+		testWatcherOutput{other, 90})
+	w.processTx(spend, &wire.ShaHash{0x11}, 11)
+	if pending := w.connectBlock(&wire.ShaHash{0x11}, 11); pending {
+		t.Fatal("mined spend: got pending spends, want none")
+	}
+	checkWatcherBalance(t, "mined spend", w, addr, 20, 0)
+	if pending := w.disconnectBlock(11); !pending {
+		t.Fatal("disconnected spend: got no pending spends")
+	}
+	s := w.spent[op]
+	if s.height != -1 || s.seenHeight != 10 || s.utxo.BlockHeight != 10 {
+		t.Fatalf("disconnected spend: got spend at height %d seen at "+
+			"%d spending output at height %d, want -1, 10 and 10",
+			s.height, s.seenHeight, s.utxo.BlockHeight)
+	}
+
+	// A conflicting transaction mined in place of the pending spend
+	// replaces it.
+	conflict := newTestWatcherTx(t, []wire.OutPoint{op},
+		testWatcherOutput{other, 95})
+	w.processTx(conflict, &wire.ShaHash{0x12}, 11)
+	if s.spender != *conflict.Sha() || s.height != 11 {
+		t.Fatalf("conflicting spend: got spender %v at height %d, "+
+			"want %v at height 11", s.spender, s.height,
+			conflict.Sha())
+	}
+
+	// A pending spend which is no longer in the memory pool is restored.
+	w.disconnectBlock(11)
+	w.mtx.Lock()
+	w.restoreSpend(&op, w.spent[op])
+	w.mtx.Unlock()
+	checkWatcherBalance(t, "restored spend", w, addr, 120, 0)
+	if _, ok := w.spent[op]; ok {
+		t.Fatal("restored spend: spend is still tracked")
+	}
+	if owner := w.owners[op]; owner != addr.EncodeAddress() {
+		t.Fatalf("restored spend: got owner %q, want %q", owner,
+			addr.EncodeAddress())
+	}
+
+	// A spend which stays pending for addrWatcherPruneDepth blocks is
+	// pruned, and its outpoint queued to be released.
+	w.processTx(spend, nil, -1)
+	checkWatcherBalance(t, "pending spend", w, addr, 20, 0)
+	if pending := w.connectBlock(&wire.ShaHash{0x13}, 10+addrWatcherPruneDepth-1); !pending {
+		t.Fatal("pending spend: got no pending spends")
+	}
+	if len(w.pruned) != 0 {
+		t.Fatalf("pending spend: got pruned outpoints %v, want none",
+			w.pruned)
+	}
+	if pending := w.connectBlock(&wire.ShaHash{0x14}, 10+addrWatcherPruneDepth); pending {
+		t.Fatal("expired spend: got pending spends, want none")
+	}
+	if len(w.pruned) != 1 || w.pruned[0] != op {
+		t.Fatalf("expired spend: got pruned outpoints %v, want %v",
+			w.pruned, op)
+	}
+	w.pruned = nil
+
+	// A mined spend is pruned once it is addrWatcherPruneDepth blocks
+	// deep.
+	spend2 := newTestWatcherTx(t, []wire.OutPoint{op2},
+		testWatcherOutput{other, 15})
+	height := int32(10 + addrWatcherPruneDepth + 1)
+	w.processTx(spend2, &wire.ShaHash{0x15}, height)
+	checkWatcherBalance(t, "mined spend", w, addr, 0, 0)
+	w.connectBlock(&wire.ShaHash{0x16}, height+addrWatcherPruneDepth-1)
+	if _, ok := w.spent[op2]; !ok || len(w.pruned) != 0 {
+		t.Fatalf("shallow spend: got pruned outpoints %v, want none",
+			w.pruned)
+	}
+	w.connectBlock(&wire.ShaHash{0x17}, height+addrWatcherPruneDepth)
+	if _, ok := w.spent[op2]; ok || len(w.pruned) != 1 ||
+		w.pruned[0] != op2 {
+		t.Fatalf("deep spend: got pruned outpoints %v, want %v",
+			w.pruned, op2)
+	}
+	if len(w.blocks) > addrWatcherPruneDepth {
+		t.Fatalf("got %d recorded blocks, want at most %d",
+			len(w.blocks), addrWatcherPruneDepth)
+	}
+}
+
+// TestAddressWatcherRelease ensures the registrations made by a watcher are
+// reference counted, released once pruned or when the watcher is stopped, and
+// that the registrations made by the caller are never released.
+func TestAddressWatcherRelease(t *testing.T) {
+	t.Parallel()
+
+	addr := newTestWatcherAddress(t, 1)
+	w := newTestAddressWatcher(addr)
+	c := w.client
+	state := c.ntfnState
+
+	callerOp := btcjson.OutPoint{Hash: "aa", Index: 0}
+	sharedWireOp := wire.OutPoint{Hash: wire.ShaHash{0xbb}, Index: 1}
+	sharedOp := newOutPointFromWire(&sharedWireOp)
+	prunedOp := wire.OutPoint{Hash: wire.ShaHash{0xcc}, Index: 2}
+	state.notifySpent[callerOp] = struct{}{}
+	state.notifyReceived[addr.EncodeAddress()] = struct{}{}
+
+	// Outpoints and addresses registered by the caller are not counted.
+	err := c.retainSpent([]btcjson.OutPoint{callerOp, sharedOp,
+		newOutPointFromWire(&prunedOp)})
+	if err != nil {
+		t.Fatalf("retainSpent: %v", err)
+	}
+	if err := c.retainSpent([]btcjson.OutPoint{sharedOp}); err != nil {
+		t.Fatalf("retainSpent: %v", err)
+	}
+	if err := c.retainReceived([]string{addr.EncodeAddress()}); err != nil {
+		t.Fatalf("retainReceived: %v", err)
+	}
+	if _, ok := state.sharedSpent[callerOp]; ok {
+		t.Fatal("caller outpoint is reference counted")
+	}
+	if _, ok := state.sharedReceived[addr.EncodeAddress()]; ok {
+		t.Fatal("caller address is reference counted")
+	}
+	if refs := state.sharedSpent[sharedOp]; refs != 2 {
+		t.Fatalf("got %d references to shared outpoint, want 2", refs)
+	}
+
+	// Pruned outpoints are released.
+	w.pruned = []wire.OutPoint{prunedOp}
+	w.releasePruned()
+	if _, ok := state.sharedSpent[newOutPointFromWire(&prunedOp)]; ok {
+		t.Fatal("pruned outpoint is still referenced")
+	}
+	if len(w.pruned) != 0 {
+		t.Fatalf("got pruned outpoints %v after release, want none",
+			w.pruned)
+	}
+
+	// Stopping the watcher releases the outputs it tracks, while the
+	// references held by others are kept.
+	w.owners[sharedWireOp] = addr.EncodeAddress()
+	w.wg.Add(1)
+	go w.handler()
+	w.Stop()
+	if refs := state.sharedSpent[sharedOp]; refs != 1 {
+		t.Fatalf("got %d references to shared outpoint after stop, "+
+			"want 1", refs)
+	}
+	if _, ok := state.notifySpent[callerOp]; !ok {
+		t.Fatal("caller outpoint was released")
+	}
+
+	// Failed registrations are not referenced.  The client is not
+	// connected, so all requests fail once it has notification handlers.
+	c.ntfnHandlers = &NotificationHandlers{}
+	c.connEstablished = make(chan struct{})
+	newOp := btcjson.OutPoint{Hash: "dd", Index: 0}
+	err = c.retainSpent([]btcjson.OutPoint{sharedOp, newOp})
+	if err != ErrClientNotConnected {
+		t.Fatalf("retainSpent: got error %v, want %v", err,
+			ErrClientNotConnected)
+	}
+	if _, ok := state.sharedSpent[newOp]; ok {
+		t.Fatal("failed registration is referenced")
+	}
+	if refs := state.sharedSpent[sharedOp]; refs != 2 {
+		t.Fatalf("got %d references to shared outpoint, want 2", refs)
+	}
+
+	// A registration made by the caller takes over the one made for the
+	// observers, so they no longer release it.
+	err = c.NotifySpent([]*wire.OutPoint{&sharedWireOp})
+	if err != ErrClientNotConnected {
+		t.Fatalf("NotifySpent: got error %v, want %v", err,
+			ErrClientNotConnected)
+	}
+	if _, ok := state.sharedSpent[sharedOp]; ok {
+		t.Fatal("outpoint registered by the caller is still referenced")
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ppcsuite/btcutil"
+)
+
+// maxAmount is the maximum amount of peercoins in base units, which matches
+// MAX_MONEY of the Peercoin reference implementation.
+const maxAmount = 2000000000 * peercoinCoin
+
+var (
+	// ErrAmountPrecision is an error to describe the condition where an
+	// amount is more precise than the base unit of one millionth of a
+	// peercoin.
+	ErrAmountPrecision = errors.New("amount is more precise than one " +
+		"millionth of a peercoin")
+
+	// ErrAmountRange is an error to describe the condition where an amount
+	// is larger than the maximum amount of peercoins or is not a number.
+	ErrAmountRange = errors.New("amount out of range")
+)
+
+// AmountUnit describes a unit amounts of peercoins can be expressed in.
+type AmountUnit int
+
+// These constants define the units amounts can be expressed in.
+const (
+	AmountPPC AmountUnit = iota
+	AmountMilliPPC
+	AmountMicroPPC
+)
+
+// amountUnitStrings is a map of amount units back to their symbols for pretty
+// printing.
+var amountUnitStrings = map[AmountUnit]string{
+	AmountPPC:      "PPC",
+	AmountMilliPPC: "mPPC",
+	AmountMicroPPC: "µPPC",
+}
+
+// String returns the symbol of the AmountUnit.
+func (u AmountUnit) String() string {
+	if s, ok := amountUnitStrings[u]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown AmountUnit (%d)", int(u))
+}
+
+// decimals returns the number of decimal places of an amount expressed in the
+// unit, which is the number of base units in the unit as a power of ten.
+func (u AmountUnit) decimals() int {
+	switch u {
+	case AmountMilliPPC:
+		return 3
+	case AmountMicroPPC:
+		return 0
+	default:
+		return 6
+	}
+}
+
+// Amount represents an amount of peercoins in base units of one millionth of a
+// peercoin.  Unlike float64 values, it is converted to and from decimal strings
+// and JSON numbers exactly, which is how the client passes amounts to and from
+// the server.
+type Amount int64
+
+// NewAmount returns the amount of the passed number of peercoins, such as an
+// amount field of a btcjson result, rounded half away from zero to the nearest
+// base unit like the Peercoin reference implementation.  Since any JSON number
+// with at most six decimal places is decoded to the float64 nearest to it, the
+// rounding recovers its exact value.
+func NewAmount(f float64) (Amount, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) ||
+		math.Abs(f) > float64(maxAmount)/peercoinCoin {
+
+		return 0, ErrAmountRange
+	}
+	return Amount(round(f * peercoinCoin)), nil
+}
+
+// round returns the passed number rounded half away from zero.
+func round(f float64) int64 {
+	if f < 0 {
+		return int64(f - 0.5)
+	}
+	return int64(f + 0.5)
+}
+
+// ParseAmount parses the passed decimal string, such as "1.5" or "1e-3",
+// expressed in the passed unit, exactly.  It returns ErrAmountPrecision when
+// the string is more precise than the base unit rather than rounding it.
+func ParseAmount(s string, unit AmountUnit) (Amount, error) {
+	return parseAmount(s, unit, false)
+}
+
+// parseAmount parses the passed decimal string expressed in the passed unit.
+// When rounding is set, strings more precise than the base unit are rounded half
+// away from zero to the nearest base unit instead of being rejected.
+func parseAmount(s string, unit AmountUnit, rounding bool) (Amount, error) {
+	if strings.ContainsAny(s, "/") {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	scale := new(big.Int).Exp(big.NewInt(10),
+		big.NewInt(int64(unit.decimals())), nil)
+	r.Mul(r, new(big.Rat).SetInt(scale))
+
+	n := new(big.Int)
+	switch {
+	case r.IsInt():
+		n.Set(r.Num())
+	case !rounding:
+		return 0, ErrAmountPrecision
+	default:
+		// Round the magnitude half away from zero.
+		rem := new(big.Int)
+		n.QuoRem(new(big.Int).Abs(r.Num()), r.Denom(), rem)
+		if rem.Lsh(rem, 1).Cmp(r.Denom()) >= 0 {
+			n.Add(n, big.NewInt(1))
+		}
+		if r.Sign() < 0 {
+			n.Neg(n)
+		}
+	}
+	if n.CmpAbs(big.NewInt(maxAmount)) > 0 {
+		return 0, ErrAmountRange
+	}
+	return Amount(n.Int64()), nil
+}
+
+// ToUnit returns the amount expressed in the passed unit as the floating point
+// number nearest to it, which may not be exact.  Use Format for an exact
+// representation.
+func (a Amount) ToUnit(unit AmountUnit) float64 {
+	return float64(a) / math.Pow10(unit.decimals())
+}
+
+// ToPPC is the equivalent of calling ToUnit with AmountPPC.  Since the returned
+// number is the nearest to the amount, it is marshalled to JSON as the exact
+// decimal amount, which is how amounts are passed in btcjson commands.
+func (a Amount) ToPPC() float64 {
+	return a.ToUnit(AmountPPC)
+}
+
+// Format returns the amount expressed in the passed unit as an exact decimal
+// string with all the decimal places of the unit, such as "1.500000" for one
+// and a half peercoins.
+func (a Amount) Format(unit AmountUnit) string {
+	decimals := unit.decimals()
+	if decimals == 0 {
+		return strconv.FormatInt(int64(a), 10)
+	}
+
+	// The magnitude is computed as an unsigned integer so the smallest
+	// int64 does not overflow.
+	sign := ""
+	u := uint64(a)
+	if a < 0 {
+		sign = "-"
+		u = -u
+	}
+	scale := uint64(math.Pow10(decimals))
+	return fmt.Sprintf("%s%d.%0*d", sign, u/scale, decimals, u%scale)
+}
+
+// String returns the amount in peercoins followed by the unit symbol, such as
+// "1.500000 PPC".
+func (a Amount) String() string {
+	return a.Format(AmountPPC) + " " + AmountPPC.String()
+}
+
+// BtcutilAmount returns the amount as a btcutil.Amount, which uses the same
+// base unit.
+func (a Amount) BtcutilAmount() btcutil.Amount {
+	return btcutil.Amount(a)
+}
+
+// MarshalJSON satisfies the json.Marshaler interface and marshals the amount as
+// an exact JSON number of peercoins.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.Format(AmountPPC)), nil
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface and unmarshals the
+// amount from a JSON number, or a string containing one, of peercoins exactly.
+// Numbers more precise than the base unit are rounded half away from zero to
+// the nearest base unit like NewAmount.  A JSON null leaves the amount
+// unchanged.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	amount, err := parseAmount(s, AmountPPC, true)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// unmarshalAmounts unmarshals the passed JSON into a result which shadows the
+// float64 amounts of the btcjson result it embeds with exact amounts, and then
+// into the embedded result, so its float64 amounts are decoded as well for
+// compatibility.  The result must not implement json.Unmarshaler itself.
+func unmarshalAmounts(data []byte, result, embedded interface{}) error {
+	if err := json.Unmarshal(data, result); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, embedded)
+}
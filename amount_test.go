@@ -0,0 +1,264 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// TestParseAmount ensures decimal strings are parsed exactly in each unit and
+// strings which are more precise than the base unit or out of range are
+// rejected.
+func TestParseAmount(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in    string
+		unit  AmountUnit
+		want  Amount
+		err   error
+		valid bool
+	}{
+		{"1", AmountPPC, 1000000, nil, true},
+		{"1.5", AmountPPC, 1500000, nil, true},
+		{"0.000001", AmountPPC, 1, nil, true},
+		{"-0.01", AmountPPC, -10000, nil, true},
+		{"1e-3", AmountPPC, 1000, nil, true},
+		{"2.5", AmountMilliPPC, 2500, nil, true},
+		{"42", AmountMicroPPC, 42, nil, true},
+		{"2000000000", AmountPPC, maxAmount, nil, true},
+		{"0.0000001", AmountPPC, 0, ErrAmountPrecision, true},
+		{"0.5", AmountMicroPPC, 0, ErrAmountPrecision, true},
+		{"2000000000.000001", AmountPPC, 0, ErrAmountRange, true},
+		{"1/2", AmountPPC, 0, nil, false},
+		{"abc", AmountPPC, 0, nil, false},
+		{"", AmountPPC, 0, nil, false},
+	}
+
+	for _, test := range tests {
+		got, err := ParseAmount(test.in, test.unit)
+		switch {
+		case !test.valid:
+			if err == nil {
+				t.Errorf("ParseAmount(%q, %v): expected error",
+					test.in, test.unit)
+			}
+		case err != test.err:
+			t.Errorf("ParseAmount(%q, %v): got error %v, want %v",
+				test.in, test.unit, err, test.err)
+		case got != test.want:
+			t.Errorf("ParseAmount(%q, %v): got %d, want %d",
+				test.in, test.unit, got, test.want)
+		}
+	}
+}
+
+// TestAmountUnmarshalJSON ensures JSON numbers and strings are unmarshalled
+// exactly, and numbers which are more precise than the base unit are rounded
+// half away from zero.
+func TestAmountUnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in    string
+		want  Amount
+		valid bool
+	}{
+		{`1.5`, 1500000, true},
+		{`"1.5"`, 1500000, true},
+		{`0`, 0, true},
+		{`0.1`, 100000, true},
+		{`1.2345678`, 1234568, true},
+		{`0.0000005`, 1, true},
+		{`0.00000049`, 0, true},
+		{`-0.0000005`, -1, true},
+		{`-1.2345674`, -1234567, true},
+		{`2000000001`, 0, false},
+		{`"abc"`, 0, false},
+		{`true`, 0, false},
+	}
+
+	for _, test := range tests {
+		var got Amount
+		err := json.Unmarshal([]byte(test.in), &got)
+		switch {
+		case !test.valid:
+			if err == nil {
+				t.Errorf("Unmarshal(%s): expected error", test.in)
+			}
+		case err != nil:
+			t.Errorf("Unmarshal(%s): unexpected error: %v", test.in,
+				err)
+		case got != test.want:
+			t.Errorf("Unmarshal(%s): got %d, want %d", test.in, got,
+				test.want)
+		}
+	}
+
+	// A null leaves the amount unchanged.
+	got := Amount(1500000)
+	if err := json.Unmarshal([]byte(`null`), &got); err != nil {
+		t.Errorf("Unmarshal(null): unexpected error: %v", err)
+	} else if got != 1500000 {
+		t.Errorf("Unmarshal(null): got %d, want 1500000", got)
+	}
+}
+
+// TestAmountResultsUnmarshalJSON ensures the results which shadow float64
+// amounts decode the amounts exactly, including those of nested results, while
+// the float64 amounts of the embedded results are decoded as well.
+func TestAmountResultsUnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	var getTx GetTransactionResult
+	err := json.Unmarshal([]byte(`{"amount":-1.1,"fee":-0.01,"txid":"ab",`+
+		`"details":[{"account":"","amount":-1.1,"fee":-0.01,`+
+		`"category":"send"},{"account":"","amount":0.3,`+
+		`"category":"receive"}]}`), &getTx)
+	if err != nil {
+		t.Fatalf("Unmarshal gettransaction: unexpected error: %v", err)
+	}
+	if getTx.Amount != -1100000 || getTx.Fee != -10000 ||
+		getTx.GetTransactionResult.Amount != -1.1 ||
+		getTx.TxID != "ab" {
+
+		t.Errorf("gettransaction: got amount %d (%v) and fee %d of %q",
+			getTx.Amount, getTx.GetTransactionResult.Amount,
+			getTx.Fee, getTx.TxID)
+	}
+	if len(getTx.Details) != 2 ||
+		len(getTx.GetTransactionResult.Details) != 2 {
+
+		t.Fatalf("gettransaction: got %d details (%d embedded), want 2",
+			len(getTx.Details), len(getTx.GetTransactionResult.Details))
+	}
+	send, receive := getTx.Details[0], getTx.Details[1]
+	if send.Amount != -1100000 || send.Fee == nil || *send.Fee != -10000 ||
+		send.GetTransactionDetailsResult.Amount != -1.1 ||
+		send.Category != "send" {
+
+		t.Errorf("gettransaction: got send details %+v", send)
+	}
+	if receive.Amount != 300000 || receive.Fee != nil ||
+		receive.GetTransactionDetailsResult.Amount != 0.3 {
+
+		t.Errorf("gettransaction: got receive details %+v", receive)
+	}
+
+	var rawTx TxRawResult
+	err = json.Unmarshal([]byte(`{"txid":"cd","vout":[{"value":0.000001,`+
+		`"n":0},{"value":12.345678,"n":1}]}`), &rawTx)
+	if err != nil {
+		t.Fatalf("Unmarshal getrawtransaction: unexpected error: %v", err)
+	}
+	if rawTx.Txid != "cd" || len(rawTx.Vout) != 2 ||
+		len(rawTx.TxRawResult.Vout) != 2 {
+
+		t.Fatalf("getrawtransaction: got %q with %d outputs (%d "+
+			"embedded), want 2", rawTx.Txid, len(rawTx.Vout),
+			len(rawTx.TxRawResult.Vout))
+	}
+	for i, want := range []Amount{1, 12345678} {
+		vout := rawTx.Vout[i]
+		if vout.Value != want || vout.N != uint32(i) ||
+			vout.Vout.Value != want.ToPPC() ||
+			rawTx.TxRawResult.Vout[i].Value != want.ToPPC() {
+
+			t.Errorf("getrawtransaction: output %d: got %+v, want "+
+				"value %d", i, vout, want)
+		}
+	}
+}
+
+// TestAmountFormat ensures amounts are formatted exactly with all the decimal
+// places of each unit.
+func TestAmountFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   Amount
+		unit AmountUnit
+		want string
+	}{
+		{1500000, AmountPPC, "1.500000"},
+		{1, AmountPPC, "0.000001"},
+		{-10000, AmountPPC, "-0.010000"},
+		{0, AmountPPC, "0.000000"},
+		{2500, AmountMilliPPC, "2.500"},
+		{-42, AmountMicroPPC, "-42"},
+		{math.MinInt64, AmountPPC, "-9223372036854.775808"},
+	}
+
+	for _, test := range tests {
+		if got := test.in.Format(test.unit); got != test.want {
+			t.Errorf("Format(%d, %v): got %q, want %q", test.in,
+				test.unit, got, test.want)
+		}
+	}
+
+	if got := Amount(1500000).String(); got != "1.500000 PPC" {
+		t.Errorf("String: got %q, want %q", got, "1.500000 PPC")
+	}
+	marshalled, err := json.Marshal(Amount(-1))
+	if err != nil || string(marshalled) != "-0.000001" {
+		t.Errorf("Marshal: got %s (%v), want -0.000001", marshalled,
+			err)
+	}
+}
+
+// TestNewAmount ensures floating point numbers of peercoins are rounded to the
+// nearest base unit and invalid numbers are rejected.
+func TestNewAmount(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   float64
+		want Amount
+		err  error
+	}{
+		{0.1, 100000, nil},
+		{1.000001, 1000001, nil},
+		{-0.0000015, -2, nil},
+		{0.0000004, 0, nil},
+		{2e9, maxAmount, nil},
+		{2e9 + 1, 0, ErrAmountRange},
+		{math.NaN(), 0, ErrAmountRange},
+		{math.Inf(-1), 0, ErrAmountRange},
+	}
+
+	for _, test := range tests {
+		got, err := NewAmount(test.in)
+		if err != test.err || got != test.want {
+			t.Errorf("NewAmount(%v): got %d (%v), want %d (%v)",
+				test.in, got, err, test.want, test.err)
+		}
+	}
+}
+
+// TestAmountToPPCMarshal ensures the floating point number of peercoins passed
+// in btcjson commands is marshalled to JSON as the exact amount.
+func TestAmountToPPCMarshal(t *testing.T) {
+	t.Parallel()
+
+	tests := []Amount{
+		1, 10000, 100000, 123456789, 999999999999, maxAmount - 1,
+		maxAmount, -1, -maxAmount + 1,
+	}
+
+	for _, amount := range tests {
+		marshalled, err := json.Marshal(amount.ToPPC())
+		if err != nil {
+			t.Errorf("Marshal(%d): unexpected error: %v", amount, err)
+			continue
+		}
+		got, err := ParseAmount(string(marshalled), AmountPPC)
+		if err != nil || got != amount {
+			t.Errorf("Marshal(%d): got %s, which is %d (%v)", amount,
+				marshalled, got, err)
+		}
+	}
+}
@@ -312,6 +312,22 @@ func (c *Client) GetRawMempool() ([]*wire.ShaHash, error) {
 	return c.GetRawMempoolAsync().Receive()
 }
 
+// GetRawMempoolVerboseResult models the data of a memory pool transaction
+// returned by the getrawmempool command when the verbose flag is set, with the
+// fee of the transaction decoded exactly.  The float64 Fee of the embedded
+// result is decoded as well for compatibility.
+type GetRawMempoolVerboseResult struct {
+	btcjson.GetRawMempoolVerboseResult
+	Fee Amount `json:"fee"`
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface and unmarshals both
+// the exact fee and the embedded result.
+func (r *GetRawMempoolVerboseResult) UnmarshalJSON(data []byte) error {
+	type result GetRawMempoolVerboseResult
+	return unmarshalAmounts(data, (*result)(r), &r.GetRawMempoolVerboseResult)
+}
+
 // FutureGetRawMempoolVerboseResult is a future promise to deliver the result of
 // a GetRawMempoolVerboseAsync RPC invocation (or an applicable error).
 type FutureGetRawMempoolVerboseResult chan *response
@@ -319,7 +335,7 @@ type FutureGetRawMempoolVerboseResult chan *response
 // Receive waits for the response promised by the future and returns a map of
 // transaction hashes to an associated data structure with information about the
 // transaction for all transactions in the memory pool.
-func (r FutureGetRawMempoolVerboseResult) Receive() (map[string]btcjson.GetRawMempoolVerboseResult, error) {
+func (r FutureGetRawMempoolVerboseResult) Receive() (map[string]GetRawMempoolVerboseResult, error) {
 	res, err := receiveFuture(r)
 	if err != nil {
 		return nil, err
@@ -327,7 +343,7 @@ func (r FutureGetRawMempoolVerboseResult) Receive() (map[string]btcjson.GetRawMe
 
 	// Unmarshal the result as a map of strings (tx shas) to their detailed
 	// results.
-	var mempoolItems map[string]btcjson.GetRawMempoolVerboseResult
+	var mempoolItems map[string]GetRawMempoolVerboseResult
 	err = json.Unmarshal(res, &mempoolItems)
 	if err != nil {
 		return nil, err
@@ -350,7 +366,7 @@ func (c *Client) GetRawMempoolVerboseAsync() FutureGetRawMempoolVerboseResult {
 // the memory pool.
 //
 // See GetRawMempool to retrieve only the transaction hashes instead.
-func (c *Client) GetRawMempoolVerbose() (map[string]btcjson.GetRawMempoolVerboseResult, error) {
+func (c *Client) GetRawMempoolVerbose() (map[string]GetRawMempoolVerboseResult, error) {
 	return c.GetRawMempoolVerboseAsync().Receive()
 }
 
@@ -443,13 +459,28 @@ func (c *Client) VerifyChainBlocks(checkLevel, numBlocks int32) (bool, error) {
 	return c.VerifyChainBlocksAsync(checkLevel, numBlocks).Receive()
 }
 
+// GetTxOutResult models the data from the gettxout command, with the value of
+// the output decoded exactly.  The float64 Value of the embedded result is
+// decoded as well for compatibility.
+type GetTxOutResult struct {
+	btcjson.GetTxOutResult
+	Value Amount `json:"value"`
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface and unmarshals both
+// the exact value and the embedded result.
+func (r *GetTxOutResult) UnmarshalJSON(data []byte) error {
+	type result GetTxOutResult
+	return unmarshalAmounts(data, (*result)(r), &r.GetTxOutResult)
+}
+
 // FutureGetTxOutResult is a future promise to deliver the result of a
 // GetTxOutAsync RPC invocation (or an applicable error).
 type FutureGetTxOutResult chan *response
 
 // Receive waits for the response promised by the future and returns a
 // transaction given its hash.
-func (r FutureGetTxOutResult) Receive() (*btcjson.GetTxOutResult, error) {
+func (r FutureGetTxOutResult) Receive() (*GetTxOutResult, error) {
 	res, err := receiveFuture(r)
 	if err != nil {
 		return nil, err
@@ -462,7 +493,7 @@ func (r FutureGetTxOutResult) Receive() (*btcjson.GetTxOutResult, error) {
 	}
 
 	// Unmarshal result as an gettxout result object.
-	var txOutInfo *btcjson.GetTxOutResult
+	var txOutInfo *GetTxOutResult
 	err = json.Unmarshal(res, &txOutInfo)
 	if err != nil {
 		return nil, err
@@ -488,6 +519,6 @@ func (c *Client) GetTxOutAsync(txHash *wire.ShaHash, index uint32, mempool bool)
 
 // GetTxOut returns the transaction output info if it's unspent and
 // nil, otherwise.
-func (c *Client) GetTxOut(txHash *wire.ShaHash, index uint32, mempool bool) (*btcjson.GetTxOutResult, error) {
+func (c *Client) GetTxOut(txHash *wire.ShaHash, index uint32, mempool bool) (*GetTxOutResult, error) {
 	return c.GetTxOutAsync(txHash, index, mempool).Receive()
 }
@@ -0,0 +1,271 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/ppcsuite/btcutil"
+)
+
+// ErrInvalidHeightRange is an error to describe the condition where the caller
+// is trying to collect statistics over an empty range of blocks.
+var ErrInvalidHeightRange = errors.New("the end height must not be lower " +
+	"than the start height")
+
+// ChainStatsWindow houses the proof-of-stake and proof-of-work statistics of a
+// range of consecutive blocks.
+type ChainStatsWindow struct {
+	StartHeight int32     `json:"startheight"`
+	EndHeight   int32     `json:"endheight"`
+	StartTime   time.Time `json:"starttime"`
+	EndTime     time.Time `json:"endtime"`
+
+	ProofOfStakeBlocks int     `json:"posblocks"`
+	ProofOfWorkBlocks  int     `json:"powblocks"`
+	ProofOfStakeRatio  float64 `json:"posratio"`
+
+	// AvgProofOfStakeDifficulty and AvgProofOfWorkDifficulty are the
+	// average difficulties of the blocks of each class.
+	AvgProofOfStakeDifficulty float64 `json:"avgposdifficulty"`
+	AvgProofOfWorkDifficulty  float64 `json:"avgpowdifficulty"`
+
+	// Minted is the amount created by the proof-of-stake blocks and Mined
+	// is the amount created by the proof-of-work blocks.
+	Minted btcutil.Amount `json:"minted"`
+	Mined  btcutil.Amount `json:"mined"`
+
+	// AvgProofOfStakeInterval and AvgProofOfWorkInterval are the average
+	// number of seconds between consecutive blocks of each class.  The
+	// first block of each class in the collected range has no interval.
+	AvgProofOfStakeInterval float64 `json:"avgposinterval"`
+	AvgProofOfWorkInterval  float64 `json:"avgpowinterval"`
+
+	// Sums used to compute the averages.
+	posDifficulty float64
+	powDifficulty float64
+	posIntervals  int
+	powIntervals  int
+	posInterval   time.Duration
+	powInterval   time.Duration
+}
+
+// add adds the passed block to the window.  The interval is the time since the
+// previous block of the same class, or zero when it is unknown.
+func (w *ChainStatsWindow) add(block *StakeBlockNtfn, difficulty float64,
+	interval time.Duration) {
+
+	if w.ProofOfStakeBlocks+w.ProofOfWorkBlocks == 0 {
+		w.StartHeight = block.Height
+		w.StartTime = block.Time
+	}
+	w.EndHeight = block.Height
+	w.EndTime = block.Time
+
+	if block.ProofOfStake {
+		w.ProofOfStakeBlocks++
+		w.posDifficulty += difficulty
+		w.Minted += block.Minted
+		if interval > 0 {
+			w.posIntervals++
+			w.posInterval += interval
+		}
+	} else {
+		w.ProofOfWorkBlocks++
+		w.powDifficulty += difficulty
+		w.Mined += block.Minted
+		if interval > 0 {
+			w.powIntervals++
+			w.powInterval += interval
+		}
+	}
+}
+
+// finish computes the averages and ratios of the window.
+func (w *ChainStatsWindow) finish() {
+	total := w.ProofOfStakeBlocks + w.ProofOfWorkBlocks
+	if total > 0 {
+		w.ProofOfStakeRatio = float64(w.ProofOfStakeBlocks) /
+			float64(total)
+	}
+	if w.ProofOfStakeBlocks > 0 {
+		w.AvgProofOfStakeDifficulty = w.posDifficulty /
+			float64(w.ProofOfStakeBlocks)
+	}
+	if w.ProofOfWorkBlocks > 0 {
+		w.AvgProofOfWorkDifficulty = w.powDifficulty /
+			float64(w.ProofOfWorkBlocks)
+	}
+	if w.posIntervals > 0 {
+		w.AvgProofOfStakeInterval = w.posInterval.Seconds() /
+			float64(w.posIntervals)
+	}
+	if w.powIntervals > 0 {
+		w.AvgProofOfWorkInterval = w.powInterval.Seconds() /
+			float64(w.powIntervals)
+	}
+}
+
+// ChainStats houses the proof-of-stake and proof-of-work statistics of a range
+// of blocks, split into windows of a fixed number of blocks.
+type ChainStats struct {
+	StartHeight int32              `json:"startheight"`
+	EndHeight   int32              `json:"endheight"`
+	WindowSize  int32              `json:"windowsize"`
+	Windows     []ChainStatsWindow `json:"windows"`
+	Total       ChainStatsWindow   `json:"total"`
+
+	// lastPoS and lastPoW are the timestamps of the last block of each
+	// class added to the statistics.
+	lastPoS time.Time
+	lastPoW time.Time
+}
+
+// newChainStats returns empty statistics for the blocks from startHeight
+// through endHeight, split into windows of windowSize blocks, or a single
+// window when windowSize is not positive.
+func newChainStats(startHeight, endHeight, windowSize int32) *ChainStats {
+	if windowSize <= 0 {
+		windowSize = endHeight - startHeight + 1
+	}
+	return &ChainStats{
+		StartHeight: startHeight,
+		EndHeight:   endHeight,
+		WindowSize:  windowSize,
+	}
+}
+
+// add adds the passed block, which must follow the previously added block, to
+// the statistics.  A new window is started every WindowSize blocks from the
+// start height.
+func (s *ChainStats) add(block *StakeBlockNtfn, difficulty float64) {
+	var interval time.Duration
+	last := &s.lastPoW
+	if block.ProofOfStake {
+		last = &s.lastPoS
+	}
+	if !last.IsZero() {
+		interval = block.Time.Sub(*last)
+	}
+	*last = block.Time
+
+	if len(s.Windows) == 0 ||
+		(block.Height-s.StartHeight)%s.WindowSize == 0 {
+
+		s.Windows = append(s.Windows, ChainStatsWindow{})
+	}
+	s.Windows[len(s.Windows)-1].add(block, difficulty, interval)
+	s.Total.add(block, difficulty, interval)
+}
+
+// finish computes the averages and ratios of the windows and the total.
+func (s *ChainStats) finish() {
+	for i := range s.Windows {
+		s.Windows[i].finish()
+	}
+	s.Total.finish()
+}
+
+// WriteJSON writes the statistics to the passed writer as JSON.
+func (s *ChainStats) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s)
+}
+
+// chainStatsCSVHeader is the header row of the statistics written by WriteCSV.
+var chainStatsCSVHeader = []string{
+	"window", "startheight", "endheight", "starttime", "endtime", "posblocks",
+	"powblocks", "posratio", "avgposdifficulty", "avgpowdifficulty",
+	"minted", "mined", "avgposinterval", "avgpowinterval",
+}
+
+// csvRecord returns the window as a row of the statistics written by WriteCSV,
+// labeled with the passed window name.
+func (w *ChainStatsWindow) csvRecord(name string) []string {
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return []string{
+		name,
+		strconv.FormatInt(int64(w.StartHeight), 10),
+		strconv.FormatInt(int64(w.EndHeight), 10),
+		w.StartTime.UTC().Format(time.RFC3339),
+		w.EndTime.UTC().Format(time.RFC3339),
+		strconv.Itoa(w.ProofOfStakeBlocks),
+		strconv.Itoa(w.ProofOfWorkBlocks),
+		formatFloat(w.ProofOfStakeRatio),
+		formatFloat(w.AvgProofOfStakeDifficulty),
+		formatFloat(w.AvgProofOfWorkDifficulty),
+		strconv.FormatInt(int64(w.Minted), 10),
+		strconv.FormatInt(int64(w.Mined), 10),
+		formatFloat(w.AvgProofOfStakeInterval),
+		formatFloat(w.AvgProofOfWorkInterval),
+	}
+}
+
+// WriteCSV writes the statistics to the passed writer as CSV, with a header
+// row followed by one row per window, named by its index, and a final row for
+// all blocks named total.  The minted and mined amounts are in base units and
+// the intervals are in seconds.
+func (s *ChainStats) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(chainStatsCSVHeader); err != nil {
+		return err
+	}
+	for i := range s.Windows {
+		record := s.Windows[i].csvRecord(strconv.Itoa(i))
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := cw.Write(s.Total.csvRecord("total")); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// CollectChainStats walks the blocks from startHeight through endHeight and
+// returns their proof-of-stake and proof-of-work statistics, split into windows
+// of windowSize blocks, or a single window when windowSize is not positive.
+// Each block is fetched with GetBlockHash and GetBlockVerbose, and the values
+// of the inputs staked by proof-of-stake blocks with GetRawTransaction.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) CollectChainStats(startHeight, endHeight,
+	windowSize int32) (*ChainStats, error) {
+
+	if endHeight < startHeight {
+		return nil, ErrInvalidHeightRange
+	}
+
+	stats := newChainStats(startHeight, endHeight, windowSize)
+	for height := startHeight; height <= endHeight; height++ {
+		hash, err := c.GetBlockHash(int64(height))
+		if err != nil {
+			return nil, err
+		}
+		verbose, err := c.GetBlockVerbose(hash, true)
+		if err != nil {
+			return nil, err
+		}
+		block, err := c.stakeBlockDetails(hash, verbose)
+		if err != nil {
+			return nil, err
+		}
+		if block.MintedErr != nil {
+			return nil, block.MintedErr
+		}
+		stats.add(block, verbose.Difficulty)
+	}
+
+	stats.finish()
+	return stats, nil
+}
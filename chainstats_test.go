@@ -0,0 +1,205 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ppcsuite/btcutil"
+)
+
+// chainStatsTestBlock describes a block fed to the statistics by the tests.
+type chainStatsTestBlock struct {
+	height       int32
+	proofOfStake bool
+	offset       int64 // seconds after chainStatsTestStart
+	difficulty   float64
+	minted       btcutil.Amount
+}
+
+// chainStatsTestStart is the timestamp the blocks of the tests are relative to.
+var chainStatsTestStart = time.Unix(1400000000, 0)
+
+// chainStatsTestBlocks are seven consecutive blocks with both classes of
+// blocks in each window of three blocks.
+var chainStatsTestBlocks = []chainStatsTestBlock{
+	{100, false, 0, 10, 50 * peercoinCoin},
+	{101, true, 60, 2, 1 * peercoinCoin},
+	{102, true, 180, 4, 2 * peercoinCoin},
+	{103, false, 600, 20, 50 * peercoinCoin},
+	{104, true, 660, 6, 3 * peercoinCoin},
+	{105, false, 900, 30, 40 * peercoinCoin},
+	{106, true, 1000, 8, 4 * peercoinCoin},
+}
+
+// collectTestChainStats returns the statistics of chainStatsTestBlocks split
+// into windows of the passed size.
+func collectTestChainStats(windowSize int32) *ChainStats {
+	stats := newChainStats(100, 106, windowSize)
+	for _, b := range chainStatsTestBlocks {
+		offset := time.Duration(b.offset) * time.Second
+		stats.add(&StakeBlockNtfn{
+			Height:       b.height,
+			Time:         chainStatsTestStart.Add(offset),
+			ProofOfStake: b.proofOfStake,
+			Minted:       b.minted,
+		}, b.difficulty)
+	}
+	stats.finish()
+	return stats
+}
+
+// exportedWindow returns the passed window without the sums used to compute
+// its averages.
+func exportedWindow(w ChainStatsWindow) ChainStatsWindow {
+	w.posDifficulty = 0
+	w.powDifficulty = 0
+	w.posIntervals = 0
+	w.powIntervals = 0
+	w.posInterval = 0
+	w.powInterval = 0
+	return w
+}
+
+// TestChainStatsWindows ensures blocks are split into windows of the
+// configured size counted from the start height, and that the counts, ratios,
+// averages, and amounts of each window and of the total are computed from the
+// blocks they contain.
+func TestChainStatsWindows(t *testing.T) {
+	t.Parallel()
+
+	at := func(offset int64) time.Time {
+		return chainStatsTestStart.Add(time.Duration(offset) * time.Second)
+	}
+	windows := []ChainStatsWindow{
+		{
+			StartHeight:               100,
+			EndHeight:                 102,
+			StartTime:                 at(0),
+			EndTime:                   at(180),
+			ProofOfStakeBlocks:        2,
+			ProofOfWorkBlocks:         1,
+			ProofOfStakeRatio:         2.0 / 3,
+			AvgProofOfStakeDifficulty: 3,
+			AvgProofOfWorkDifficulty:  10,
+			Minted:                    3 * peercoinCoin,
+			Mined:                     50 * peercoinCoin,
+			AvgProofOfStakeInterval:   120,
+			AvgProofOfWorkInterval:    0,
+		},
+		{
+			// The intervals span windows.
+			StartHeight:               103,
+			EndHeight:                 105,
+			StartTime:                 at(600),
+			EndTime:                   at(900),
+			ProofOfStakeBlocks:        1,
+			ProofOfWorkBlocks:         2,
+			ProofOfStakeRatio:         1.0 / 3,
+			AvgProofOfStakeDifficulty: 6,
+			AvgProofOfWorkDifficulty:  25,
+			Minted:                    3 * peercoinCoin,
+			Mined:                     90 * peercoinCoin,
+			AvgProofOfStakeInterval:   480,
+			AvgProofOfWorkInterval:    450,
+		},
+		{
+			StartHeight:               106,
+			EndHeight:                 106,
+			StartTime:                 at(1000),
+			EndTime:                   at(1000),
+			ProofOfStakeBlocks:        1,
+			ProofOfWorkBlocks:         0,
+			ProofOfStakeRatio:         1,
+			AvgProofOfStakeDifficulty: 8,
+			AvgProofOfWorkDifficulty:  0,
+			Minted:                    4 * peercoinCoin,
+			Mined:                     0,
+			AvgProofOfStakeInterval:   340,
+			AvgProofOfWorkInterval:    0,
+		},
+	}
+	total := ChainStatsWindow{
+		StartHeight:               100,
+		EndHeight:                 106,
+		StartTime:                 at(0),
+		EndTime:                   at(1000),
+		ProofOfStakeBlocks:        4,
+		ProofOfWorkBlocks:         3,
+		ProofOfStakeRatio:         4.0 / 7,
+		AvgProofOfStakeDifficulty: 5,
+		AvgProofOfWorkDifficulty:  20,
+		Minted:                    10 * peercoinCoin,
+		Mined:                     140 * peercoinCoin,
+		AvgProofOfStakeInterval:   940.0 / 3,
+		AvgProofOfWorkInterval:    450,
+	}
+
+	tests := []struct {
+		name       string
+		windowSize int32
+		wantSize   int32
+		windows    []ChainStatsWindow
+	}{
+		{"windows of three blocks", 3, 3, windows},
+		{"single window", 0, 7, []ChainStatsWindow{total}},
+	}
+
+	for _, test := range tests {
+		stats := collectTestChainStats(test.windowSize)
+		if stats.StartHeight != 100 || stats.EndHeight != 106 ||
+			stats.WindowSize != test.wantSize {
+
+			t.Errorf("%s: got heights %d-%d in windows of %d, want "+
+				"100-106 in windows of %d", test.name,
+				stats.StartHeight, stats.EndHeight,
+				stats.WindowSize, test.wantSize)
+		}
+		if len(stats.Windows) != len(test.windows) {
+			t.Errorf("%s: got %d windows, want %d", test.name,
+				len(stats.Windows), len(test.windows))
+			continue
+		}
+		for i, want := range test.windows {
+			if got := exportedWindow(stats.Windows[i]); got != want {
+				t.Errorf("%s: window %d: got %+v, want %+v",
+					test.name, i, got, want)
+			}
+		}
+		if got := exportedWindow(stats.Total); got != total {
+			t.Errorf("%s: total: got %+v, want %+v", test.name, got,
+				total)
+		}
+	}
+}
+
+// TestChainStatsWriteCSV ensures the statistics are written as a header row,
+// one row per window, and a total row.
+func TestChainStatsWriteCSV(t *testing.T) {
+	t.Parallel()
+
+	want := "window,startheight,endheight,starttime,endtime,posblocks," +
+		"powblocks,posratio,avgposdifficulty,avgpowdifficulty,minted," +
+		"mined,avgposinterval,avgpowinterval\n" +
+		"0,100,102,2014-05-13T16:53:20Z,2014-05-13T16:56:20Z,2,1," +
+		"0.6666666666666666,3,10,3000000,50000000,120,0\n" +
+		"1,103,105,2014-05-13T17:03:20Z,2014-05-13T17:08:20Z,1,2," +
+		"0.3333333333333333,6,25,3000000,90000000,480,450\n" +
+		"2,106,106,2014-05-13T17:10:00Z,2014-05-13T17:10:00Z,1,0," +
+		"1,8,0,4000000,0,340,0\n" +
+		"total,100,106,2014-05-13T16:53:20Z,2014-05-13T17:10:00Z,4,3," +
+		"0.5714285714285714,5,20,10000000,140000000,313.3333333333333," +
+		"450\n"
+
+	var buf bytes.Buffer
+	if err := collectTestChainStats(3).WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("got CSV:\n%s\nwant:\n%s", got, want)
+	}
+}
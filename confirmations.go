@@ -0,0 +1,508 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+var (
+	// ErrTxDoubleSpent is an error to describe the condition where a
+	// transaction being waited on can no longer be confirmed because one
+	// or more of its inputs have been spent by another transaction.
+	ErrTxDoubleSpent = errors.New("transaction has been double spent")
+
+	// ErrWaitCanceled is an error to describe the condition where a wait
+	// for transaction confirmations was canceled by the caller before the
+	// transaction reached the requested number of confirmations.
+	ErrWaitCanceled = errors.New("wait for confirmations canceled")
+
+	// ErrTxNotFound is an error to describe the condition where a
+	// transaction being waited on is known to neither the chain server nor
+	// the wallet, so its confirmations can never be resolved.
+	ErrTxNotFound = errors.New("transaction is not known to the chain " +
+		"server or the wallet")
+)
+
+const (
+	// confirmationPollInterval is the amount of time to wait in between
+	// confirmation checks when block notifications are not available.
+	confirmationPollInterval = time.Second * 30
+
+	// confirmationNtfnPollInterval is the amount of time to wait in between
+	// confirmation checks when block notifications are available.  It
+	// guards against missed notifications.
+	confirmationNtfnPollInterval = time.Minute * 5
+
+	// confirmationEventBufferSize is the number of confirmation events
+	// that can be queued before further events are dropped.
+	confirmationEventBufferSize = 20
+)
+
+// ConfirmationEventType enumerates the kinds of events which are delivered
+// while waiting for a transaction to reach a number of confirmations.
+type ConfirmationEventType int
+
+// Constants used to identify the kind of a ConfirmationEvent.
+const (
+	// ConfirmationsChanged indicates the number of confirmations of the
+	// transaction increased.
+	ConfirmationsChanged ConfirmationEventType = iota
+
+	// ConfirmationsDropped indicates the number of confirmations of the
+	// transaction decreased due to a chain reorganization.  This includes
+	// the transaction dropping back into the memory pool or disappearing
+	// entirely.
+	ConfirmationsDropped
+
+	// ConfirmationDoubleSpent indicates one or more of the transaction
+	// inputs were spent by a different transaction.  No further events are
+	// delivered after this one.
+	ConfirmationDoubleSpent
+
+	// ConfirmationsReached indicates the transaction reached the requested
+	// number of confirmations.  No further events are delivered after this
+	// one.
+	ConfirmationsReached
+)
+
+// confirmationEventTypeStrings is a map of confirmation event types back to
+// their constant names for pretty printing.
+var confirmationEventTypeStrings = map[ConfirmationEventType]string{
+	ConfirmationsChanged:    "ConfirmationsChanged",
+	ConfirmationsDropped:    "ConfirmationsDropped",
+	ConfirmationDoubleSpent: "ConfirmationDoubleSpent",
+	ConfirmationsReached:    "ConfirmationsReached",
+}
+
+// String returns the ConfirmationEventType in human-readable form.
+func (t ConfirmationEventType) String() string {
+	if s, ok := confirmationEventTypeStrings[t]; ok {
+		return s
+	}
+	return "Unknown ConfirmationEventType"
+}
+
+// ConfirmationEvent describes a change in the confirmation status of a
+// transaction being waited on.
+type ConfirmationEvent struct {
+	Type          ConfirmationEventType
+	TxHash        wire.ShaHash
+	Confirmations int64
+
+	// BlockHash is the hash of the block the transaction is mined in.  It
+	// is nil when the transaction is not mined.
+	BlockHash *wire.ShaHash
+}
+
+// FutureWaitForConfirmationsResult is a future promise to deliver the result of
+// a WaitForConfirmationsAsync invocation (or an applicable error).  Unlike the
+// futures of single RPC invocations, it also provides a channel of events
+// describing the progress of the wait.
+type FutureWaitForConfirmationsResult struct {
+	events   chan *ConfirmationEvent
+	result   chan *response
+	quit     chan struct{}
+	quitOnce sync.Once
+	final    *ConfirmationEvent
+}
+
+// Events returns a channel on which every change in the confirmation status of
+// the transaction is delivered.  The channel is closed once the wait completes.
+// Events are dropped when the channel buffer is full, so callers which are not
+// interested in the events do not need to drain it.
+func (r *FutureWaitForConfirmationsResult) Events() <-chan *ConfirmationEvent {
+	return r.events
+}
+
+// Cancel stops waiting for the transaction.  Receive returns ErrWaitCanceled
+// unless the wait already completed.
+func (r *FutureWaitForConfirmationsResult) Cancel() {
+	r.quitOnce.Do(func() { close(r.quit) })
+}
+
+// Receive waits for the transaction to reach the requested number of
+// confirmations and returns the final event.  ErrTxDoubleSpent is returned
+// along with the double spend event when the transaction can never confirm.
+func (r *FutureWaitForConfirmationsResult) Receive() (*ConfirmationEvent, error) {
+	_, err := receiveFuture(r.result)
+	return r.final, err
+}
+
+// txConfirmationInfo describes what the chain server or the wallet know about a
+// transaction being waited on.
+type txConfirmationInfo struct {
+	// found is false when neither the chain server nor the wallet know
+	// about the transaction.
+	found bool
+
+	// confs is the number of confirmations of the transaction.  It is
+	// negative when the wallet considers the transaction conflicted.
+	confs int64
+
+	// blockHash is the hash of the block containing the transaction.  It
+	// is nil when the transaction is not mined.
+	blockHash *wire.ShaHash
+
+	// prevOuts are the previous outpoints spent by the transaction and
+	// numOutputs is its number of outputs.  They are only known when the
+	// chain server knows the transaction.
+	prevOuts   []*wire.OutPoint
+	numOutputs int
+}
+
+// txConfirmationLookup houses the requests used to resolve the confirmations
+// of a transaction being waited on.  It is implemented by Client and allows the
+// resolution to be tested without a server.
+type txConfirmationLookup interface {
+	txConfirmations(txHash *wire.ShaHash) (*txConfirmationInfo, error)
+	isDoubleSpent(prevOuts []*wire.OutPoint) (bool, error)
+	minedTxConfirmations(txHash *wire.ShaHash, numOutputs int) (confs int64,
+		blockHash *wire.ShaHash, mined bool, err error)
+}
+
+// txConfirmations returns what the chain server, or the wallet when the chain
+// server does not know the transaction, know about the passed transaction.
+func (c *Client) txConfirmations(txHash *wire.ShaHash) (*txConfirmationInfo, error) {
+	var info txConfirmationInfo
+	var blockHashStr string
+	rawTx, err := c.GetRawTransactionVerbose(txHash)
+	if err == nil {
+		info.confs = int64(rawTx.Confirmations)
+		info.numOutputs = len(rawTx.Vout)
+		blockHashStr = rawTx.BlockHash
+		for _, txIn := range rawTx.Vin {
+			if txIn.IsCoinBase() {
+				continue
+			}
+			prevHash, err := wire.NewShaHashFromStr(txIn.Txid)
+			if err != nil {
+				return nil, err
+			}
+			info.prevOuts = append(info.prevOuts,
+				wire.NewOutPoint(prevHash, txIn.Vout))
+		}
+	} else {
+		// Only fall back to the wallet when the chain server does not
+		// know the transaction, such as when it is mined and the
+		// transaction index is not enabled.
+		if !isNoTxInfoError(err) {
+			return nil, err
+		}
+		walletTx, err := c.GetTransaction(txHash)
+		if err != nil {
+			// The transaction is not found when the wallet does
+			// not know it either or the server has no wallet.
+			if isNoTxInfoError(err) || isMethodNotFoundError(err) {
+				return &info, nil
+			}
+			return nil, err
+		}
+		info.confs = walletTx.Confirmations
+		blockHashStr = walletTx.BlockHash
+	}
+
+	if blockHashStr != "" && info.confs > 0 {
+		info.blockHash, err = wire.NewShaHashFromStr(blockHashStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	info.found = true
+	return &info, nil
+}
+
+// isNoTxInfoError returns whether or not the passed error is the error the
+// server replies with when it has no information about a transaction.
+func isNoTxInfoError(err error) bool {
+	jerr, ok := err.(*btcjson.RPCError)
+	return ok && jerr.Code == btcjson.ErrRPCNoTxInfo
+}
+
+// isMethodNotFoundError returns whether or not the passed error is the error
+// the server replies with when it does not support a method, such as the
+// wallet methods of a server without a wallet.
+func isMethodNotFoundError(err error) bool {
+	jerr, ok := err.(*btcjson.RPCError)
+	return ok && jerr.Code == btcjson.ErrRPCMethodNotFound.Code
+}
+
+// isDoubleSpent returns whether or not any of the passed previous outpoints
+// have been spent, either in the main chain or the memory pool.  For a
+// transaction the chain server no longer knows, spent inputs either mean it was
+// double spent or that it was mined and the transaction index is not enabled,
+// so minedTxConfirmations must rule out the latter.
+func (c *Client) isDoubleSpent(prevOuts []*wire.OutPoint) (bool, error) {
+	for _, op := range prevOuts {
+		txOut, err := c.GetTxOut(&op.Hash, op.Index, true)
+		if err != nil {
+			return false, err
+		}
+		if txOut == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// minedTxConfirmations returns the number of confirmations and the hash of the
+// block containing the passed transaction with the passed number of outputs by
+// looking up its outputs in the main chain, which does not require the
+// transaction index.  The returned mined flag is false when none of its outputs
+// are unspent outputs of the main chain.
+func (c *Client) minedTxConfirmations(txHash *wire.ShaHash, numOutputs int) (int64,
+	*wire.ShaHash, bool, error) {
+
+	for i := 0; i < numOutputs; i++ {
+		txOut, err := c.GetTxOut(txHash, uint32(i), false)
+		if err != nil {
+			return 0, nil, false, err
+		}
+		if txOut == nil || txOut.Confirmations <= 0 {
+			continue
+		}
+
+		// The block containing the transaction is the one the number
+		// of confirmations leads back to from the best block the output
+		// was looked up at.
+		bestHash, err := wire.NewShaHashFromStr(txOut.BestBlock)
+		if err != nil {
+			return 0, nil, false, err
+		}
+		bestBlock, err := c.GetBlockVerbose(bestHash, false)
+		if err != nil {
+			return 0, nil, false, err
+		}
+		confs := txOut.Confirmations
+		blockHash, err := c.GetBlockHash(bestBlock.Height - confs + 1)
+		if err != nil {
+			return 0, nil, false, err
+		}
+		return confs, blockHash, true, nil
+	}
+	return 0, nil, false, nil
+}
+
+// txConfirmationStatus describes the confirmation status of a transaction
+// being waited on as resolved by a txConfirmationResolver.
+type txConfirmationStatus struct {
+	confs       int64
+	blockHash   *wire.ShaHash
+	doubleSpent bool
+}
+
+// txConfirmationResolver resolves the confirmation status of a transaction each
+// time it is checked.  It remembers the inputs and the number of outputs of the
+// transaction from the checks where the chain server knows it, so the status
+// can still be resolved once it does not.
+type txConfirmationResolver struct {
+	lookup     txConfirmationLookup
+	txHash     wire.ShaHash
+	prevOuts   []*wire.OutPoint
+	numOutputs int
+}
+
+// resolve returns the current confirmation status of the transaction.
+// ErrTxNotFound is returned when the transaction is not known and its inputs
+// were never seen, such as when it is mined, the transaction index is not
+// enabled, and the wallet does not know it.
+func (r *txConfirmationResolver) resolve() (*txConfirmationStatus, error) {
+	info, err := r.lookup.txConfirmations(&r.txHash)
+	if err != nil {
+		return nil, err
+	}
+	if len(info.prevOuts) > 0 {
+		r.prevOuts = info.prevOuts
+		r.numOutputs = info.numOutputs
+	}
+
+	// The wallet reports conflicted transactions with a negative number
+	// of confirmations.
+	if info.found {
+		if info.confs < 0 {
+			return &txConfirmationStatus{doubleSpent: true}, nil
+		}
+		return &txConfirmationStatus{
+			confs:     info.confs,
+			blockHash: info.blockHash,
+		}, nil
+	}
+	if len(r.prevOuts) == 0 {
+		return nil, ErrTxNotFound
+	}
+
+	// The transaction is neither mined nor in the memory pool as far as
+	// its inputs tell when they are unspent.
+	spent, err := r.lookup.isDoubleSpent(r.prevOuts)
+	if err != nil {
+		return nil, err
+	}
+	if !spent {
+		return &txConfirmationStatus{}, nil
+	}
+
+	// Spent inputs also mean the transaction itself was mined when any of
+	// its outputs exist in the main chain.
+	confs, blockHash, mined, err := r.lookup.minedTxConfirmations(
+		&r.txHash, r.numOutputs)
+	if err != nil {
+		return nil, err
+	}
+	if mined {
+		return &txConfirmationStatus{confs: confs, blockHash: blockHash},
+			nil
+	}
+	return &txConfirmationStatus{doubleSpent: true}, nil
+}
+
+// blockNtfnsAvailable returns whether or not the client will be notified about
+// connected blocks, either by the RPC server or by the notification poller.
+func (c *Client) blockNtfnsAvailable() bool {
+	if c.ntfnHandlers == nil {
+		return false
+	}
+
+	c.ntfnState.Lock()
+	defer c.ntfnState.Unlock()
+	return c.ntfnState.notifyBlocks
+}
+
+// waitForConfirmations is the main loop of a wait started by
+// WaitForConfirmationsAsync.  It must be run as a goroutine.
+func (c *Client) waitForConfirmations(txHash wire.ShaHash, numConfs int64,
+	f *FutureWaitForConfirmationsResult) {
+
+	defer close(f.events)
+
+	// Wake up on connected and disconnected blocks when the client is
+	// registered for them, and fall back to polling otherwise.
+	var blockSignal chan struct{}
+	pollInterval := confirmationPollInterval
+	if c.blockNtfnsAvailable() {
+		blockSignal = c.chainState.subscribe()
+		defer c.chainState.unsubscribe(blockSignal)
+		pollInterval = confirmationNtfnPollInterval
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	sendEvent := func(event *ConfirmationEvent) {
+		select {
+		case f.events <- event:
+		default:
+			log.Debugf("Dropping confirmation event %v for %v",
+				event.Type, event.TxHash)
+		}
+	}
+
+	var lastConfs int64
+	resolver := txConfirmationResolver{lookup: c, txHash: txHash}
+	for {
+		status, err := resolver.resolve()
+		if err != nil {
+			f.result <- &response{err: err}
+			return
+		}
+		if status.doubleSpent {
+			f.final = &ConfirmationEvent{
+				Type:   ConfirmationDoubleSpent,
+				TxHash: txHash,
+			}
+			sendEvent(f.final)
+			f.result <- &response{err: ErrTxDoubleSpent}
+			return
+		}
+		confs, blockHash := status.confs, status.blockHash
+
+		switch {
+		case confs >= numConfs:
+			f.final = &ConfirmationEvent{
+				Type:          ConfirmationsReached,
+				TxHash:        txHash,
+				Confirmations: confs,
+				BlockHash:     blockHash,
+			}
+			sendEvent(f.final)
+			f.result <- &response{}
+			return
+
+		case confs > lastConfs:
+			sendEvent(&ConfirmationEvent{
+				Type:          ConfirmationsChanged,
+				TxHash:        txHash,
+				Confirmations: confs,
+				BlockHash:     blockHash,
+			})
+
+		case confs < lastConfs:
+			sendEvent(&ConfirmationEvent{
+				Type:          ConfirmationsDropped,
+				TxHash:        txHash,
+				Confirmations: confs,
+				BlockHash:     blockHash,
+			})
+		}
+		lastConfs = confs
+
+		select {
+		case <-blockSignal:
+		case <-ticker.C:
+		case <-f.quit:
+			f.result <- &response{err: ErrWaitCanceled}
+			return
+		case <-c.shutdown:
+			f.result <- &response{err: ErrClientShutdown}
+			return
+		}
+	}
+}
+
+// WaitForConfirmationsAsync returns an instance of a type that can be used to
+// get the result of waiting for a transaction to reach a number of
+// confirmations at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See WaitForConfirmations for the blocking version and more details.
+func (c *Client) WaitForConfirmationsAsync(txHash *wire.ShaHash, numConfs int64) *FutureWaitForConfirmationsResult {
+	f := &FutureWaitForConfirmationsResult{
+		events: make(chan *ConfirmationEvent, confirmationEventBufferSize),
+		result: make(chan *response, 1),
+		quit:   make(chan struct{}),
+	}
+	go c.waitForConfirmations(*txHash, numConfs, f)
+	return f
+}
+
+// WaitForConfirmations blocks until the passed transaction reaches the given
+// number of confirmations and returns the final event, which includes the hash
+// of the block the transaction is mined in.
+//
+// Confirmations are rechecked each time a block is connected or disconnected
+// when the client is registered for block notifications via NotifyBlocks.
+// Otherwise, such as when running in HTTP POST mode without the
+// NtfnPollInterval config option, the confirmations are polled periodically.
+// The confirmations are looked up with GetRawTransactionVerbose, falling back
+// to the wallet with GetTransaction when the chain server does not know the
+// transaction.  When neither knows it, such as when it is mined and the
+// transaction index is not enabled, its outputs are looked up with GetTxOut
+// once its inputs are spent.
+//
+// ErrTxDoubleSpent is returned if the transaction disappears, any of its inputs
+// have been spent, and none of its outputs are in the main chain, or the wallet
+// reports the transaction as conflicted.  ErrTxNotFound is returned if neither
+// the chain server nor the wallet know the transaction and its inputs are not
+// known either.
+//
+// See WaitForConfirmationsAsync to also receive events as the number of
+// confirmations changes, including when it drops due to a chain
+// reorganization.
+func (c *Client) WaitForConfirmations(txHash *wire.ShaHash, numConfs int64) (*ConfirmationEvent, error) {
+	return c.WaitForConfirmationsAsync(txHash, numConfs).Receive()
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"testing"
+
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// fakeTxConfirmationLookup is a txConfirmationLookup which replies with the
+// state of a single step of a test.
+type fakeTxConfirmationLookup struct {
+	info       txConfirmationInfo
+	inputSpent bool
+	minedConfs int64
+	minedBlock *wire.ShaHash
+}
+
+func (l *fakeTxConfirmationLookup) txConfirmations(txHash *wire.ShaHash) (*txConfirmationInfo, error) {
+	info := l.info
+	return &info, nil
+}
+
+func (l *fakeTxConfirmationLookup) isDoubleSpent(prevOuts []*wire.OutPoint) (bool, error) {
+	return l.inputSpent, nil
+}
+
+func (l *fakeTxConfirmationLookup) minedTxConfirmations(txHash *wire.ShaHash,
+	numOutputs int) (int64, *wire.ShaHash, bool, error) {
+
+	if l.minedBlock == nil || numOutputs == 0 {
+		return 0, nil, false, nil
+	}
+	return l.minedConfs, l.minedBlock, true, nil
+}
+
+// TestTxConfirmationResolver ensures the confirmation status of a transaction
+// is resolved from the chain server, the wallet, and the inputs and outputs of
+// the transaction across a sequence of checks.
+func TestTxConfirmationResolver(t *testing.T) {
+	t.Parallel()
+
+	prevOuts := []*wire.OutPoint{wire.NewOutPoint(&wire.ShaHash{1}, 0)}
+	block := &wire.ShaHash{2}
+
+	type step struct {
+		lookup      fakeTxConfirmationLookup
+		confs       int64
+		blockHash   *wire.ShaHash
+		doubleSpent bool
+		notFound    bool
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "mempool then mined without txindex",
+			steps: []step{
+				{
+					lookup: fakeTxConfirmationLookup{
+						info: txConfirmationInfo{
+							found:      true,
+							prevOuts:   prevOuts,
+							numOutputs: 2,
+						},
+					},
+				},
+				{
+					lookup: fakeTxConfirmationLookup{
+						inputSpent: true,
+						minedConfs: 1,
+						minedBlock: block,
+					},
+					confs:     1,
+					blockHash: block,
+				},
+			},
+		},
+		{
+			name: "mempool then double spent",
+			steps: []step{
+				{
+					lookup: fakeTxConfirmationLookup{
+						info: txConfirmationInfo{
+							found:      true,
+							prevOuts:   prevOuts,
+							numOutputs: 1,
+						},
+					},
+				},
+				{
+					lookup: fakeTxConfirmationLookup{
+						inputSpent: true,
+					},
+					doubleSpent: true,
+				},
+			},
+		},
+		{
+			name: "mempool then dropped",
+			steps: []step{
+				{
+					lookup: fakeTxConfirmationLookup{
+						info: txConfirmationInfo{
+							found:      true,
+							prevOuts:   prevOuts,
+							numOutputs: 1,
+						},
+					},
+				},
+				{},
+			},
+		},
+		{
+			name: "mined and known to the wallet",
+			steps: []step{
+				{
+					lookup: fakeTxConfirmationLookup{
+						info: txConfirmationInfo{
+							found:     true,
+							confs:     3,
+							blockHash: block,
+						},
+					},
+					confs:     3,
+					blockHash: block,
+				},
+			},
+		},
+		{
+			name: "conflicted in the wallet",
+			steps: []step{
+				{
+					lookup: fakeTxConfirmationLookup{
+						info: txConfirmationInfo{
+							found: true,
+							confs: -1,
+						},
+					},
+					doubleSpent: true,
+				},
+			},
+		},
+		{
+			name: "never seen",
+			steps: []step{
+				{notFound: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		lookup := new(fakeTxConfirmationLookup)
+		r := txConfirmationResolver{lookup: lookup}
+		for i, step := range test.steps {
+			*lookup = step.lookup
+			status, err := r.resolve()
+			if step.notFound {
+				if err != ErrTxNotFound {
+					t.Errorf("%s: step %d: got error %v, want "+
+						"%v", test.name, i, err,
+						ErrTxNotFound)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: step %d: unexpected error %v",
+					test.name, i, err)
+				continue
+			}
+			if status.doubleSpent != step.doubleSpent {
+				t.Errorf("%s: step %d: got double spent %v, "+
+					"want %v", test.name, i,
+					status.doubleSpent, step.doubleSpent)
+			}
+			if status.confs != step.confs ||
+				status.blockHash != step.blockHash {
+
+				t.Errorf("%s: step %d: got %d confirmations "+
+					"in block %v, want %d in block %v",
+					test.name, i, status.confs,
+					status.blockHash, step.confs,
+					step.blockHash)
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"sync"
+
+	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// ChainTxDelivery describes how a recvtx or redeemingtx notification relates to
+// the previous notifications of the same kind for the same transaction.  It is
+// only tracked when the NtfnDedupWindow config option is set.
+type ChainTxDelivery int
+
+// Constants used to identify the kind of a ChainTxDelivery.
+const (
+	// TxDeliveryUntracked indicates previous notifications are not tracked
+	// since the NtfnDedupWindow config option is not set.
+	TxDeliveryUntracked ChainTxDelivery = iota
+
+	// TxDeliveryFirstSeen indicates the transaction was not notified
+	// before.
+	TxDeliveryFirstSeen
+
+	// TxDeliveryConfirmed indicates the transaction was notified before
+	// while in the memory pool and is now mined in a block.
+	TxDeliveryConfirmed
+
+	// TxDeliveryReorganized indicates the transaction was notified before
+	// as mined in a different block, which is no longer part of the main
+	// chain.
+	TxDeliveryReorganized
+)
+
+// chainTxDeliveryStrings is a map of chain transaction deliveries back to their
+// constant names for pretty printing.
+var chainTxDeliveryStrings = map[ChainTxDelivery]string{
+	TxDeliveryUntracked:   "TxDeliveryUntracked",
+	TxDeliveryFirstSeen:   "TxDeliveryFirstSeen",
+	TxDeliveryConfirmed:   "TxDeliveryConfirmed",
+	TxDeliveryReorganized: "TxDeliveryReorganized",
+}
+
+// String returns the ChainTxDelivery in human-readable form.
+func (d ChainTxDelivery) String() string {
+	if s, ok := chainTxDeliveryStrings[d]; ok {
+		return s
+	}
+	return "Unknown ChainTxDelivery"
+}
+
+// chainTxKey identifies the notifications of one kind for one transaction.
+type chainTxKey struct {
+	method string
+	txHash wire.ShaHash
+}
+
+// chainTxSeen houses the notifications delivered for a transaction.
+type chainTxSeen struct {
+	// mempool is set when the last delivery was for the memory pool, and
+	// seenMempool once there was any delivery for the memory pool.  The
+	// mempool flag is reset by a delivery for a block, so the transaction
+	// is delivered again when it returns to the memory pool after the
+	// block is disconnected, regardless of whether the block disconnected
+	// notification arrives before or after it.
+	mempool     bool
+	seenMempool bool
+
+	// blocks holds the hashes of the blocks the transaction was delivered
+	// as mined in which have not been disconnected.  reorganized is set
+	// once the transaction was mined in more than one block, or in a block
+	// which was disconnected.
+	blocks      []string
+	reorganized bool
+}
+
+// chainTxDedup tracks the recvtx and redeemingtx notifications delivered for
+// the most recently notified transactions in order to drop duplicates, such as
+// those caused by overlapping rescans and rescans after a reconnect.
+type chainTxDedup struct {
+	sync.Mutex
+	seen   map[chainTxKey]*chainTxSeen
+	order  map[string][]chainTxKey // notification method to keys
+	window int
+}
+
+// newChainTxDedup returns a new deduplicator which remembers the notifications
+// for up to window transactions of each kind.
+func newChainTxDedup(window int) *chainTxDedup {
+	return &chainTxDedup{
+		seen:   make(map[chainTxKey]*chainTxSeen),
+		order:  make(map[string][]chainTxKey),
+		window: window,
+	}
+}
+
+// check records a notification of the passed kind for the passed transaction
+// and block, which is nil for transactions in the memory pool.  It returns how
+// the notification relates to the previous ones and whether or not it is a
+// duplicate which should be dropped.
+//
+// This function is safe for concurrent access.
+func (d *chainTxDedup) check(method string, txHash *wire.ShaHash,
+	block *btcjson.BlockDetails) (ChainTxDelivery, bool) {
+
+	d.Lock()
+	defer d.Unlock()
+
+	key := chainTxKey{method: method, txHash: *txHash}
+	seen, ok := d.seen[key]
+	if !ok {
+		order := d.order[method]
+		if len(order) >= d.window {
+			delete(d.seen, order[0])
+			order = order[1:]
+		}
+		seen = &chainTxSeen{}
+		d.seen[key] = seen
+		d.order[method] = append(order, key)
+	}
+
+	if block == nil {
+		if seen.mempool {
+			return TxDeliveryFirstSeen, true
+		}
+		seen.mempool = true
+		seen.seenMempool = true
+		if len(seen.blocks) > 0 || seen.reorganized {
+			// The transaction is back in the memory pool after
+			// the block it was mined in was disconnected.
+			seen.reorganized = true
+			return TxDeliveryReorganized, false
+		}
+		return TxDeliveryFirstSeen, false
+	}
+
+	for _, blockHash := range seen.blocks {
+		if blockHash == block.Hash {
+			return TxDeliveryFirstSeen, true
+		}
+	}
+	seen.mempool = false
+	seen.blocks = append(seen.blocks, block.Hash)
+	switch {
+	case len(seen.blocks) > 1 || seen.reorganized:
+		seen.reorganized = true
+		return TxDeliveryReorganized, false
+	case seen.seenMempool:
+		return TxDeliveryConfirmed, false
+	}
+	return TxDeliveryFirstSeen, false
+}
+
+// disconnectBlock forgets the deliveries of the transactions mined in the block
+// with the passed hash, which was disconnected from the main chain, so they are
+// delivered again when they are mined in the same block once it is connected
+// again, and are reported as reorganized.
+//
+// This function is safe for concurrent access.
+func (d *chainTxDedup) disconnectBlock(blockHash string) {
+	d.Lock()
+	defer d.Unlock()
+
+	for _, seen := range d.seen {
+		for i, hash := range seen.blocks {
+			if hash != blockHash {
+				continue
+			}
+			seen.blocks = append(seen.blocks[:i], seen.blocks[i+1:]...)
+			seen.reorganized = true
+			break
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"testing"
+
+	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// TestChainTxDedup ensures duplicate recvtx and redeemingtx notifications are
+// dropped, and the remaining ones are classified by how they relate to the
+// previous notifications for the same transaction.  Steps without a method
+// disconnect their block.
+func TestChainTxDedup(t *testing.T) {
+	t.Parallel()
+
+	type step struct {
+		method string
+		tx     byte   // first byte of the transaction hash
+		block  string // block hash, or empty for the memory pool
+		want   ChainTxDelivery
+		dup    bool
+	}
+	recvTx := btcjson.RecvTxNtfnMethod
+	redeemingTx := btcjson.RedeemingTxNtfnMethod
+	tests := []struct {
+		name   string
+		window int
+		steps  []step
+	}{
+		{
+			name:   "mempool then block",
+			window: 10,
+			steps: []step{
+				{recvTx, 1, "", TxDeliveryFirstSeen, false},
+				{recvTx, 1, "", TxDeliveryFirstSeen, true},
+				{recvTx, 1, "a", TxDeliveryConfirmed, false},
+				{recvTx, 1, "a", TxDeliveryFirstSeen, true},
+			},
+		},
+		{
+			name:   "rescan of mined transaction",
+			window: 10,
+			steps: []step{
+				{recvTx, 1, "a", TxDeliveryFirstSeen, false},
+				{recvTx, 1, "a", TxDeliveryFirstSeen, true},
+			},
+		},
+		{
+			name:   "reorganized into another block",
+			window: 10,
+			steps: []step{
+				{recvTx, 1, "a", TxDeliveryFirstSeen, false},
+				{recvTx, 1, "b", TxDeliveryReorganized, false},
+				{recvTx, 1, "a", TxDeliveryFirstSeen, true},
+			},
+		},
+		{
+			name:   "back in the memory pool",
+			window: 10,
+			steps: []step{
+				{recvTx, 1, "a", TxDeliveryFirstSeen, false},
+				{recvTx, 1, "", TxDeliveryReorganized, false},
+				{recvTx, 1, "", TxDeliveryFirstSeen, true},
+			},
+		},
+		{
+			name:   "back in the memory pool after confirmation",
+			window: 10,
+			steps: []step{
+				{recvTx, 1, "", TxDeliveryFirstSeen, false},
+				{recvTx, 1, "a", TxDeliveryConfirmed, false},
+				{recvTx, 1, "", TxDeliveryReorganized, false},
+				{"", 0, "a", 0, false},
+				{recvTx, 1, "", TxDeliveryFirstSeen, true},
+				{recvTx, 1, "b", TxDeliveryReorganized, false},
+			},
+		},
+		{
+			name:   "disconnected before back in the memory pool",
+			window: 10,
+			steps: []step{
+				{recvTx, 1, "", TxDeliveryFirstSeen, false},
+				{recvTx, 1, "a", TxDeliveryConfirmed, false},
+				{"", 0, "a", 0, false},
+				{recvTx, 1, "", TxDeliveryReorganized, false},
+				{recvTx, 1, "", TxDeliveryFirstSeen, true},
+			},
+		},
+		{
+			name:   "block connected again",
+			window: 10,
+			steps: []step{
+				{recvTx, 1, "a", TxDeliveryFirstSeen, false},
+				{redeemingTx, 2, "a", TxDeliveryFirstSeen, false},
+				{recvTx, 3, "b", TxDeliveryFirstSeen, false},
+				{"", 0, "a", 0, false},
+				{recvTx, 1, "a", TxDeliveryReorganized, false},
+				{redeemingTx, 2, "a", TxDeliveryReorganized, false},
+				{recvTx, 3, "b", TxDeliveryFirstSeen, true},
+			},
+		},
+		{
+			name:   "kinds tracked separately",
+			window: 10,
+			steps: []step{
+				{recvTx, 1, "a", TxDeliveryFirstSeen, false},
+				{redeemingTx, 1, "a", TxDeliveryFirstSeen, false},
+				{redeemingTx, 1, "a", TxDeliveryFirstSeen, true},
+			},
+		},
+		{
+			name:   "evicted beyond window",
+			window: 2,
+			steps: []step{
+				{recvTx, 1, "a", TxDeliveryFirstSeen, false},
+				{recvTx, 2, "a", TxDeliveryFirstSeen, false},
+				{recvTx, 3, "a", TxDeliveryFirstSeen, false},
+				{recvTx, 1, "a", TxDeliveryFirstSeen, false},
+				{recvTx, 3, "a", TxDeliveryFirstSeen, true},
+			},
+		},
+		{
+			name:   "window per kind",
+			window: 1,
+			steps: []step{
+				{recvTx, 1, "a", TxDeliveryFirstSeen, false},
+				{redeemingTx, 2, "a", TxDeliveryFirstSeen, false},
+				{recvTx, 1, "a", TxDeliveryFirstSeen, true},
+				{redeemingTx, 2, "a", TxDeliveryFirstSeen, true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		d := newChainTxDedup(test.window)
+		for i, step := range test.steps {
+			if step.method == "" {
+				d.disconnectBlock(step.block)
+				continue
+			}
+			txHash := wire.ShaHash{step.tx}
+			var block *btcjson.BlockDetails
+			if step.block != "" {
+				block = &btcjson.BlockDetails{Hash: step.block}
+			}
+			got, dup := d.check(step.method, &txHash, block)
+			if got != step.want || dup != step.dup {
+				t.Errorf("%s: step %d: got %v (duplicate %v), "+
+					"want %v (duplicate %v)", test.name, i,
+					got, dup, step.want, step.dup)
+			}
+		}
+		for method, order := range d.order {
+			if len(order) > test.window {
+				t.Errorf("%s: tracked %d %s transactions "+
+					"beyond window of %d", test.name,
+					len(order), method, test.window)
+			}
+		}
+		if len(d.seen) > 2*test.window {
+			t.Errorf("%s: tracked %d transactions beyond window "+
+				"of %d", test.name, len(d.seen), test.window)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"math/big"
+
+	"github.com/ppcsuite/ppcd/blockchain"
+)
+
+// difficultyOneBits is the compact representation of the target with a
+// difficulty of one, which is the reference target difficulties are expressed
+// against by the RPC server.
+const difficultyOneBits = 0x1d00ffff
+
+// difficultyOneTarget is the target with a difficulty of one.
+var difficultyOneTarget = blockchain.CompactToBig(difficultyOneBits)
+
+// CompactToTarget converts the passed compact representation of a target, as
+// found in the bits field of a block header, to the target it represents.
+func CompactToTarget(bits uint32) *big.Int {
+	return blockchain.CompactToBig(bits)
+}
+
+// TargetToCompact converts the passed target to its compact representation.
+// Precision is lost since only the three most significant bytes of the target
+// are kept.
+func TargetToCompact(target *big.Int) uint32 {
+	return blockchain.BigToCompact(target)
+}
+
+// TargetToDifficulty returns the difficulty of the passed target, which is the
+// ratio of the target with a difficulty of one to it.  Zero is returned for
+// targets which are not positive.
+func TargetToDifficulty(target *big.Int) float64 {
+	if target.Sign() <= 0 {
+		return 0
+	}
+	difficulty, _ := new(big.Rat).SetFrac(difficultyOneTarget,
+		target).Float64()
+	return difficulty
+}
+
+// DifficultyToTarget returns the target with the passed difficulty.  Nil is
+// returned for difficulties which are not positive.
+func DifficultyToTarget(difficulty float64) *big.Int {
+	if difficulty <= 0 {
+		return nil
+	}
+	ratio := new(big.Rat).SetFloat64(difficulty)
+	if ratio == nil {
+		return nil
+	}
+	target := new(big.Rat).SetInt(difficultyOneTarget)
+	target.Quo(target, ratio)
+
+	// Truncate the target to an integer.
+	return new(big.Int).Quo(target.Num(), target.Denom())
+}
+
+// CompactToDifficulty returns the difficulty of the target with the passed
+// compact representation.
+func CompactToDifficulty(bits uint32) float64 {
+	return TargetToDifficulty(CompactToTarget(bits))
+}
+
+// DifficultyToCompact returns the compact representation of the target with
+// the passed difficulty.  The target is rounded to the nearest compact
+// representation, so the difficulty of a compact target converts back to the
+// same compact target even though the difficulty is not exact.  Zero is
+// returned for difficulties which are not positive.
+func DifficultyToCompact(difficulty float64) uint32 {
+	target := DifficultyToTarget(difficulty)
+	if target == nil {
+		return 0
+	}
+
+	// Add half of the unit of the least significant byte kept by the
+	// compact representation, so the bytes it drops round the target
+	// instead of truncating it.
+	if size := uint((target.BitLen() + 7) / 8); size > 3 {
+		half := new(big.Int).Lsh(big.NewInt(1), 8*(size-3)-1)
+		target.Add(target, half)
+	}
+	return TargetToCompact(target)
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"math/big"
+	"testing"
+)
+
+// newTestTarget returns the passed mantissa shifted left by the passed number
+// of bits.
+func newTestTarget(mantissa int64, shift uint) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(mantissa), shift)
+}
+
+// TestDifficultyConversions ensures compact targets are converted to the
+// expected targets and difficulties, and that the difficulties of compact
+// targets convert back to the same compact targets.
+func TestDifficultyConversions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		bits       uint32
+		target     *big.Int
+		difficulty float64
+		compact    uint32 // compact representation of target
+	}{
+		{
+			name:       "difficulty one",
+			bits:       0x1d00ffff,
+			target:     newTestTarget(0xffff, 208),
+			difficulty: 1,
+			compact:    0x1d00ffff,
+		},
+		{
+			name:       "peercoin proof-of-stake limit",
+			bits:       0x1e00ffff,
+			target:     newTestTarget(0xffff, 216),
+			difficulty: 0.00390625,
+			compact:    0x1e00ffff,
+		},
+		{
+			name:       "inexact difficulty",
+			bits:       0x1c0fffff,
+			target:     newTestTarget(0x0fffff, 200),
+			difficulty: 15.999771117945784,
+			compact:    0x1c0fffff,
+		},
+		{
+			name:       "bitcoin wiki example",
+			bits:       0x1b0404cb,
+			target:     newTestTarget(0x0404cb, 192),
+			difficulty: 16307.420938523983,
+			compact:    0x1b0404cb,
+		},
+		{
+			name:       "negative",
+			bits:       0x04923456,
+			target:     newTestTarget(-0x123456, 8),
+			difficulty: 0,
+			compact:    0x04923456,
+		},
+		{
+			name:       "negative with small exponent",
+			bits:       0x01fedcba,
+			target:     big.NewInt(-0x7e),
+			difficulty: 0,
+			compact:    0x01fe0000,
+		},
+		{
+			name:       "overflow",
+			bits:       0xff123456,
+			target:     newTestTarget(0x123456, 2016),
+			difficulty: 0,
+			compact:    0xff123456,
+		},
+	}
+
+	for _, test := range tests {
+		target := CompactToTarget(test.bits)
+		if target.Cmp(test.target) != 0 {
+			t.Errorf("%s: CompactToTarget: got %x, want %x",
+				test.name, target, test.target)
+			continue
+		}
+		if compact := TargetToCompact(target); compact != test.compact {
+			t.Errorf("%s: TargetToCompact: got %08x, want %08x",
+				test.name, compact, test.compact)
+		}
+		difficulty := TargetToDifficulty(target)
+		if difficulty != test.difficulty {
+			t.Errorf("%s: TargetToDifficulty: got %v, want %v",
+				test.name, difficulty, test.difficulty)
+		}
+		if d := CompactToDifficulty(test.bits); d != difficulty {
+			t.Errorf("%s: CompactToDifficulty: got %v, want %v",
+				test.name, d, difficulty)
+		}
+
+		// Only positive difficulties convert back to targets.
+		if difficulty == 0 {
+			if target := DifficultyToTarget(difficulty); target != nil {
+				t.Errorf("%s: DifficultyToTarget: got %x, want "+
+					"nil", test.name, target)
+			}
+			if compact := DifficultyToCompact(difficulty); compact != 0 {
+				t.Errorf("%s: DifficultyToCompact: got %08x, "+
+					"want 0", test.name, compact)
+			}
+			continue
+		}
+		if compact := DifficultyToCompact(difficulty); compact != test.bits {
+			t.Errorf("%s: DifficultyToCompact: got %08x, want %08x",
+				test.name, compact, test.bits)
+		}
+		if got := TargetToDifficulty(DifficultyToTarget(difficulty)); got != difficulty {
+			t.Errorf("%s: DifficultyToTarget: got difficulty %v, "+
+				"want %v", test.name, got, difficulty)
+		}
+	}
+}
+
+// TestDifficultyToTargetExact ensures difficulties which divide the target with
+// a difficulty of one exactly are converted to exact targets.
+func TestDifficultyToTargetExact(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		difficulty float64
+		target     *big.Int
+	}{
+		{1, newTestTarget(0xffff, 208)},
+		{256, newTestTarget(0xffff, 200)},
+		{0.5, newTestTarget(0xffff, 209)},
+		{0xffff, newTestTarget(1, 208)},
+	}
+
+	for _, test := range tests {
+		target := DifficultyToTarget(test.difficulty)
+		if target == nil || target.Cmp(test.target) != 0 {
+			t.Errorf("DifficultyToTarget(%v): got %x, want %x",
+				test.difficulty, target, test.target)
+		}
+	}
+}
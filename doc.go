@@ -63,10 +63,24 @@ The first important part of notifications is to realize that they will only
 work when connected via websockets.  This should intuitively make sense
 because HTTP POST mode does not keep a connection open!
 
+The block connected, block disconnected, and transaction accepted
+notifications can be emulated in HTTP POST mode by setting the NtfnPollInterval
+field of the connection config.  The client then polls the RPC server for
+changes to the best chain and memory pool at that interval and delivers the
+same notifications to the handlers, so the NotifyBlocks and
+NotifyNewTransactions registrations work regardless of the transport.
+
 All notifications provided by btcd require registration to opt-in.  For example,
 if you want to be notified when funds are received by a set of addresses, you
 register the addresses via the NotifyReceived (or NotifyReceivedAsync) function.
 
+The registered notifications only live in memory, but they can be exported with
+ExportNotificationState and registered again after a restart with
+ImportNotificationState.  Alternatively, setting the NtfnStateStore field of the
+connection config, for example to a store returned by
+NewFileNotificationStateStore, keeps a saved copy current automatically which
+RestoreNotificationState registers again.
+
 Notification Handlers
 
 Notifications are exposed by the client through the use of callback handlers
@@ -93,6 +107,13 @@ notifications are automatically re-registered and any in-flight commands are
 re-issued.  This means from the caller's perspective, the request simply takes
 longer to complete.
 
+Notifications that occur while the client is disconnected are not delivered by
+the server.  Setting the ReplayMissedBlocks flag in the connection config
+instructs the client to remember the most recent blocks it was notified about
+and, once reconnected, deliver block disconnected and block connected
+notifications for the blocks it missed.  The RescanMissedBlocks flag may also be
+set to rescan the missed blocks for the registered addresses and outpoints.
+
 The caller may invoke the Shutdown method on the client to force the client
 to cease reconnect attempts and return ErrClientShutdown for all outstanding
 commands.
@@ -134,7 +155,9 @@ ErrInvalidEndpoint, ErrClientDisconnect, or ErrClientShutdown.
 
 NOTE: The ErrClientDisconnect will not be returned unless the
 DisableAutoReconnect flag is set since the client automatically handles
-reconnect by default as previously described.
+reconnect by default as previously described.  The exception is Rescan, which
+is not reissued on reconnect and returns ErrClientDisconnect when the client
+disconnects before it finishes.  RescanRange resumes such rescans instead.
 
 The second category of errors typically indicates a programmer error and as such
 the type can vary, but usually will be best handled by simply showing/logging
@@ -0,0 +1,790 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/btcsuite/websocket"
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/txscript"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+const (
+	// fanoutSendBufferSize is the number of notifications which can be
+	// queued for a fan-out subscriber before it is disconnected as too
+	// slow.
+	fanoutSendBufferSize = 1000
+
+	// fanoutMaxRequestSize is the maximum size of a request read from a
+	// fan-out subscriber.
+	fanoutMaxRequestSize = 1 << 20
+)
+
+// fanoutSubscriber houses the state of a downstream websocket connection to a
+// FanoutServer.
+type fanoutSubscriber struct {
+	conn     *websocket.Conn
+	send     chan []byte
+	quit     chan struct{}
+	quitOnce sync.Once
+
+	mtx          sync.Mutex
+	blocks       bool
+	newTx        bool
+	newTxVerbose bool
+	addrs        map[string]struct{}
+
+	// outpoints maps the outpoints watched for the subscriber to whether
+	// the subscriber registered them with notifyspent, in which case they
+	// hold a reference to the upstream registration, as opposed to being
+	// added when an output paying to one of its addresses was received.
+	outpoints map[wire.OutPoint]bool
+}
+
+// disconnect closes the connection of the subscriber.
+//
+// This function is safe for concurrent access.
+func (s *fanoutSubscriber) disconnect() {
+	s.quitOnce.Do(func() {
+		close(s.quit)
+		s.conn.Close()
+	})
+}
+
+// queue queues the passed message to be sent to the subscriber without
+// blocking.  The subscriber is disconnected when its send buffer is full.
+func (s *fanoutSubscriber) queue(msg []byte) {
+	select {
+	case s.send <- msg:
+	default:
+		log.Warnf("Disconnecting slow fan-out subscriber %s",
+			s.conn.RemoteAddr())
+		s.disconnect()
+	}
+}
+
+// writer sends the queued messages to the subscriber.  It must be run as a
+// goroutine.
+func (s *fanoutSubscriber) writer() {
+out:
+	for {
+		select {
+		case msg := <-s.send:
+			err := s.conn.WriteMessage(websocket.TextMessage, msg)
+			if err != nil {
+				s.disconnect()
+				break out
+			}
+
+		case <-s.quit:
+			break out
+		}
+	}
+}
+
+// FanoutServer re-publishes the notifications received by a single upstream
+// client to any number of downstream websocket subscribers, which reduces the
+// number of connections to the RPC server when many processes need the same
+// notifications.
+//
+// Subscribers register for notifications with the notifyblocks,
+// stopnotifyblocks, notifynewtransactions, stopnotifynewtransactions,
+// notifyreceived, stopnotifyreceived, notifyspent, and stopnotifyspent requests
+// exactly as they would with ppcd, and receive the notifications in the same
+// JSON format.  Each subscriber has its own address and outpoint filters, and,
+// like ppcd, the outputs paying to the addresses of a subscriber are added to
+// its outpoint filter, and, also like ppcd, outpoints are removed from the
+// filter once they are spent in a block.  The registrations needed by the
+// subscribers are made by the upstream client, so they are re-established on
+// reconnect, and are reference counted across subscribers and the other users
+// of the client so they are released once nothing needs them anymore.
+// Registrations the caller made directly with the client are never released.
+// Rescans and transaction filters are not supported.
+//
+// Transaction notifications are registered as verbose when the upstream client
+// is not registered for them yet, since the non-verbose notifications can be
+// derived from the verbose ones.  A non-verbose registration made by the caller
+// is left in place, in which case subscribers can only register for
+// non-verbose transaction notifications.
+//
+// FanoutServer implements http.Handler, so it can be served by any HTTP server.
+// It does not authenticate subscribers, so the handler should be wrapped when
+// it is served on an untrusted network.
+type FanoutServer struct {
+	client   *Client
+	upgrader websocket.Upgrader
+	queue    *ntfnQueue
+	quit     chan struct{}
+	wg       sync.WaitGroup
+
+	quitOnce sync.Once
+
+	// upstreamMtx serializes the registrations made by the upstream
+	// client for the subscribers.
+	upstreamMtx    sync.Mutex
+	upstreamBlocks bool
+
+	mtx         sync.Mutex
+	subscribers map[*fanoutSubscriber]struct{}
+}
+
+// Enforce FanoutServer satisfies the ntfnObserver interface.
+var _ ntfnObserver = (*FanoutServer)(nil)
+
+// NewFanoutServer returns a new server which re-publishes the notifications
+// received by the client to its subscribers.  The client must have been
+// created with notification handlers, although none of the handlers need to be
+// set, and must not be in HTTP POST mode.
+func (c *Client) NewFanoutServer() (*FanoutServer, error) {
+	if c.config.HTTPPostMode {
+		return nil, ErrNotificationsNotSupported
+	}
+	if c.ntfnHandlers == nil {
+		return nil, ErrNoNotificationHandlers
+	}
+
+	f := &FanoutServer{
+		client: c,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+		},
+		queue:       newNtfnQueue(),
+		quit:        make(chan struct{}),
+		subscribers: make(map[*fanoutSubscriber]struct{}),
+	}
+	c.ntfnObservers.add(f)
+	f.wg.Add(1)
+	go f.dispatcher()
+	return f, nil
+}
+
+// Stop disconnects all subscribers and stops re-publishing notifications.  The
+// address and outpoint registrations made by the upstream client are released
+// as the subscribers disconnect, while the block and transaction registrations
+// are left in place.
+func (f *FanoutServer) Stop() {
+	f.quitOnce.Do(func() {
+		f.client.ntfnObservers.remove(f)
+		close(f.quit)
+	})
+	f.wg.Wait()
+
+	f.mtx.Lock()
+	for s := range f.subscribers {
+		s.disconnect()
+	}
+	f.mtx.Unlock()
+}
+
+// ServeHTTP upgrades the passed request to a websocket connection and serves
+// the subscriber until it disconnects.  It is part of the http.Handler
+// interface implementation.
+func (f *FanoutServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-f.quit:
+		http.Error(w, "server stopped", http.StatusServiceUnavailable)
+		return
+	default:
+	}
+
+	conn, err := f.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Warnf("Unable to upgrade fan-out connection from %s: %v",
+			r.RemoteAddr, err)
+		return
+	}
+	conn.SetReadLimit(fanoutMaxRequestSize)
+
+	s := &fanoutSubscriber{
+		conn:      conn,
+		send:      make(chan []byte, fanoutSendBufferSize),
+		quit:      make(chan struct{}),
+		addrs:     make(map[string]struct{}),
+		outpoints: make(map[wire.OutPoint]bool),
+	}
+	f.mtx.Lock()
+	f.subscribers[s] = struct{}{}
+	f.mtx.Unlock()
+	log.Infof("New fan-out subscriber %s", conn.RemoteAddr())
+
+	go s.writer()
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		if reply := f.handleRequest(s, msg); reply != nil {
+			s.queue(reply)
+		}
+	}
+
+	f.mtx.Lock()
+	delete(f.subscribers, s)
+	f.mtx.Unlock()
+	s.disconnect()
+	log.Infof("Fan-out subscriber %s disconnected", conn.RemoteAddr())
+
+	// Release the upstream registrations of the subscriber.
+	s.mtx.Lock()
+	addrs := make([]string, 0, len(s.addrs))
+	for addr := range s.addrs {
+		addrs = append(addrs, addr)
+	}
+	var ops []wire.OutPoint
+	for op, registered := range s.outpoints {
+		if registered {
+			ops = append(ops, op)
+		}
+	}
+	s.mtx.Unlock()
+	if err := f.releaseAddresses(s, addrs); err != nil {
+		log.Warnf("Unable to release addresses of fan-out subscriber "+
+			"%s: %v", conn.RemoteAddr(), err)
+	}
+	if err := f.releaseOutPoints(s, ops); err != nil {
+		log.Warnf("Unable to release outpoints of fan-out subscriber "+
+			"%s: %v", conn.RemoteAddr(), err)
+	}
+}
+
+// handleRequest handles the passed request from a subscriber and returns the
+// marshalled reply.
+func (f *FanoutServer) handleRequest(s *fanoutSubscriber, msg []byte) []byte {
+	var request btcjson.Request
+	if err := json.Unmarshal(msg, &request); err != nil {
+		reply, _ := btcjson.MarshalResponse(nil, nil,
+			btcjson.NewRPCError(btcjson.ErrRPCParse.Code, err.Error()))
+		return reply
+	}
+
+	var rpcErr *btcjson.RPCError
+	cmd, err := btcjson.UnmarshalCmd(&request)
+	if err != nil {
+		rpcErr = btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter,
+			err.Error())
+	} else if err := f.handleCmd(s, cmd); err != nil {
+		if e, ok := err.(*btcjson.RPCError); ok {
+			rpcErr = e
+		} else {
+			rpcErr = btcjson.NewRPCError(btcjson.ErrRPCMisc,
+				err.Error())
+		}
+	}
+
+	reply, err := btcjson.MarshalResponse(request.ID, nil, rpcErr)
+	if err != nil {
+		log.Errorf("Unable to marshal fan-out reply: %v", err)
+		return nil
+	}
+	return reply
+}
+
+// handleCmd updates the filters of the subscriber for the passed command and
+// makes the upstream registrations they require.
+func (f *FanoutServer) handleCmd(s *fanoutSubscriber, cmd interface{}) error {
+	switch cmd := cmd.(type) {
+	case *btcjson.NotifyBlocksCmd:
+		if err := f.registerBlocks(); err != nil {
+			return err
+		}
+		s.mtx.Lock()
+		s.blocks = true
+		s.mtx.Unlock()
+
+	case *btcjson.StopNotifyBlocksCmd:
+		s.mtx.Lock()
+		s.blocks = false
+		s.mtx.Unlock()
+
+	case *btcjson.NotifyNewTransactionsCmd:
+		verbose := cmd.Verbose != nil && *cmd.Verbose
+		if err := f.registerNewTx(verbose); err != nil {
+			return err
+		}
+		s.mtx.Lock()
+		s.newTx = !verbose
+		s.newTxVerbose = verbose
+		s.mtx.Unlock()
+
+	case *btcjson.StopNotifyNewTransactionsCmd:
+		s.mtx.Lock()
+		s.newTx = false
+		s.newTxVerbose = false
+		s.mtx.Unlock()
+
+	case *btcjson.NotifyReceivedCmd:
+		addrs, err := f.decodeAddresses(cmd.Addresses)
+		if err != nil {
+			return err
+		}
+		return f.registerAddresses(s, addrs)
+
+	case *btcjson.StopNotifyReceivedCmd:
+		addrs, err := f.decodeAddresses(cmd.Addresses)
+		if err != nil {
+			return err
+		}
+		encoded := make([]string, 0, len(addrs))
+		for _, addr := range addrs {
+			encoded = append(encoded, addr.EncodeAddress())
+		}
+		return f.releaseAddresses(s, encoded)
+
+	case *btcjson.NotifySpentCmd:
+		ops, err := decodeOutPoints(cmd.OutPoints)
+		if err != nil {
+			return err
+		}
+		return f.registerOutPoints(s, ops)
+
+	case *btcjson.StopNotifySpentCmd:
+		ops, err := decodeOutPoints(cmd.OutPoints)
+		if err != nil {
+			return err
+		}
+		released := make([]wire.OutPoint, 0, len(ops))
+		for _, op := range ops {
+			released = append(released, *op)
+		}
+		return f.releaseOutPoints(s, released)
+
+	default:
+		return btcjson.ErrRPCMethodNotFound
+	}
+	return nil
+}
+
+// decodeAddresses decodes the passed addresses for the network of the upstream
+// client.
+func (f *FanoutServer) decodeAddresses(encoded []string) ([]btcutil.Address, error) {
+	params := f.client.chainParams()
+	addrs := make([]btcutil.Address, 0, len(encoded))
+	for _, s := range encoded {
+		addr, err := btcutil.DecodeAddress(s, params)
+		if err != nil {
+			return nil, btcjson.NewRPCError(
+				btcjson.ErrRPCInvalidAddressOrKey,
+				"Invalid address or key: "+s)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// decodeOutPoints converts the passed outpoints to their wire representation.
+func decodeOutPoints(outpoints []btcjson.OutPoint) ([]*wire.OutPoint, error) {
+	ops := make([]*wire.OutPoint, 0, len(outpoints))
+	for _, op := range outpoints {
+		hash, err := wire.NewShaHashFromStr(op.Hash)
+		if err != nil {
+			return nil, btcjson.NewRPCError(btcjson.ErrRPCDecodeHexString,
+				err.Error())
+		}
+		ops = append(ops, wire.NewOutPoint(hash, op.Index))
+	}
+	return ops, nil
+}
+
+// registerBlocks registers the upstream client for block notifications unless
+// it is already registered.
+func (f *FanoutServer) registerBlocks() error {
+	f.upstreamMtx.Lock()
+	defer f.upstreamMtx.Unlock()
+
+	if f.upstreamBlocks {
+		return nil
+	}
+	if err := f.client.NotifyBlocks(); err != nil {
+		return err
+	}
+	f.upstreamBlocks = true
+	return nil
+}
+
+// registerNewTx makes sure the upstream client is registered for transaction
+// notifications which serve a subscriber asking for notifications of the passed
+// verbosity.  The upstream client registers for verbose notifications, which
+// serve both kinds, unless it is already registered.  An existing non-verbose
+// registration is not switched to verbose since that would change the
+// notifications delivered to the caller of the client, so verbose notifications
+// can not be served in that case.
+func (f *FanoutServer) registerNewTx(verbose bool) error {
+	f.upstreamMtx.Lock()
+	defer f.upstreamMtx.Unlock()
+
+	c := f.client
+	c.ntfnState.Lock()
+	notifyNewTx := c.ntfnState.notifyNewTx
+	notifyNewTxVerbose := c.ntfnState.notifyNewTxVerbose
+	c.ntfnState.Unlock()
+	switch {
+	case notifyNewTxVerbose:
+		return nil
+	case notifyNewTx && verbose:
+		return btcjson.NewRPCError(btcjson.ErrRPCMisc, "Verbose "+
+			"transaction notifications are not available")
+	case notifyNewTx:
+		return nil
+	}
+	return c.NotifyNewTransactions(true)
+}
+
+// registerAddresses adds the passed addresses to the filter of the subscriber
+// and registers the upstream client for notifications of transactions paying
+// to them.
+func (f *FanoutServer) registerAddresses(s *fanoutSubscriber, addrs []btcutil.Address) error {
+	f.upstreamMtx.Lock()
+	defer f.upstreamMtx.Unlock()
+
+	// Only the requests of the subscriber itself change its filter, which
+	// are handled one at a time, so it is not locked during the request.
+	subscribed := make(map[string]struct{})
+	var added []string
+	s.mtx.Lock()
+	for _, addr := range addrs {
+		encoded := addr.EncodeAddress()
+		if _, ok := s.addrs[encoded]; ok {
+			continue
+		}
+		if _, ok := subscribed[encoded]; ok {
+			continue
+		}
+		subscribed[encoded] = struct{}{}
+		added = append(added, encoded)
+	}
+	s.mtx.Unlock()
+
+	if err := f.client.retainReceived(added); err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	for _, encoded := range added {
+		s.addrs[encoded] = struct{}{}
+	}
+	s.mtx.Unlock()
+	return nil
+}
+
+// releaseAddresses removes the passed encoded addresses from the filter of the
+// subscriber and unregisters the upstream client from those nothing else
+// needs.
+func (f *FanoutServer) releaseAddresses(s *fanoutSubscriber, addrs []string) error {
+	f.upstreamMtx.Lock()
+	defer f.upstreamMtx.Unlock()
+
+	var released []string
+	s.mtx.Lock()
+	for _, addr := range addrs {
+		if _, ok := s.addrs[addr]; !ok {
+			continue
+		}
+		delete(s.addrs, addr)
+		released = append(released, addr)
+	}
+	s.mtx.Unlock()
+
+	return f.client.releaseReceived(released)
+}
+
+// registerOutPoints adds the passed outpoints to the filter of the subscriber
+// and registers the upstream client for notifications of transactions spending
+// them.
+func (f *FanoutServer) registerOutPoints(s *fanoutSubscriber, ops []*wire.OutPoint) error {
+	f.upstreamMtx.Lock()
+	defer f.upstreamMtx.Unlock()
+
+	subscribed := make(map[wire.OutPoint]struct{})
+	var added []wire.OutPoint
+	s.mtx.Lock()
+	for _, op := range ops {
+		if s.outpoints[*op] {
+			continue
+		}
+		if _, ok := subscribed[*op]; ok {
+			continue
+		}
+		subscribed[*op] = struct{}{}
+		added = append(added, *op)
+	}
+	s.mtx.Unlock()
+
+	retained := make([]btcjson.OutPoint, 0, len(added))
+	for i := range added {
+		retained = append(retained, newOutPointFromWire(&added[i]))
+	}
+	if err := f.client.retainSpent(retained); err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	for _, op := range added {
+		s.outpoints[op] = true
+	}
+	s.mtx.Unlock()
+	return nil
+}
+
+// releaseOutPoints removes the passed outpoints from the filter of the
+// subscriber and unregisters the upstream client from those the subscriber
+// registered which nothing else needs.
+func (f *FanoutServer) releaseOutPoints(s *fanoutSubscriber, ops []wire.OutPoint) error {
+	f.upstreamMtx.Lock()
+	defer f.upstreamMtx.Unlock()
+
+	var released []btcjson.OutPoint
+	s.mtx.Lock()
+	for i := range ops {
+		op := &ops[i]
+		registered, ok := s.outpoints[*op]
+		if !ok {
+			continue
+		}
+		delete(s.outpoints, *op)
+		if registered {
+			released = append(released, newOutPointFromWire(op))
+		}
+	}
+	s.mtx.Unlock()
+
+	return f.client.releaseSpent(released)
+}
+
+// observeNotification queues the notifications which can be re-published for
+// processing by the dispatcher goroutine.  It is part of the ntfnObserver
+// interface implementation.
+func (f *FanoutServer) observeNotification(ntfn *rawNotification) {
+	switch ntfn.Method {
+	case btcjson.BlockConnectedNtfnMethod, btcjson.BlockDisconnectedNtfnMethod,
+		btcjson.TxAcceptedNtfnMethod, btcjson.TxAcceptedVerboseNtfnMethod,
+		btcjson.RecvTxNtfnMethod, btcjson.RedeemingTxNtfnMethod:
+
+		f.queue.push(ntfn)
+	}
+}
+
+// dispatcher re-publishes the queued notifications.  It must be run as a
+// goroutine.
+func (f *FanoutServer) dispatcher() {
+out:
+	for {
+		select {
+		case <-f.queue.signal:
+			// The subscribers can not tell which notifications
+			// were dropped, so disconnect them to have them
+			// resync as they would after a server restart.
+			ntfns, dropped := f.queue.popAll()
+			if dropped > 0 {
+				log.Warnf("Fan-out server dropped %d "+
+					"notifications, disconnecting "+
+					"subscribers", dropped)
+				f.mtx.Lock()
+				for s := range f.subscribers {
+					s.disconnect()
+				}
+				f.mtx.Unlock()
+			}
+			for _, ntfn := range ntfns {
+				f.dispatch(ntfn)
+			}
+
+		case <-f.quit:
+			break out
+
+		case <-f.client.shutdown:
+			break out
+		}
+	}
+	f.wg.Done()
+}
+
+// marshalNotification returns the passed notification in the JSON format used
+// by ppcd.
+func marshalNotification(method string, params []json.RawMessage) ([]byte, error) {
+	return json.Marshal(&btcjson.Request{
+		Jsonrpc: "1.0",
+		Method:  method,
+		Params:  params,
+	})
+}
+
+// dispatch sends the passed notification to the subscribers it matches.
+func (f *FanoutServer) dispatch(ntfn *rawNotification) {
+	msg, err := marshalNotification(ntfn.Method, ntfn.Params)
+	if err != nil {
+		log.Errorf("Unable to marshal [%s] notification: %v",
+			ntfn.Method, err)
+		return
+	}
+
+	// The matching criteria depend on the kind of notification.  The
+	// outpoints spent in a block are collected per subscriber.
+	var matches func(s *fanoutSubscriber) bool
+	spent := make(map[*fanoutSubscriber][]wire.OutPoint)
+	switch ntfn.Method {
+	case btcjson.BlockConnectedNtfnMethod, btcjson.BlockDisconnectedNtfnMethod:
+		matches = func(s *fanoutSubscriber) bool { return s.blocks }
+
+	case btcjson.TxAcceptedNtfnMethod:
+		// The upstream client is only registered for non-verbose
+		// notifications when the caller of the client registered
+		// them.
+		matches = func(s *fanoutSubscriber) bool { return s.newTx }
+
+	case btcjson.TxAcceptedVerboseNtfnMethod:
+		// Derive the non-verbose notification for the subscribers
+		// which want it.  The values of the outputs are decoded as
+		// exact amounts.
+		var rawTx struct {
+			Txid string `json:"txid"`
+			Vout []struct {
+				Value Amount `json:"value"`
+			} `json:"vout"`
+		}
+		if len(ntfn.Params) != 1 {
+			log.Warnf("Received invalid tx accepted verbose " +
+				"notification: wrong number of params")
+			return
+		}
+		if err := json.Unmarshal(ntfn.Params[0], &rawTx); err != nil {
+			log.Warnf("Received invalid tx accepted verbose "+
+				"notification: %v", err)
+			return
+		}
+		var total Amount
+		for _, vout := range rawTx.Vout {
+			total += vout.Value
+		}
+		shortNtfn, err := newRawNotification(btcjson.TxAcceptedNtfnMethod,
+			rawTx.Txid, total)
+		if err != nil {
+			log.Errorf("Unable to create tx accepted notification: "+
+				"%v", err)
+			return
+		}
+		shortMsg, err := marshalNotification(shortNtfn.Method,
+			shortNtfn.Params)
+		if err != nil {
+			log.Errorf("Unable to marshal [%s] notification: %v",
+				shortNtfn.Method, err)
+			return
+		}
+		f.forEachSubscriber(func(s *fanoutSubscriber) {
+			if s.newTx {
+				s.queue(shortMsg)
+			}
+		})
+		matches = func(s *fanoutSubscriber) bool { return s.newTxVerbose }
+
+	case btcjson.RecvTxNtfnMethod:
+		tx, _, err := parseChainTxNtfnParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid recvtx notification: %v", err)
+			return
+		}
+		outputAddrs := f.outputAddresses(tx)
+		matches = func(s *fanoutSubscriber) bool {
+			// Like ppcd, watch the outputs paying to the addresses
+			// of the subscriber for spends.
+			matched := false
+			for i, addrs := range outputAddrs {
+				for _, addr := range addrs {
+					if _, ok := s.addrs[addr]; !ok {
+						continue
+					}
+					op := wire.OutPoint{Hash: *tx.Sha(),
+						Index: uint32(i)}
+					if _, ok := s.outpoints[op]; !ok {
+						s.outpoints[op] = false
+					}
+					matched = true
+					break
+				}
+			}
+			return matched
+		}
+
+	case btcjson.RedeemingTxNtfnMethod:
+		tx, block, err := parseChainTxNtfnParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid redeemingtx notification: "+
+				"%v", err)
+			return
+		}
+		matches = func(s *fanoutSubscriber) bool {
+			matched := false
+			for _, txIn := range tx.MsgTx().TxIn {
+				op := txIn.PreviousOutPoint
+				if _, ok := s.outpoints[op]; !ok {
+					continue
+				}
+				matched = true
+
+				// Like ppcd, stop watching the outpoint once
+				// the spend is mined.  The filter is updated
+				// once the notification is queued.
+				if block != nil {
+					spent[s] = append(spent[s], op)
+				}
+			}
+			return matched
+		}
+
+	default:
+		return
+	}
+
+	f.forEachSubscriber(func(s *fanoutSubscriber) {
+		if matches(s) {
+			s.queue(msg)
+		}
+	})
+
+	for s, ops := range spent {
+		if err := f.releaseOutPoints(s, ops); err != nil {
+			log.Warnf("Unable to release spent outpoints of "+
+				"fan-out subscriber %s: %v", s.conn.RemoteAddr(),
+				err)
+		}
+	}
+}
+
+// forEachSubscriber invokes the passed function for every subscriber with the
+// filters of the subscriber locked.
+func (f *FanoutServer) forEachSubscriber(fn func(s *fanoutSubscriber)) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	for s := range f.subscribers {
+		s.mtx.Lock()
+		fn(s)
+		s.mtx.Unlock()
+	}
+}
+
+// outputAddresses returns the encoded addresses each output of the passed
+// transaction pays to.
+func (f *FanoutServer) outputAddresses(tx *btcutil.Tx) [][]string {
+	params := f.client.chainParams()
+	txOuts := tx.MsgTx().TxOut
+	outputAddrs := make([][]string, len(txOuts))
+	for i, txOut := range txOuts {
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(txOut.PkScript,
+			params)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			outputAddrs[i] = append(outputAddrs[i], addr.EncodeAddress())
+		}
+	}
+	return outputAddrs
+}
@@ -0,0 +1,432 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/websocket"
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/chaincfg"
+	"github.com/ppcsuite/ppcd/txscript"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// fanoutTestTimeout is the time a fan-out test waits for an expected message.
+const fanoutTestTimeout = 5 * time.Second
+
+// fanoutTestUpstream is a websocket RPC server standing in for ppcd.  It
+// acknowledges every request, records the requests, and sends notifications
+// to the connected client.
+type fanoutTestUpstream struct {
+	server   *httptest.Server
+	upgrader websocket.Upgrader
+	requests chan *btcjson.Request
+
+	mtx       sync.Mutex
+	conn      *websocket.Conn
+	connected chan struct{}
+}
+
+// newFanoutTestUpstream starts a new upstream server.
+func newFanoutTestUpstream() *fanoutTestUpstream {
+	u := &fanoutTestUpstream{
+		requests:  make(chan *btcjson.Request, 100),
+		connected: make(chan struct{}),
+	}
+	u.server = httptest.NewServer(http.HandlerFunc(u.serve))
+	return u
+}
+
+// serve acknowledges the requests of the connected client.
+func (u *fanoutTestUpstream) serve(w http.ResponseWriter, r *http.Request) {
+	conn, err := u.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	u.mtx.Lock()
+	u.conn = conn
+	u.mtx.Unlock()
+	close(u.connected)
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var request btcjson.Request
+		if err := json.Unmarshal(msg, &request); err != nil {
+			return
+		}
+		u.requests <- &request
+		reply, err := btcjson.MarshalResponse(request.ID, nil, nil)
+		if err != nil {
+			return
+		}
+		u.write(reply)
+	}
+}
+
+// write sends the passed message to the connected client.
+func (u *fanoutTestUpstream) write(msg []byte) {
+	u.mtx.Lock()
+	u.conn.WriteMessage(websocket.TextMessage, msg)
+	u.mtx.Unlock()
+}
+
+// notify sends a notification with the passed method and parameters to the
+// connected client.
+func (u *fanoutTestUpstream) notify(t *testing.T, method string,
+	params ...interface{}) {
+
+	ntfn, err := newRawNotification(method, params...)
+	if err != nil {
+		t.Fatalf("newRawNotification: %v", err)
+	}
+	msg, err := marshalNotification(ntfn.Method, ntfn.Params)
+	if err != nil {
+		t.Fatalf("marshalNotification: %v", err)
+	}
+	u.write(msg)
+}
+
+// expectRequest ensures the next request received from the client has the
+// passed method.
+func (u *fanoutTestUpstream) expectRequest(t *testing.T, step, method string) *btcjson.Request {
+	select {
+	case request := <-u.requests:
+		if request.Method != method {
+			t.Fatalf("%s: got upstream request %s, want %s", step,
+				request.Method, method)
+		}
+		return request
+	case <-time.After(fanoutTestTimeout):
+		t.Fatalf("%s: timeout waiting for upstream request %s", step,
+			method)
+	}
+	return nil
+}
+
+// expectNoRequest ensures no request was received from the client.
+func (u *fanoutTestUpstream) expectNoRequest(t *testing.T, step string) {
+	select {
+	case request := <-u.requests:
+		t.Fatalf("%s: got unexpected upstream request %s", step,
+			request.Method)
+	default:
+	}
+}
+
+// fanoutTestSubscriber is a websocket connection to a FanoutServer.
+type fanoutTestSubscriber struct {
+	conn   *websocket.Conn
+	nextID uint64
+}
+
+// fanoutTestMessage is a reply or notification received by a subscriber.
+type fanoutTestMessage struct {
+	ID     *float64          `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	Error  *btcjson.RPCError `json:"error"`
+}
+
+// newFanoutTestSubscriber connects a new subscriber to the passed fan-out
+// server.
+func newFanoutTestSubscriber(t *testing.T, server *httptest.Server) *fanoutTestSubscriber {
+	var dialer websocket.Dialer
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	return &fanoutTestSubscriber{conn: conn}
+}
+
+// read returns the next message received by the subscriber.
+func (s *fanoutTestSubscriber) read(t *testing.T, step string) *fanoutTestMessage {
+	s.conn.SetReadDeadline(time.Now().Add(fanoutTestTimeout))
+	_, msg, err := s.conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("%s: ReadMessage: %v", step, err)
+	}
+	var m fanoutTestMessage
+	if err := json.Unmarshal(msg, &m); err != nil {
+		t.Fatalf("%s: invalid message %s: %v", step, msg, err)
+	}
+	return &m
+}
+
+// request sends the passed command and returns the error of the reply.
+func (s *fanoutTestSubscriber) request(t *testing.T, step string,
+	cmd interface{}) *btcjson.RPCError {
+
+	s.nextID++
+	msg, err := btcjson.MarshalCmd(s.nextID, cmd)
+	if err != nil {
+		t.Fatalf("%s: MarshalCmd: %v", step, err)
+	}
+	if err := s.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+		t.Fatalf("%s: WriteMessage: %v", step, err)
+	}
+	reply := s.read(t, step)
+	if reply.ID == nil || uint64(*reply.ID) != s.nextID {
+		t.Fatalf("%s: got message %+v, want reply %d", step, reply,
+			s.nextID)
+	}
+	return reply.Error
+}
+
+// mustRequest sends the passed command and ensures it succeeds.
+func (s *fanoutTestSubscriber) mustRequest(t *testing.T, step string,
+	cmd interface{}) {
+
+	if rpcErr := s.request(t, step, cmd); rpcErr != nil {
+		t.Fatalf("%s: got error %v", step, rpcErr)
+	}
+}
+
+// expectNtfn ensures the next message received by the subscriber is a
+// notification with the passed method.
+func (s *fanoutTestSubscriber) expectNtfn(t *testing.T, step, method string) *fanoutTestMessage {
+	ntfn := s.read(t, step)
+	if ntfn.ID != nil || ntfn.Method != method {
+		t.Fatalf("%s: got message %+v, want %s notification", step,
+			ntfn, method)
+	}
+	return ntfn
+}
+
+// newFanoutTest connects a client to a new upstream server and serves a new
+// fan-out server for it.
+func newFanoutTest(t *testing.T) (*fanoutTestUpstream, *Client, *FanoutServer,
+	*httptest.Server) {
+
+	upstream := newFanoutTestUpstream()
+	client, err := New(&ConnConfig{
+		Host:                 strings.TrimPrefix(upstream.server.URL, "http://"),
+		Endpoint:             "ws",
+		User:                 "user",
+		Pass:                 "pass",
+		DisableTLS:           true,
+		DisableAutoReconnect: true,
+	}, &NotificationHandlers{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	<-upstream.connected
+
+	f, err := client.NewFanoutServer()
+	if err != nil {
+		t.Fatalf("NewFanoutServer: %v", err)
+	}
+	return upstream, client, f, httptest.NewServer(f)
+}
+
+// closeFanoutTest stops the passed fan-out test servers and client.
+func closeFanoutTest(upstream *fanoutTestUpstream, client *Client,
+	f *FanoutServer, server *httptest.Server) {
+
+	f.Stop()
+	server.Close()
+	client.Shutdown()
+	client.WaitForShutdown()
+	upstream.server.Close()
+}
+
+// newFanoutTestTx returns the hex encoded serialization of a transaction
+// spending the passed outpoint with an output paying to the passed address.
+func newFanoutTestTx(t *testing.T, prevOut wire.OutPoint,
+	addr btcutil.Address) (*wire.MsgTx, string) {
+
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: %v", err)
+	}
+	msgTx := wire.NewMsgTx()
+	msgTx.AddTxIn(wire.NewTxIn(&prevOut, nil))
+	msgTx.AddTxOut(wire.NewTxOut(1e6, pkScript))
+	var buf bytes.Buffer
+	if err := msgTx.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	return msgTx, hex.EncodeToString(buf.Bytes())
+}
+
+// fanoutTestOutPoints returns the number of outpoints watched for the
+// subscribers of the passed fan-out server.
+func fanoutTestOutPoints(f *FanoutServer) int {
+	n := 0
+	f.forEachSubscriber(func(s *fanoutSubscriber) {
+		n += len(s.outpoints)
+	})
+	return n
+}
+
+// TestFanoutServer ensures the fan-out server re-publishes the notifications
+// matching the filters of its subscribers, reference counts the upstream
+// registrations without releasing those made by the caller of the client, and
+// stops watching outpoints once they are spent in a block.
+func TestFanoutServer(t *testing.T) {
+	t.Parallel()
+
+	upstream, client, f, server := newFanoutTest(t)
+	defer closeFanoutTest(upstream, client, f, server)
+	subA := newFanoutTestSubscriber(t, server)
+	subB := newFanoutTestSubscriber(t, server)
+
+	pkHash := bytes.Repeat([]byte{1}, 20)
+	addr, err := btcutil.NewAddressPubKeyHash(pkHash, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: %v", err)
+	}
+	callerPkHash := bytes.Repeat([]byte{2}, 20)
+	callerAddr, err := btcutil.NewAddressPubKeyHash(callerPkHash,
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: %v", err)
+	}
+
+	// Block notifications are only delivered to the subscribers which
+	// registered them.
+	subA.mustRequest(t, "notifyblocks", btcjson.NewNotifyBlocksCmd())
+	upstream.expectRequest(t, "notifyblocks", "notifyblocks")
+	blockHash := wire.ShaHash{0x10}
+	upstream.notify(t, btcjson.BlockConnectedNtfnMethod, blockHash.String(),
+		10, 1400000000)
+	subA.expectNtfn(t, "blockconnected", btcjson.BlockConnectedNtfnMethod)
+
+	// The upstream registration of an address is shared by the
+	// subscribers.
+	encoded := []string{addr.EncodeAddress()}
+	subA.mustRequest(t, "notifyreceived A", btcjson.NewNotifyReceivedCmd(encoded))
+	upstream.expectRequest(t, "notifyreceived A", "notifyreceived")
+	subB.mustRequest(t, "notifyreceived B", btcjson.NewNotifyReceivedCmd(encoded))
+	upstream.expectNoRequest(t, "notifyreceived B")
+
+	// An address registered by the caller of the client is never
+	// released.
+	if err := client.NotifyReceived([]btcutil.Address{callerAddr}); err != nil {
+		t.Fatalf("NotifyReceived: %v", err)
+	}
+	upstream.expectRequest(t, "caller notifyreceived", "notifyreceived")
+	callerEncoded := []string{callerAddr.EncodeAddress()}
+	subA.mustRequest(t, "notifyreceived caller address",
+		btcjson.NewNotifyReceivedCmd(callerEncoded))
+	subA.mustRequest(t, "stopnotifyreceived caller address",
+		btcjson.NewStopNotifyReceivedCmd(callerEncoded))
+	upstream.expectNoRequest(t, "stopnotifyreceived caller address")
+
+	// Both subscribers receive the transaction paying to the address, and
+	// watch its output for spends.
+	fundingTx, fundingHex := newFanoutTestTx(t, wire.OutPoint{Index: 1},
+		addr)
+	fundingOut := wire.OutPoint{Hash: fundingTx.TxSha(), Index: 0}
+	block := &btcjson.BlockDetails{Height: 11, Hash: blockHash.String(),
+		Time: 1400000000}
+	upstream.notify(t, btcjson.RecvTxNtfnMethod, fundingHex, block)
+	subA.expectNtfn(t, "recvtx A", btcjson.RecvTxNtfnMethod)
+	subB.expectNtfn(t, "recvtx B", btcjson.RecvTxNtfnMethod)
+
+	// The spend is delivered while in the memory pool and once mined,
+	// after which the output is no longer watched.
+	_, spendHex := newFanoutTestTx(t, fundingOut, callerAddr)
+	upstream.notify(t, btcjson.RedeemingTxNtfnMethod, spendHex)
+	subA.expectNtfn(t, "mempool redeemingtx", btcjson.RedeemingTxNtfnMethod)
+	upstream.notify(t, btcjson.RedeemingTxNtfnMethod, spendHex, block)
+	subA.expectNtfn(t, "mined redeemingtx", btcjson.RedeemingTxNtfnMethod)
+	subB.expectNtfn(t, "mempool redeemingtx B", btcjson.RedeemingTxNtfnMethod)
+	subB.expectNtfn(t, "mined redeemingtx B", btcjson.RedeemingTxNtfnMethod)
+	upstream.notify(t, btcjson.RedeemingTxNtfnMethod, spendHex, block)
+	upstream.notify(t, btcjson.BlockConnectedNtfnMethod, blockHash.String(),
+		11, 1400000000)
+	subA.expectNtfn(t, "spent outpoint", btcjson.BlockConnectedNtfnMethod)
+	if n := fanoutTestOutPoints(f); n != 0 {
+		t.Fatalf("spent outpoint: got %d watched outpoints, want 0", n)
+	}
+
+	// An outpoint registered by a subscriber is released upstream once it
+	// is spent in a block.
+	registeredOut := wire.OutPoint{Hash: wire.ShaHash{0x20}, Index: 3}
+	subB.mustRequest(t, "notifyspent", btcjson.NewNotifySpentCmd(
+		[]btcjson.OutPoint{newOutPointFromWire(&registeredOut)}))
+	upstream.expectRequest(t, "notifyspent", "notifyspent")
+	_, spendHex = newFanoutTestTx(t, registeredOut, callerAddr)
+	upstream.notify(t, btcjson.RedeemingTxNtfnMethod, spendHex, block)
+	subB.expectNtfn(t, "registered redeemingtx", btcjson.RedeemingTxNtfnMethod)
+	upstream.expectRequest(t, "registered redeemingtx", "stopnotifyspent")
+
+	// The address is only released upstream once no subscriber needs it.
+	subA.mustRequest(t, "stopnotifyreceived A",
+		btcjson.NewStopNotifyReceivedCmd(encoded))
+	upstream.expectNoRequest(t, "stopnotifyreceived A")
+	subB.mustRequest(t, "stopnotifyreceived B",
+		btcjson.NewStopNotifyReceivedCmd(encoded))
+	request := upstream.expectRequest(t, "stopnotifyreceived B",
+		"stopnotifyreceived")
+	var released []string
+	if err := json.Unmarshal(request.Params[0], &released); err != nil ||
+		len(released) != 1 || released[0] != encoded[0] {
+		t.Fatalf("stopnotifyreceived B: got released addresses %s, "+
+			"want %v", request.Params[0], encoded)
+	}
+}
+
+// TestFanoutServerNewTx ensures the fan-out server registers the upstream
+// client for verbose transaction notifications unless the caller of the client
+// already registered non-verbose ones, which are then left in place.
+func TestFanoutServerNewTx(t *testing.T) {
+	t.Parallel()
+
+	verbose := true
+	nonVerbose := false
+	txHash := wire.ShaHash{0x30}
+
+	// Without a registration by the caller, the upstream client registers
+	// verbose notifications, which serve both kinds.
+	upstream, client, f, server := newFanoutTest(t)
+	sub := newFanoutTestSubscriber(t, server)
+	sub.mustRequest(t, "non-verbose", btcjson.NewNotifyNewTransactionsCmd(
+		&nonVerbose))
+	request := upstream.expectRequest(t, "non-verbose",
+		"notifynewtransactions")
+	if len(request.Params) != 1 || string(request.Params[0]) != "true" {
+		t.Fatalf("non-verbose: got upstream params %s, want verbose",
+			request.Params)
+	}
+	upstream.notify(t, btcjson.TxAcceptedVerboseNtfnMethod,
+		&btcjson.TxRawResult{Txid: txHash.String()})
+	sub.expectNtfn(t, "non-verbose", btcjson.TxAcceptedNtfnMethod)
+	closeFanoutTest(upstream, client, f, server)
+
+	// A non-verbose registration by the caller is not switched, so only
+	// non-verbose notifications can be served.
+	upstream, client, f, server = newFanoutTest(t)
+	defer closeFanoutTest(upstream, client, f, server)
+	if err := client.NotifyNewTransactions(false); err != nil {
+		t.Fatalf("NotifyNewTransactions: %v", err)
+	}
+	upstream.expectRequest(t, "caller", "notifynewtransactions")
+	sub = newFanoutTestSubscriber(t, server)
+	rpcErr := sub.request(t, "verbose", btcjson.NewNotifyNewTransactionsCmd(
+		&verbose))
+	if rpcErr == nil {
+		t.Fatal("verbose: registration succeeded, want error")
+	}
+	sub.mustRequest(t, "caller non-verbose",
+		btcjson.NewNotifyNewTransactionsCmd(&nonVerbose))
+	upstream.expectNoRequest(t, "caller non-verbose")
+	upstream.notify(t, btcjson.TxAcceptedNtfnMethod, txHash.String(), 1.5)
+	sub.expectNtfn(t, "caller non-verbose", btcjson.TxAcceptedNtfnMethod)
+}
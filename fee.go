@@ -0,0 +1,204 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"fmt"
+
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// FeePolicy describes the minimum fee and output value rules transactions must
+// follow to be accepted by the network.
+type FeePolicy struct {
+	// MinFeePerKB is the fee required for each started kilobyte of the
+	// serialized transaction.
+	MinFeePerKB btcutil.Amount
+
+	// MinOutput is the minimum value of an output.  Smaller outputs are
+	// dust, which the network rejects regardless of the fee.
+	MinOutput btcutil.Amount
+}
+
+// DefaultFeePolicy is the fee policy of the Peercoin reference implementation,
+// which requires a fee of 0.01 peercoin per started kilobyte and outputs of at
+// least 0.01 peercoin.
+var DefaultFeePolicy = FeePolicy{
+	MinFeePerKB: peercoinCent,
+	MinOutput:   peercoinCent,
+}
+
+// InsufficientFeeError describes a transaction which pays less than the fee
+// required by a fee policy.
+type InsufficientFeeError struct {
+	TxHash      wire.ShaHash
+	Size        int
+	Fee         btcutil.Amount
+	RequiredFee btcutil.Amount
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *InsufficientFeeError) Error() string {
+	return fmt.Sprintf("transaction %v of %d bytes pays a fee of %v, "+
+		"which is less than the required fee of %v", e.TxHash, e.Size,
+		Amount(e.Fee), Amount(e.RequiredFee))
+}
+
+// DustOutputError describes a transaction with an output whose value is less
+// than the minimum output value of a fee policy.  The hash and index are unset
+// when the transaction is not created yet.
+type DustOutputError struct {
+	TxHash    wire.ShaHash
+	Index     uint32
+	Value     btcutil.Amount
+	MinOutput btcutil.Amount
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *DustOutputError) Error() string {
+	if e.TxHash == (wire.ShaHash{}) {
+		return fmt.Sprintf("output value of %v is less than the "+
+			"minimum output value of %v", Amount(e.Value),
+			Amount(e.MinOutput))
+	}
+	return fmt.Sprintf("output %d of transaction %v has a value of %v, "+
+		"which is less than the minimum output value of %v", e.Index,
+		e.TxHash, Amount(e.Value), Amount(e.MinOutput))
+}
+
+// RequiredFee returns the fee required for the passed transaction, which is
+// the minimum fee per kilobyte for each started kilobyte of the serialized
+// transaction.  Since signatures add to the size, the transaction should be
+// signed.
+func (p *FeePolicy) RequiredFee(tx *wire.MsgTx) btcutil.Amount {
+	return requiredFee(tx.SerializeSize(), p.MinFeePerKB)
+}
+
+// requiredFee returns the fee required for a transaction of the passed size,
+// rounded up to the next kilobyte like the Peercoin reference implementation.
+func requiredFee(size int, feePerKB btcutil.Amount) btcutil.Amount {
+	return btcutil.Amount(1+size/1000) * feePerKB
+}
+
+// CheckOutputs returns a *DustOutputError if an output of the passed
+// transaction is worth less than the minimum output value.  The empty first
+// output which marks coinstake transactions is allowed.
+func (p *FeePolicy) CheckOutputs(tx *wire.MsgTx) error {
+	for i, txOut := range tx.TxOut {
+		if txOut.Value == 0 && len(txOut.PkScript) == 0 {
+			continue
+		}
+		if btcutil.Amount(txOut.Value) < p.MinOutput {
+			return &DustOutputError{
+				TxHash:    tx.TxSha(),
+				Index:     uint32(i),
+				Value:     btcutil.Amount(txOut.Value),
+				MinOutput: p.MinOutput,
+			}
+		}
+	}
+	return nil
+}
+
+// checkAmounts returns a *DustOutputError if one of the passed amounts, such as
+// those passed to CreateRawTransaction, is less than the minimum output value.
+func (p *FeePolicy) checkAmounts(amounts map[btcutil.Address]btcutil.Amount) error {
+	for _, amount := range amounts {
+		if amount < p.MinOutput {
+			return &DustOutputError{Value: amount, MinOutput: p.MinOutput}
+		}
+	}
+	return nil
+}
+
+// Check returns a *DustOutputError or an *InsufficientFeeError if the passed
+// transaction, which spends inputs worth the passed total value, does not
+// follow the fee policy.
+func (p *FeePolicy) Check(tx *wire.MsgTx, inputValue btcutil.Amount) error {
+	if err := p.CheckOutputs(tx); err != nil {
+		return err
+	}
+
+	fee := inputValue
+	for _, txOut := range tx.TxOut {
+		fee -= btcutil.Amount(txOut.Value)
+	}
+	size := tx.SerializeSize()
+	required := requiredFee(size, p.MinFeePerKB)
+	if fee < required {
+		return &InsufficientFeeError{
+			TxHash:      tx.TxSha(),
+			Size:        size,
+			Fee:         fee,
+			RequiredFee: required,
+		}
+	}
+	return nil
+}
+
+// CheckTransactionFee returns a *DustOutputError or an *InsufficientFeeError if
+// the passed signed transaction does not follow the passed fee policy, or
+// DefaultFeePolicy when it is nil.  The values of the inputs are looked up
+// with GetTxOut, which does not require the transaction index, unless they are
+// known to the client already.  The inputs must therefore be unspent outputs of
+// the main chain or of transactions in the memory pool.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) CheckTransactionFee(tx *wire.MsgTx, policy *FeePolicy) error {
+	if policy == nil {
+		policy = &DefaultFeePolicy
+	}
+
+	// Check the outputs first since it does not require any requests.
+	if err := policy.CheckOutputs(tx); err != nil {
+		return err
+	}
+
+	// Request the values of the inputs which are not cached at once.
+	var inputValue btcutil.Amount
+	futures := make([]FutureGetTxOutResult, len(tx.TxIn))
+	for i, txIn := range tx.TxIn {
+		op := &txIn.PreviousOutPoint
+		if value, ok := c.txOutValues.lookup(op); ok {
+			inputValue += value
+			continue
+		}
+		futures[i] = c.GetTxOutAsync(&op.Hash, op.Index, true)
+	}
+	for i, future := range futures {
+		if future == nil {
+			continue
+		}
+		txOut, err := future.Receive()
+		if err != nil {
+			return err
+		}
+		if txOut == nil {
+			return fmt.Errorf("input %d of transaction %v spends "+
+				"unknown or spent output %v", i, tx.TxSha(),
+				tx.TxIn[i].PreviousOutPoint)
+		}
+		inputValue += btcutil.Amount(txOut.Value)
+	}
+	return policy.Check(tx, inputValue)
+}
+
+// sendCheckedRawTransaction submits the passed command sending the passed
+// transaction once it is checked against the configured fee policy.  The check
+// runs in its own goroutine since it issues blocking requests.
+func (c *Client) sendCheckedRawTransaction(tx *wire.MsgTx, cmd interface{}) chan *response {
+	responseChan := make(chan *response, 1)
+	go func() {
+		err := c.CheckTransactionFee(tx, c.config.FeePolicy)
+		if err != nil {
+			responseChan <- &response{err: err}
+			return
+		}
+		responseChan <- <-c.sendCmd(cmd)
+	}()
+	return responseChan
+}
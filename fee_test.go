@@ -0,0 +1,125 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"testing"
+
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// TestRequiredFee ensures the required fee is charged for each started
+// kilobyte like the Peercoin reference implementation.
+func TestRequiredFee(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		size int
+		want btcutil.Amount
+	}{
+		{0, peercoinCent},
+		{226, peercoinCent},
+		{999, peercoinCent},
+		{1000, 2 * peercoinCent},
+		{1999, 2 * peercoinCent},
+		{10500, 11 * peercoinCent},
+	}
+
+	for _, test := range tests {
+		got := requiredFee(test.size, DefaultFeePolicy.MinFeePerKB)
+		if got != test.want {
+			t.Errorf("requiredFee(%d): got %d, want %d", test.size,
+				got, test.want)
+		}
+	}
+}
+
+// TestFeePolicyCheck ensures transactions with dust outputs or insufficient
+// fees are rejected, while the empty first output of coinstake transactions is
+// allowed.
+func TestFeePolicyCheck(t *testing.T) {
+	t.Parallel()
+
+	pkScript := make([]byte, 25)
+	tests := []struct {
+		name      string
+		outputs   []int64
+		coinStake bool
+		extra     btcutil.Amount // input value above outputs plus fee
+		dust      bool
+		lowFee    bool
+	}{
+		{
+			name:    "exact fee",
+			outputs: []int64{peercoinCoin, peercoinCent},
+		},
+		{
+			name:    "overpaid fee",
+			outputs: []int64{peercoinCoin},
+			extra:   peercoinCoin,
+		},
+		{
+			name:    "underpaid fee",
+			outputs: []int64{peercoinCoin},
+			extra:   -1,
+			lowFee:  true,
+		},
+		{
+			name:    "dust output",
+			outputs: []int64{peercoinCoin, peercoinCent - 1},
+			extra:   peercoinCoin,
+			dust:    true,
+		},
+		{
+			name:      "coinstake marker",
+			outputs:   []int64{0, peercoinCoin},
+			coinStake: true,
+		},
+	}
+
+	policy := &DefaultFeePolicy
+	for _, test := range tests {
+		msgTx := wire.NewMsgTx()
+		msgTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil))
+		var outputValue btcutil.Amount
+		for i, value := range test.outputs {
+			script := pkScript
+			if test.coinStake && i == 0 {
+				script = nil
+			}
+			msgTx.AddTxOut(wire.NewTxOut(value, script))
+			outputValue += btcutil.Amount(value)
+		}
+		required := policy.RequiredFee(msgTx)
+		inputValue := outputValue + required + test.extra
+
+		err := policy.Check(msgTx, inputValue)
+		switch e := err.(type) {
+		case nil:
+			if test.dust || test.lowFee {
+				t.Errorf("%s: expected error", test.name)
+			}
+		case *DustOutputError:
+			if !test.dust {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			} else if e.MinOutput != policy.MinOutput {
+				t.Errorf("%s: got minimum output %d, want %d",
+					test.name, e.MinOutput, policy.MinOutput)
+			}
+		case *InsufficientFeeError:
+			if !test.lowFee {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			} else if e.RequiredFee != required ||
+				e.Fee != required+test.extra {
+				t.Errorf("%s: got fee %d of %d, want %d of %d",
+					test.name, e.Fee, e.RequiredFee,
+					required+test.extra, required)
+			}
+		default:
+			t.Errorf("%s: unexpected error type %T", test.name, err)
+		}
+	}
+}
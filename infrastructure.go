@@ -24,6 +24,7 @@ import (
 	"github.com/btcsuite/go-socks/socks"
 	"github.com/btcsuite/websocket"
 	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/chaincfg"
 )
 
 var (
@@ -145,6 +146,36 @@ type Client struct {
 	// Notifications.
 	ntfnHandlers *NotificationHandlers
 	ntfnState    *notificationState
+	chainState   *chainNtfnState
+	txOutValues  *txOutValueCache
+
+	// txFilterSupport caches whether or not the server supports the
+	// transaction filter commands.
+	txFilterSupport txFilterSupport
+
+	// chainTxDedup drops duplicate recvtx and redeemingtx notifications.
+	// It is nil unless the NtfnDedupWindow config option is set.
+	chainTxDedup *chainTxDedup
+
+	// ntfnObservers holds the components which process every notification
+	// in addition to the notification handlers.
+	ntfnObservers *ntfnObservers
+
+	// rescanNtfns is used to deliver rescan progress to the rescans
+	// started with RescanRangeAsync.
+	rescanNtfns *rescanNtfnSubscribers
+
+	// ntfnMtx serializes the delivery of notifications to the handlers.
+	// It also protects ntfnHeld and heldNtfns, which queue the
+	// notifications received while missed notifications are replayed on
+	// reconnect.
+	ntfnMtx   sync.Mutex
+	ntfnHeld  bool
+	heldNtfns []*rawNotification
+
+	// ntfnStateDirty is signaled when the notification state changes so
+	// it can be saved to the configured notification state store.
+	ntfnStateDirty chan struct{}
 
 	// Networking infrastructure.
 	sendChan        chan []byte
@@ -255,6 +286,55 @@ func (c *Client) trackRegisteredNtfns(cmd interface{}) {
 		for _, addr := range bcmd.Addresses {
 			c.ntfnState.notifyReceived[addr] = struct{}{}
 		}
+
+	case *btcjson.StopNotifySpentCmd:
+		for _, op := range bcmd.OutPoints {
+			delete(c.ntfnState.notifySpent, op)
+		}
+
+	case *btcjson.StopNotifyReceivedCmd:
+		for _, addr := range bcmd.Addresses {
+			delete(c.ntfnState.notifyReceived, addr)
+		}
+
+	case *loadTxFilterCmd:
+		if bcmd.Reload {
+			c.ntfnState.txFilterAddrs = make(map[string]struct{})
+			c.ntfnState.txFilterOutPoints = make(map[btcjson.OutPoint]struct{})
+		}
+		c.ntfnState.txFilterLoaded = true
+		for _, addr := range bcmd.Addresses {
+			c.ntfnState.txFilterAddrs[addr] = struct{}{}
+		}
+		for _, op := range bcmd.OutPoints {
+			c.ntfnState.txFilterOutPoints[op] = struct{}{}
+		}
+
+	default:
+		return
+	}
+	c.ntfnStateChanged()
+}
+
+// trackWatchedFilter adds the passed addresses and outpoints, which the server
+// is about to watch without them being part of the registered notification
+// state, such as those passed to a rescan, to the notification state.  This
+// allows detailed transaction notifications to report which parts of a
+// transaction matched.
+func (c *Client) trackWatchedFilter(addresses []string, outpoints []btcjson.OutPoint) {
+	// Nothing to do if the caller is not interested in notifications.
+	if c.ntfnHandlers == nil {
+		return
+	}
+
+	c.ntfnState.Lock()
+	defer c.ntfnState.Unlock()
+
+	for _, addr := range addresses {
+		c.ntfnState.watchedReceived.add(addr)
+	}
+	for _, op := range outpoints {
+		c.ntfnState.watchedSpent.add(op)
 	}
 }
 
@@ -332,7 +412,7 @@ func (c *Client) handleMessage(msg []byte) {
 		}
 		// Deliver the notification.
 		log.Tracef("Received notification [%s]", in.Method)
-		c.handleNotification(in.rawNotification)
+		c.deliverNotification(in.rawNotification)
 		return
 	}
 
@@ -468,8 +548,14 @@ func (c *Client) reregisterNtfns() error {
 	// the notification state (while not under the lock of course) which
 	// also register it with the remote RPC server, so this prevents double
 	// registrations.
-	stateCopy := c.ntfnState.Copy()
+	return c.registerNtfnState(c.ntfnState.Copy())
+}
 
+// registerNtfnState creates and sends the commands needed to register all of
+// the notifications in the passed state with the RPC server.  The state is
+// added to the notification state associated with the client as each command
+// succeeds.
+func (c *Client) registerNtfnState(stateCopy *notificationState) error {
 	// Reregister notifyblocks if needed.
 	if stateCopy.notifyBlocks {
 		log.Debugf("Reregistering [notifyblocks]")
@@ -516,6 +602,26 @@ func (c *Client) reregisterNtfns() error {
 		}
 	}
 
+	// Reload the previously loaded transaction filter in one command if
+	// needed.
+	if stateCopy.txFilterLoaded {
+		addresses := make([]string, 0, len(stateCopy.txFilterAddrs))
+		for addr := range stateCopy.txFilterAddrs {
+			addresses = append(addresses, addr)
+		}
+		outpoints := make([]btcjson.OutPoint, 0,
+			len(stateCopy.txFilterOutPoints))
+		for op := range stateCopy.txFilterOutPoints {
+			outpoints = append(outpoints, op)
+		}
+		log.Debugf("Reloading [loadtxfilter] with %d addresses and %d "+
+			"outpoints", len(addresses), len(outpoints))
+		err := c.loadTxFilterInternal(true, addresses, outpoints).Receive()
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -529,6 +635,17 @@ var ignoreResends = map[string]struct{}{
 // disconnected.  It is intended to be called once the client has reconnected as
 // a separate goroutine.
 func (c *Client) resendRequests() {
+	// Snapshot the blocks the client was notified about before
+	// re-registering for notifications, since block notifications received
+	// afterwards would otherwise hide the missed blocks.  Those
+	// notifications are held until the missed ones are replayed.
+	var seen []seenBlock
+	if c.config.ReplayMissedBlocks {
+		seen = c.chainState.Blocks()
+		c.holdNotifications()
+		defer c.releaseNotifications(nil)
+	}
+
 	// Set the notification state back up.  If anything goes wrong,
 	// disconnect the client.
 	if err := c.reregisterNtfns(); err != nil {
@@ -551,9 +668,10 @@ func (c *Client) resendRequests() {
 		if _, ok := ignoreResends[jReq.method]; ok {
 			// If a request is not sent on reconnect, remove it
 			// from the request structures, since no reply is
-			// expected.
+			// expected, and let the caller know it was lost.
 			delete(c.requestMap, jReq.id)
 			c.requestList.Remove(e)
+			jReq.responseChan <- &response{err: ErrClientDisconnect}
 		} else {
 			resendReqs = append(resendReqs, jReq)
 		}
@@ -571,6 +689,15 @@ func (c *Client) resendRequests() {
 			jReq.id)
 		c.sendMessage(jReq.marshalledJSON)
 	}
+
+	// Deliver any block notifications that were missed while the client
+	// was disconnected if requested.
+	if c.config.ReplayMissedBlocks {
+		if err := c.replayMissedBlocks(seen); err != nil {
+			log.Warnf("Unable to replay missed block notifications: %v",
+				err)
+		}
+	}
 }
 
 // wsReconnectHandler listens for client disconnects and automatically tries
@@ -992,6 +1119,10 @@ func (c *Client) start() {
 	if c.config.HTTPPostMode {
 		c.wg.Add(1)
 		go c.sendPostHandler()
+		if c.ntfnHandlers != nil && c.config.NtfnPollInterval > 0 {
+			c.wg.Add(1)
+			go c.ntfnPoller()
+		}
 	} else {
 		c.wg.Add(3)
 		go func() {
@@ -1065,6 +1196,24 @@ type ConnConfig struct {
 	// called manually.
 	DisableConnectOnNew bool
 
+	// ReplayMissedBlocks specifies that the client should deliver the
+	// block connected and block disconnected notifications for any blocks
+	// that were connected to or disconnected from the main chain while the
+	// client was disconnected from the server.  The notifications are
+	// replayed once the client has reconnected and the registered
+	// notifications have been re-established, before any notification
+	// received since the client reconnected.  It has no effect unless
+	// block notifications have been registered with NotifyBlocks.
+	ReplayMissedBlocks bool
+
+	// RescanMissedBlocks specifies that, after replaying missed block
+	// notifications, the client should rescan the missed blocks for all
+	// addresses and outpoints registered with NotifyReceived and
+	// NotifySpent so any missed OnRecvTx and OnRedeemingTx notifications
+	// are delivered as well.  It has no effect if the ReplayMissedBlocks
+	// parameter is not set.
+	RescanMissedBlocks bool
+
 	// HTTPPostMode instructs the client to run using multiple independent
 	// connections issuing HTTP POST requests instead of using the default
 	// of websockets.  Websockets are generally preferred as some of the
@@ -1073,6 +1222,65 @@ type ConnConfig struct {
 	// flag can be set to true to use basic HTTP POST requests instead.
 	HTTPPostMode bool
 
+	// NtfnPollInterval enables emulation of the block connected, block
+	// disconnected, and transaction accepted notifications when running in
+	// HTTP POST mode.  When it is set to a positive duration, NotifyBlocks
+	// and NotifyNewTransactions succeed in HTTP POST mode and the client
+	// polls the RPC server at the specified interval for changes to the
+	// best chain and memory pool, delivering the same notifications to the
+	// notification handlers that a websocket connection would.  It has no
+	// effect when not running in HTTP POST mode.
+	//
+	// NOTE: The emulated notifications are delivered from the polling
+	// goroutine, which only requests further changes once the handlers
+	// return.  Since responses in HTTP POST mode are not read by that
+	// goroutine, handlers may safely issue blocking requests.
+	NtfnPollInterval time.Duration
+
+	// ChainParams specifies the network parameters used to decode the
+	// addresses reported in notifications.  The main network parameters
+	// are used when it is nil.
+	ChainParams *chaincfg.Params
+
+	// NtfnDedupWindow enables dropping duplicate OnRecvTx, OnRecvTxDetails,
+	// OnRedeemingTx, and OnRedeemingTxDetails notifications, such as those
+	// caused by overlapping rescans or rescans after a reconnect, when set
+	// to a positive number.  A notification is a duplicate when one of the
+	// same kind was already delivered for the same transaction and block,
+	// or while the transaction was in the memory pool, among the specified
+	// number of most recently notified transactions.  The Delivery field
+	// of the detailed notifications then also reports whether the
+	// transaction is seen for the first time or was seen before, such as
+	// in the memory pool.  Up to the specified number of transactions are
+	// remembered for each kind of notification.  When block notifications
+	// are registered with NotifyBlocks, the deliveries for a block are
+	// forgotten once it is disconnected.
+	NtfnDedupWindow int
+
+	// NtfnStateStore specifies an optional store which the client keeps
+	// current with the registered notifications, such as one returned by
+	// NewFileNotificationStateStore.  The state is saved asynchronously
+	// each time it changes and once more on shutdown.  Use
+	// RestoreNotificationState to register the saved notifications again
+	// after a restart.  It has no effect if there are no notification
+	// handlers.
+	NtfnStateStore NotificationStateStore
+
+	// ValidateTxTime instructs SendRawTransaction to reject transactions
+	// whose timestamp is later than the local clock, which approximates
+	// the network time, instead of submitting them to the server which
+	// would reject them.  Use CheckTransactionTime to also account for
+	// the time of the best block when the local clock is behind.
+	ValidateTxTime bool
+
+	// FeePolicy instructs CreateRawTransaction to reject outputs worth less
+	// than the minimum output value, and SendRawTransaction to reject
+	// transactions which do not follow the fee policy, such as
+	// DefaultFeePolicy, instead of submitting them to the server which
+	// would reject them.  SendRawTransaction requests the values of the
+	// inputs from the server to check the fee.
+	FeePolicy *FeePolicy
+
 	// EnableBCInfoHacks is an option provided to enable compatiblity hacks
 	// when connecting to blockchain.info RPC server
 	EnableBCInfoHacks bool
@@ -1187,13 +1395,16 @@ func dial(config *ConnConfig) (*websocket.Conn, error) {
 func New(config *ConnConfig, ntfnHandlers *NotificationHandlers) (*Client, error) {
 	// Either open a websocket connection or create an HTTP client depending
 	// on the HTTP POST mode.  Also, set the notification handlers to nil
-	// when running in HTTP POST mode.
+	// when running in HTTP POST mode unless notifications are emulated by
+	// polling.
 	var wsConn *websocket.Conn
 	var httpClient *http.Client
 	connEstablished := make(chan struct{})
 	var start bool
 	if config.HTTPPostMode {
-		ntfnHandlers = nil
+		if config.NtfnPollInterval <= 0 {
+			ntfnHandlers = nil
+		}
 		start = true
 
 		var err error
@@ -1222,6 +1433,11 @@ func New(config *ConnConfig, ntfnHandlers *NotificationHandlers) (*Client, error
 		requestList:     list.New(),
 		ntfnHandlers:    ntfnHandlers,
 		ntfnState:       newNotificationState(),
+		chainState:      newChainNtfnState(),
+		txOutValues:     newTxOutValueCache(maxCachedTxOutValues),
+		ntfnObservers:   newNtfnObservers(),
+		rescanNtfns:     newRescanNtfnSubscribers(),
+		ntfnStateDirty:  make(chan struct{}, 1),
 		sendChan:        make(chan []byte, sendBufferSize),
 		sendPostChan:    make(chan *sendPostDetails, sendPostBufferSize),
 		connEstablished: connEstablished,
@@ -1229,6 +1445,22 @@ func New(config *ConnConfig, ntfnHandlers *NotificationHandlers) (*Client, error
 		shutdown:        make(chan struct{}),
 	}
 
+	// Keep the configured notification state store current regardless of
+	// whether or not the client is connected yet.
+	if config.NtfnStateStore != nil && ntfnHandlers != nil {
+		client.wg.Add(1)
+		go client.ntfnStateSaver()
+	}
+
+	// Fetch the details of connected blocks when requested.
+	if ntfnHandlers != nil && ntfnHandlers.OnStakeBlockConnected != nil {
+		client.startStakeBlockNotifier()
+	}
+
+	if config.NtfnDedupWindow > 0 {
+		client.chainTxDedup = newChainTxDedup(config.NtfnDedupWindow)
+	}
+
 	if start {
 		close(connEstablished)
 		client.start()
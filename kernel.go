@@ -0,0 +1,331 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/blockchain"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+const (
+	// peercoinCoin is the number of base units in one peercoin, which is
+	// the unit coin-days are expressed in.
+	peercoinCoin = 1000000
+
+	// secondsPerDay is the number of seconds in a day.
+	secondsPerDay = 24 * 60 * 60
+)
+
+// StakeKernelParams houses the consensus parameters used to evaluate stake
+// kernels.
+type StakeKernelParams struct {
+	// StakeMinAge is the minimum age of an output before it may stake.
+	StakeMinAge time.Duration
+
+	// StakeMaxAge is the age after which the weight of an output stops
+	// increasing.
+	StakeMaxAge time.Duration
+}
+
+// MainNetStakeKernelParams are the stake kernel parameters of the main network.
+var MainNetStakeKernelParams = StakeKernelParams{
+	StakeMinAge: 30 * 24 * time.Hour,
+	StakeMaxAge: 90 * 24 * time.Hour,
+}
+
+// StakeKernel houses the data the proof-of-stake kernel hash of an output is
+// computed from.  Apart from the timestamp of the coinstake transaction, it is
+// fixed for a given output.
+type StakeKernel struct {
+	OutPoint wire.OutPoint
+	Amount   btcutil.Amount
+	PkScript []byte
+
+	// StakeModifier is the kernel stake modifier of the block the output
+	// is mined in, which is called the block from.
+	StakeModifier uint64
+
+	// BlockFromHash and BlockFromTime are the hash and timestamp of the
+	// block the output is mined in.
+	BlockFromHash *wire.ShaHash
+	BlockFromTime time.Time
+
+	// TxPrevOffset is the offset of the transaction creating the output
+	// within the serialized block and TxPrevTime is its timestamp.
+	TxPrevOffset uint32
+	TxPrevTime   time.Time
+}
+
+// Hash returns the proof-of-stake kernel hash of the output for a coinstake
+// transaction with the passed timestamp.
+func (k *StakeKernel) Hash(txTime time.Time) *wire.ShaHash {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, k.StakeModifier)
+	binary.Write(&buf, binary.LittleEndian, uint32(k.BlockFromTime.Unix()))
+	binary.Write(&buf, binary.LittleEndian, k.TxPrevOffset)
+	binary.Write(&buf, binary.LittleEndian, uint32(k.TxPrevTime.Unix()))
+	binary.Write(&buf, binary.LittleEndian, k.OutPoint.Index)
+	binary.Write(&buf, binary.LittleEndian, uint32(txTime.Unix()))
+
+	hash, _ := wire.NewShaHash(wire.DoubleSha256(buf.Bytes()))
+	return hash
+}
+
+// Eligible returns whether or not the output is old enough to stake in a
+// coinstake transaction with the passed timestamp.
+func (k *StakeKernel) Eligible(txTime time.Time, params *StakeKernelParams) bool {
+	if txTime.Before(k.TxPrevTime) {
+		return false
+	}
+	return !k.BlockFromTime.Add(params.StakeMinAge).After(txTime)
+}
+
+// EligibleTime returns the earliest timestamp of a coinstake transaction the
+// output may stake in.
+func (k *StakeKernel) EligibleTime(params *StakeKernelParams) time.Time {
+	eligible := k.BlockFromTime.Add(params.StakeMinAge)
+	if eligible.Before(k.TxPrevTime) {
+		return k.TxPrevTime
+	}
+	return eligible
+}
+
+// CoinDayWeight returns the coin-days the output is weighted with in a
+// coinstake transaction with the passed timestamp.  The age of the output
+// counts up to the maximum stake age, minus the minimum stake age.  The weight
+// is not positive when the output is too young to stake.
+func (k *StakeKernel) CoinDayWeight(txTime time.Time, params *StakeKernelParams) *big.Int {
+	age := int64(txTime.Sub(k.TxPrevTime) / time.Second)
+	maxAge := int64(params.StakeMaxAge / time.Second)
+	if age > maxAge {
+		age = maxAge
+	}
+	timeWeight := age - int64(params.StakeMinAge/time.Second)
+
+	weight := big.NewInt(int64(k.Amount))
+	weight.Mul(weight, big.NewInt(timeWeight))
+	weight.Quo(weight, big.NewInt(peercoinCoin))
+	return weight.Quo(weight, big.NewInt(secondsPerDay))
+}
+
+// Target returns the target the kernel hash of the output must not exceed in a
+// coinstake transaction with the passed timestamp, which is the target per
+// coin-day with the passed compact representation multiplied by the coin-day
+// weight of the output.
+func (k *StakeKernel) Target(bits uint32, txTime time.Time,
+	params *StakeKernelParams) *big.Int {
+
+	target := CompactToTarget(bits)
+	return target.Mul(target, k.CoinDayWeight(txTime, params))
+}
+
+// StakeKernelEvaluation houses the result of evaluating the kernel of an
+// output against a target.
+type StakeKernelEvaluation struct {
+	Kernel        *StakeKernel
+	Time          time.Time
+	Hash          *wire.ShaHash
+	CoinDayWeight *big.Int
+	Target        *big.Int
+
+	// Eligible is set when the output is old enough to stake and Satisfied
+	// is set when its kernel hash also meets the target, in which case it
+	// would stake at Time.
+	Eligible  bool
+	Satisfied bool
+}
+
+// Evaluate evaluates the kernel of the output against the target per coin-day
+// with the passed compact representation for a coinstake transaction with the
+// passed timestamp.
+func (k *StakeKernel) Evaluate(bits uint32, txTime time.Time,
+	params *StakeKernelParams) *StakeKernelEvaluation {
+
+	eval := &StakeKernelEvaluation{
+		Kernel:        k,
+		Time:          txTime,
+		Hash:          k.Hash(txTime),
+		CoinDayWeight: k.CoinDayWeight(txTime, params),
+		Target:        k.Target(bits, txTime, params),
+		Eligible:      k.Eligible(txTime, params),
+	}
+	eval.Satisfied = eval.Eligible && eval.CoinDayWeight.Sign() > 0 &&
+		blockchain.ShaHashToBig(eval.Hash).Cmp(eval.Target) <= 0
+	return eval
+}
+
+// stakeKernelBlock houses the details of a block needed to build the kernels of
+// the outputs mined in it.
+type stakeKernelBlock struct {
+	hash          *wire.ShaHash
+	time          time.Time
+	stakeModifier uint64
+	txOffsets     map[wire.ShaHash]uint32
+}
+
+// getStakeKernelBlock fetches the details of the block with the passed hash.
+func (c *Client) getStakeKernelBlock(blockHash *wire.ShaHash) (*stakeKernelBlock, error) {
+	block, err := c.GetBlock(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	txLocs, err := block.TxLoc()
+	if err != nil {
+		return nil, err
+	}
+	stakeModifier, err := c.GetKernelStakeModifier(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &stakeKernelBlock{
+		hash:          blockHash,
+		time:          block.MsgBlock().Header.Timestamp,
+		stakeModifier: stakeModifier,
+		txOffsets:     make(map[wire.ShaHash]uint32, len(txLocs)),
+	}
+	for i, tx := range block.Transactions() {
+		b.txOffsets[*tx.Sha()] = uint32(txLocs[i].TxStart)
+	}
+	return b, nil
+}
+
+// getStakeKernel returns the kernel of the passed outpoint using the passed
+// cache of blocks, which is updated with any block fetched.
+func (c *Client) getStakeKernel(op *wire.OutPoint,
+	blocks map[wire.ShaHash]*stakeKernelBlock) (*StakeKernel, error) {
+
+	rawTx, err := c.GetRawTransactionVerbose(&op.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if rawTx.BlockHash == "" {
+		return nil, fmt.Errorf("transaction %v is not mined", op.Hash)
+	}
+	tx, err := parseHexTx(rawTx.Hex)
+	if err != nil {
+		return nil, err
+	}
+	txOuts := tx.MsgTx().TxOut
+	if int(op.Index) >= len(txOuts) {
+		return nil, fmt.Errorf("transaction %v has no output %d",
+			op.Hash, op.Index)
+	}
+
+	blockHash, err := wire.NewShaHashFromStr(rawTx.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	block, ok := blocks[*blockHash]
+	if !ok {
+		block, err = c.getStakeKernelBlock(blockHash)
+		if err != nil {
+			return nil, err
+		}
+		blocks[*blockHash] = block
+	}
+	offset, ok := block.txOffsets[op.Hash]
+	if !ok {
+		return nil, fmt.Errorf("transaction %v is not in block %v",
+			op.Hash, blockHash)
+	}
+
+	return &StakeKernel{
+		OutPoint:      *op,
+		Amount:        btcutil.Amount(txOuts[op.Index].Value),
+		PkScript:      txOuts[op.Index].PkScript,
+		StakeModifier: block.stakeModifier,
+		BlockFromHash: blockHash,
+		BlockFromTime: block.time,
+		TxPrevOffset:  offset,
+		TxPrevTime:    tx.MsgTx().Time,
+	}, nil
+}
+
+// GetStakeKernel returns the proof-of-stake kernel of the passed outpoint,
+// which must be mined in the main chain.  It is built from the results of
+// GetRawTransactionVerbose, GetBlock, and GetKernelStakeModifier.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) GetStakeKernel(op *wire.OutPoint) (*StakeKernel, error) {
+	return c.getStakeKernel(op, make(map[wire.ShaHash]*stakeKernelBlock))
+}
+
+// GetStakeKernels returns the proof-of-stake kernels of the passed outpoints,
+// fetching each block they are mined in only once.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) GetStakeKernels(ops []*wire.OutPoint) ([]*StakeKernel, error) {
+	blocks := make(map[wire.ShaHash]*stakeKernelBlock)
+	kernels := make([]*StakeKernel, 0, len(ops))
+	for _, op := range ops {
+		kernel, err := c.getStakeKernel(op, blocks)
+		if err != nil {
+			return nil, err
+		}
+		kernels = append(kernels, kernel)
+	}
+	return kernels, nil
+}
+
+// walletStakeKernels returns the kernels of the confirmed unspent outputs of
+// the wallet.
+func (c *Client) walletStakeKernels() ([]*StakeKernel, error) {
+	unspent, err := c.ListUnspent()
+	if err != nil {
+		return nil, err
+	}
+	ops := make([]*wire.OutPoint, 0, len(unspent))
+	for _, utxo := range unspent {
+		if utxo.Confirmations < 1 {
+			continue
+		}
+		hash, err := wire.NewShaHashFromStr(utxo.TxID)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, wire.NewOutPoint(hash, utxo.Vout))
+	}
+	return c.GetStakeKernels(ops)
+}
+
+// EvaluateStakeKernels evaluates the kernels of the confirmed unspent outputs
+// of the wallet against the target required for the next proof-of-stake block
+// for a coinstake transaction with the passed timestamp, using the main network
+// parameters when params is nil.  This allows predicting which outputs would
+// stake without asking the server to try.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) EvaluateStakeKernels(txTime time.Time,
+	params *StakeKernelParams) ([]*StakeKernelEvaluation, error) {
+
+	if params == nil {
+		params = &MainNetStakeKernelParams
+	}
+	bits, err := c.GetNextRequiredTarget(true)
+	if err != nil {
+		return nil, err
+	}
+	kernels, err := c.walletStakeKernels()
+	if err != nil {
+		return nil, err
+	}
+
+	evals := make([]*StakeKernelEvaluation, 0, len(kernels))
+	for _, kernel := range kernels {
+		evals = append(evals, kernel.Evaluate(bits, txTime, params))
+	}
+	return evals, nil
+}
@@ -0,0 +1,570 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// MempoolTx describes a transaction in a MempoolView.
+type MempoolTx struct {
+	Tx   *btcutil.Tx
+	Size int32
+
+	// Fee is the fee paid by the transaction.  It is only set when
+	// FeeKnown is true, which is not the case when the value of an input
+	// could not be looked up.
+	Fee      btcutil.Amount
+	FeeKnown bool
+
+	// Time and Height are the time the transaction entered the memory pool
+	// and the height of the best block at that time.
+	Time   time.Time
+	Height int32
+}
+
+// FeeRate returns the fee paid by the transaction per kilobyte.  It is zero
+// when the fee is not known.
+func (tx *MempoolTx) FeeRate() btcutil.Amount {
+	if !tx.FeeKnown || tx.Size <= 0 {
+		return 0
+	}
+	return tx.Fee * 1000 / btcutil.Amount(tx.Size)
+}
+
+// MempoolStats houses size and fee statistics about the transactions in a
+// MempoolView.  The fee statistics only cover the transactions with a known
+// fee.
+type MempoolStats struct {
+	Count         int
+	TotalSize     int64
+	TotalFee      btcutil.Amount
+	MinFeeRate    btcutil.Amount
+	MedianFeeRate btcutil.Amount
+	MaxFeeRate    btcutil.Amount
+}
+
+// MempoolView is a local mirror of the memory pool of the RPC server.  It is
+// seeded with GetRawMempoolVerbose and then kept up to date with the transaction
+// accepted and block notifications, so lookups do not issue any requests.
+// Transactions are evicted once they are mined in a block, along with any
+// transactions which conflict with the block.
+//
+// The notifications are processed by a goroutine owned by the view, so the view
+// never blocks the notification handlers.  Since the RPC server does not notify
+// about transactions which expire from its memory pool, or which are returned
+// to it by a block being disconnected, the view is synced again from scratch
+// when a block is disconnected and callers can call Sync to do so at any time,
+// such as after a reconnect.  The view is also synced when it falls so far
+// behind the notifications that some of them are dropped.
+type MempoolView struct {
+	client   *Client
+	queue    *ntfnQueue
+	syncs    chan chan error
+	quit     chan struct{}
+	quitOnce sync.Once
+	wg       sync.WaitGroup
+
+	mtx   sync.RWMutex
+	txs   map[wire.ShaHash]*MempoolTx
+	spent map[wire.OutPoint]wire.ShaHash
+}
+
+// Enforce MempoolView satisfies the ntfnObserver interface.
+var _ ntfnObserver = (*MempoolView)(nil)
+
+// NewMempoolView returns a new view of the memory pool of the RPC server.  The
+// client is registered for block and transaction accepted notifications if it
+// is not already, and the view is seeded before it is returned.  The client
+// must have been created with notification handlers, although none of the
+// handlers need to be set.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) NewMempoolView() (*MempoolView, error) {
+	if c.ntfnHandlers == nil {
+		return nil, ErrNoNotificationHandlers
+	}
+
+	v := &MempoolView{
+		client: c,
+		queue:  newNtfnQueue(),
+		syncs:  make(chan chan error),
+		quit:   make(chan struct{}),
+		txs:    make(map[wire.ShaHash]*MempoolTx),
+		spent:  make(map[wire.OutPoint]wire.ShaHash),
+	}
+
+	// Start observing the notifications before seeding the view so no
+	// changes are missed in between.  Notifications about transactions
+	// which are already part of the seed are harmless.
+	c.ntfnObservers.add(v)
+	if err := v.register(); err != nil {
+		c.ntfnObservers.remove(v)
+		return nil, err
+	}
+	if err := v.sync(); err != nil {
+		c.ntfnObservers.remove(v)
+		return nil, err
+	}
+
+	v.wg.Add(1)
+	go v.handler()
+	return v, nil
+}
+
+// register registers the client for the notifications the view relies on
+// without changing the verbosity of any previously registered transaction
+// accepted notifications.
+func (v *MempoolView) register() error {
+	c := v.client
+	if err := c.NotifyBlocks(); err != nil {
+		return err
+	}
+
+	c.ntfnState.Lock()
+	notifyNewTx := c.ntfnState.notifyNewTx || c.ntfnState.notifyNewTxVerbose
+	c.ntfnState.Unlock()
+	if notifyNewTx {
+		return nil
+	}
+	return c.NotifyNewTransactions(false)
+}
+
+// observeNotification queues the notifications which affect the memory pool
+// for processing by the view goroutine.  It is part of the ntfnObserver
+// interface implementation.
+func (v *MempoolView) observeNotification(ntfn *rawNotification) {
+	switch ntfn.Method {
+	case btcjson.TxAcceptedNtfnMethod, btcjson.TxAcceptedVerboseNtfnMethod,
+		btcjson.BlockConnectedNtfnMethod,
+		btcjson.BlockDisconnectedNtfnMethod:
+
+		v.queue.push(ntfn)
+	}
+}
+
+// handler processes the queued notifications and sync requests.  It must be
+// run as a goroutine.
+func (v *MempoolView) handler() {
+out:
+	for {
+		select {
+		case <-v.queue.signal:
+			// Sync the view from scratch when notifications
+			// were dropped since it fell too far behind.
+			ntfns, dropped := v.queue.popAll()
+			if dropped > 0 {
+				log.Warnf("Memory pool view dropped %d "+
+					"notifications, syncing", dropped)
+				if err := v.sync(); err != nil {
+					log.Warnf("Unable to sync memory pool "+
+						"view: %v", err)
+				}
+			}
+			for _, ntfn := range ntfns {
+				v.handleNotification(ntfn)
+			}
+
+		case errChan := <-v.syncs:
+			errChan <- v.sync()
+
+		case <-v.quit:
+			break out
+
+		case <-v.client.shutdown:
+			break out
+		}
+	}
+	v.wg.Done()
+}
+
+// handleNotification updates the view for the passed notification.  Errors are
+// logged rather than returned since the view will be corrected by the next
+// sync.
+func (v *MempoolView) handleNotification(ntfn *rawNotification) {
+	var err error
+	switch ntfn.Method {
+	case btcjson.TxAcceptedNtfnMethod:
+		var txHash *wire.ShaHash
+		txHash, _, err = parseTxAcceptedNtfnParams(ntfn.Params)
+		if err != nil {
+			break
+		}
+		var tx *btcutil.Tx
+		tx, err = v.client.GetRawTransaction(txHash)
+		if err != nil {
+			break
+		}
+		err = v.addTx(tx)
+
+	case btcjson.TxAcceptedVerboseNtfnMethod:
+		var rawTx *btcjson.TxRawResult
+		rawTx, err = parseTxAcceptedVerboseNtfnParams(ntfn.Params)
+		if err != nil {
+			break
+		}
+		var tx *btcutil.Tx
+		tx, err = parseHexTx(rawTx.Hex)
+		if err != nil {
+			break
+		}
+		err = v.addTx(tx)
+
+	case btcjson.BlockConnectedNtfnMethod:
+		var blockHash *wire.ShaHash
+		blockHash, _, _, err = parseChainNtfnParams(ntfn.Params)
+		if err != nil {
+			break
+		}
+		var block *btcutil.Block
+		block, err = v.client.GetBlock(blockHash)
+		if err != nil {
+			break
+		}
+		v.connectBlock(block)
+
+	case btcjson.BlockDisconnectedNtfnMethod:
+		err = v.sync()
+	}
+	if err != nil {
+		log.Warnf("Unable to update memory pool view for [%s]: %v",
+			ntfn.Method, err)
+	}
+}
+
+// inputValue returns the value of the output spent by the passed outpoint.
+// Outputs of transactions in the view are looked up locally, and the RPC server
+// is asked about confirmed outputs.
+func (v *MempoolView) inputValue(op *wire.OutPoint) (btcutil.Amount, bool, error) {
+	v.mtx.RLock()
+	parent, ok := v.txs[op.Hash]
+	v.mtx.RUnlock()
+	if ok {
+		txOuts := parent.Tx.MsgTx().TxOut
+		if int(op.Index) >= len(txOuts) {
+			return 0, false, nil
+		}
+		return btcutil.Amount(txOuts[op.Index].Value), true, nil
+	}
+
+	txOut, err := v.client.GetTxOut(&op.Hash, op.Index, false)
+	if err != nil {
+		return 0, false, err
+	}
+	if txOut == nil {
+		return 0, false, nil
+	}
+	return btcutil.Amount(txOut.Value), true, nil
+}
+
+// addTx adds the passed newly accepted transaction to the view.
+func (v *MempoolView) addTx(tx *btcutil.Tx) error {
+	v.mtx.RLock()
+	_, ok := v.txs[*tx.Sha()]
+	v.mtx.RUnlock()
+	if ok {
+		return nil
+	}
+
+	msgTx := tx.MsgTx()
+	mtx := &MempoolTx{
+		Tx:       tx,
+		Size:     int32(msgTx.SerializeSize()),
+		FeeKnown: true,
+		Time:     time.Now(),
+	}
+	_, mtx.Height = v.client.LastSeenBlock()
+
+	var totalIn btcutil.Amount
+	for _, txIn := range msgTx.TxIn {
+		value, ok, err := v.inputValue(&txIn.PreviousOutPoint)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			mtx.FeeKnown = false
+			break
+		}
+		totalIn += value
+	}
+	if mtx.FeeKnown {
+		var totalOut btcutil.Amount
+		for _, txOut := range msgTx.TxOut {
+			totalOut += btcutil.Amount(txOut.Value)
+		}
+		mtx.Fee = totalIn - totalOut
+	}
+
+	v.mtx.Lock()
+	v.insertTx(mtx)
+	v.mtx.Unlock()
+	return nil
+}
+
+// insertTx adds the passed transaction to the view and indexes the outpoints
+// it spends.
+//
+// This function MUST be called with the view lock held (for writes).
+func (v *MempoolView) insertTx(mtx *MempoolTx) {
+	txHash := *mtx.Tx.Sha()
+	v.txs[txHash] = mtx
+	for _, txIn := range mtx.Tx.MsgTx().TxIn {
+		v.spent[txIn.PreviousOutPoint] = txHash
+	}
+}
+
+// removeTx removes the transaction with the passed hash from the view.  When
+// removeRedeemers is true, all transactions spending its outputs are removed as
+// well since they can no longer be mined.
+//
+// This function MUST be called with the view lock held (for writes).
+func (v *MempoolView) removeTx(txHash *wire.ShaHash, removeRedeemers bool) {
+	mtx, ok := v.txs[*txHash]
+	if !ok {
+		return
+	}
+
+	msgTx := mtx.Tx.MsgTx()
+	if removeRedeemers {
+		for i := range msgTx.TxOut {
+			op := wire.OutPoint{Hash: *txHash, Index: uint32(i)}
+			if redeemer, ok := v.spent[op]; ok {
+				v.removeTx(&redeemer, true)
+			}
+		}
+	}
+
+	for _, txIn := range msgTx.TxIn {
+		if spender, ok := v.spent[txIn.PreviousOutPoint]; ok &&
+			spender.IsEqual(txHash) {
+
+			delete(v.spent, txIn.PreviousOutPoint)
+		}
+	}
+	delete(v.txs, *txHash)
+}
+
+// connectBlock evicts the transactions mined in the passed block along with any
+// transactions which double spend them.
+func (v *MempoolView) connectBlock(block *btcutil.Block) {
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+
+	for _, tx := range block.Transactions() {
+		v.removeTx(tx.Sha(), false)
+		for _, txIn := range tx.MsgTx().TxIn {
+			if spender, ok := v.spent[txIn.PreviousOutPoint]; ok {
+				log.Debugf("Evicting transaction %v double spent "+
+					"by %v", spender, tx.Sha())
+				v.removeTx(&spender, true)
+			}
+		}
+	}
+}
+
+// sync replaces the contents of the view with the current memory pool of the
+// RPC server.  Transactions which are already in the view are kept as is.
+func (v *MempoolView) sync() error {
+	entries, err := v.client.GetRawMempoolVerbose()
+	if err != nil {
+		return err
+	}
+
+	// Keep the transactions which are already in the view and request the
+	// others at once.
+	type fetch struct {
+		txHash wire.ShaHash
+		entry  GetRawMempoolVerboseResult
+		future FutureGetRawTransactionResult
+	}
+	txs := make(map[wire.ShaHash]*MempoolTx, len(entries))
+	var fetches []fetch
+	for txHashStr, entry := range entries {
+		txHash, err := wire.NewShaHashFromStr(txHashStr)
+		if err != nil {
+			return err
+		}
+
+		v.mtx.RLock()
+		mtx, ok := v.txs[*txHash]
+		v.mtx.RUnlock()
+		if ok {
+			txs[*txHash] = mtx
+			continue
+		}
+		fetches = append(fetches, fetch{
+			txHash: *txHash,
+			entry:  entry,
+			future: v.client.GetRawTransactionAsync(txHash),
+		})
+	}
+
+	for _, fetch := range fetches {
+		// The transaction might have been mined or evicted since the
+		// memory pool was fetched, so lookup failures are not fatal.
+		tx, err := fetch.future.Receive()
+		if err != nil {
+			log.Debugf("Unable to fetch memory pool transaction "+
+				"%v: %v", fetch.txHash, err)
+			continue
+		}
+		entry := fetch.entry
+		txs[fetch.txHash] = &MempoolTx{
+			Tx:       tx,
+			Size:     entry.Size,
+			Fee:      btcutil.Amount(entry.Fee),
+			FeeKnown: true,
+			Time:     time.Unix(entry.Time, 0),
+			Height:   int32(entry.Height),
+		}
+	}
+
+	v.mtx.Lock()
+	v.txs = make(map[wire.ShaHash]*MempoolTx, len(txs))
+	v.spent = make(map[wire.OutPoint]wire.ShaHash)
+	for _, mtx := range txs {
+		v.insertTx(mtx)
+	}
+	v.mtx.Unlock()
+	return nil
+}
+
+// Sync replaces the contents of the view with the current memory pool of the
+// RPC server.  This corrects the view for any changes the RPC server does not
+// notify about, such as transactions expiring from its memory pool, and any
+// notifications missed while the client was disconnected.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (v *MempoolView) Sync() error {
+	errChan := make(chan error, 1)
+	select {
+	case v.syncs <- errChan:
+	case <-v.quit:
+		return ErrClientShutdown
+	case <-v.client.shutdown:
+		return ErrClientShutdown
+	}
+	return <-errChan
+}
+
+// Stop stops updating the view.  The view may still be queried afterwards.
+func (v *MempoolView) Stop() {
+	v.quitOnce.Do(func() {
+		v.client.ntfnObservers.remove(v)
+		close(v.quit)
+	})
+	v.wg.Wait()
+}
+
+// Tx returns the transaction with the passed hash and whether or not it is in
+// the view.
+//
+// This function is safe for concurrent access.
+func (v *MempoolView) Tx(txHash *wire.ShaHash) (MempoolTx, bool) {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	mtx, ok := v.txs[*txHash]
+	if !ok {
+		return MempoolTx{}, false
+	}
+	return *mtx, true
+}
+
+// SpentBy returns the hash of the transaction in the view which spends the
+// passed outpoint, if any.  This allows checking a transaction for double
+// spends before broadcasting it.
+//
+// This function is safe for concurrent access.
+func (v *MempoolView) SpentBy(op *wire.OutPoint) (*wire.ShaHash, bool) {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	spender, ok := v.spent[*op]
+	if !ok {
+		return nil, false
+	}
+	return &spender, true
+}
+
+// Count returns the number of transactions in the view.
+//
+// This function is safe for concurrent access.
+func (v *MempoolView) Count() int {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	return len(v.txs)
+}
+
+// TxHashes returns the hashes of all transactions in the view.
+//
+// This function is safe for concurrent access.
+func (v *MempoolView) TxHashes() []wire.ShaHash {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	hashes := make([]wire.ShaHash, 0, len(v.txs))
+	for txHash := range v.txs {
+		hashes = append(hashes, txHash)
+	}
+	return hashes
+}
+
+// amountSorter implements sort.Interface to allow a slice of amounts to be
+// sorted.
+type amountSorter []btcutil.Amount
+
+// Len returns the number of amounts in the slice.  It is part of the
+// sort.Interface implementation.
+func (s amountSorter) Len() int {
+	return len(s)
+}
+
+// Swap swaps the amounts at the passed indices.  It is part of the
+// sort.Interface implementation.
+func (s amountSorter) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// Less returns whether the amount with index i should sort before the amount
+// with index j.  It is part of the sort.Interface implementation.
+func (s amountSorter) Less(i, j int) bool {
+	return s[i] < s[j]
+}
+
+// Stats returns size and fee statistics about the transactions in the view.
+//
+// This function is safe for concurrent access.
+func (v *MempoolView) Stats() *MempoolStats {
+	v.mtx.RLock()
+	defer v.mtx.RUnlock()
+
+	stats := &MempoolStats{Count: len(v.txs)}
+	feeRates := make([]btcutil.Amount, 0, len(v.txs))
+	for _, mtx := range v.txs {
+		stats.TotalSize += int64(mtx.Size)
+		if !mtx.FeeKnown {
+			continue
+		}
+		stats.TotalFee += mtx.Fee
+		feeRates = append(feeRates, mtx.FeeRate())
+	}
+	if len(feeRates) > 0 {
+		sort.Sort(amountSorter(feeRates))
+		stats.MinFeeRate = feeRates[0]
+		stats.MedianFeeRate = feeRates[len(feeRates)/2]
+		stats.MaxFeeRate = feeRates[len(feeRates)-1]
+	}
+	return stats
+}
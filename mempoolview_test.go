@@ -0,0 +1,226 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"testing"
+
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// newTestMempoolTx returns a memory pool transaction spending the passed
+// outpoints with a single output of the passed value, along with the passed
+// fee and size.
+func newTestMempoolTx(value int64, fee btcutil.Amount, size int32,
+	prevOuts ...wire.OutPoint) *MempoolTx {
+
+	msgTx := &wire.MsgTx{Version: 1}
+	for _, op := range prevOuts {
+		msgTx.TxIn = append(msgTx.TxIn, &wire.TxIn{
+			PreviousOutPoint: op,
+			Sequence:         wire.MaxTxInSequenceNum,
+		})
+	}
+	msgTx.TxOut = append(msgTx.TxOut, &wire.TxOut{
+		Value:    value,
+		PkScript: []byte{0x51},
+	})
+	return &MempoolTx{
+		Tx:       btcutil.NewTx(msgTx),
+		Size:     size,
+		Fee:      fee,
+		FeeKnown: true,
+	}
+}
+
+// newTestMempoolView returns an empty memory pool view which is not connected
+// to a client.
+func newTestMempoolView() *MempoolView {
+	return &MempoolView{
+		txs:   make(map[wire.ShaHash]*MempoolTx),
+		spent: make(map[wire.OutPoint]wire.ShaHash),
+	}
+}
+
+// TestMempoolViewEviction ensures transactions are removed from the memory pool
+// view along with their redeemers when they are mined or double spent.
+func TestMempoolViewEviction(t *testing.T) {
+	t.Parallel()
+
+	fundingOut := wire.OutPoint{Hash: wire.ShaHash{1}, Index: 0}
+	otherOut := wire.OutPoint{Hash: wire.ShaHash{2}, Index: 0}
+	parent := newTestMempoolTx(900, 100, 250, fundingOut)
+	child := newTestMempoolTx(800, 100, 250,
+		wire.OutPoint{Hash: *parent.Tx.Sha(), Index: 0})
+	unrelated := newTestMempoolTx(500, 100, 250, otherOut)
+	doubleSpend := newTestMempoolTx(950, 50, 250, fundingOut)
+
+	tests := []struct {
+		name    string
+		mined   []*MempoolTx // transactions of a connected block
+		remove  *MempoolTx   // transaction removed with its redeemers
+		present []*MempoolTx
+		absent  []*MempoolTx
+	}{
+		{
+			name:    "parent mined",
+			mined:   []*MempoolTx{parent},
+			present: []*MempoolTx{child, unrelated},
+			absent:  []*MempoolTx{parent},
+		},
+		{
+			name:    "parent double spent",
+			mined:   []*MempoolTx{doubleSpend},
+			present: []*MempoolTx{unrelated},
+			absent:  []*MempoolTx{parent, child},
+		},
+		{
+			name:    "parent removed",
+			remove:  parent,
+			present: []*MempoolTx{unrelated},
+			absent:  []*MempoolTx{parent, child},
+		},
+		{
+			name:    "child removed",
+			remove:  child,
+			present: []*MempoolTx{parent, unrelated},
+			absent:  []*MempoolTx{child},
+		},
+	}
+
+	for _, test := range tests {
+		v := newTestMempoolView()
+		for _, mtx := range []*MempoolTx{parent, child, unrelated} {
+			v.insertTx(mtx)
+		}
+		if spender, ok := v.SpentBy(&fundingOut); !ok ||
+			!spender.IsEqual(parent.Tx.Sha()) {
+
+			t.Errorf("%s: funding output not spent by parent",
+				test.name)
+		}
+
+		if test.mined != nil {
+			msgBlock := &wire.MsgBlock{}
+			for _, mtx := range test.mined {
+				msgBlock.Transactions = append(
+					msgBlock.Transactions, mtx.Tx.MsgTx())
+			}
+			v.connectBlock(btcutil.NewBlock(msgBlock))
+		}
+		if test.remove != nil {
+			v.mtx.Lock()
+			v.removeTx(test.remove.Tx.Sha(), true)
+			v.mtx.Unlock()
+		}
+
+		for _, mtx := range test.present {
+			if _, ok := v.Tx(mtx.Tx.Sha()); !ok {
+				t.Errorf("%s: missing transaction %v",
+					test.name, mtx.Tx.Sha())
+			}
+		}
+		for _, mtx := range test.absent {
+			if _, ok := v.Tx(mtx.Tx.Sha()); ok {
+				t.Errorf("%s: unexpected transaction %v",
+					test.name, mtx.Tx.Sha())
+			}
+			for _, txIn := range mtx.Tx.MsgTx().TxIn {
+				spender, ok := v.SpentBy(&txIn.PreviousOutPoint)
+				if ok && spender.IsEqual(mtx.Tx.Sha()) {
+					t.Errorf("%s: removed transaction %v "+
+						"still spends %v", test.name,
+						mtx.Tx.Sha(),
+						txIn.PreviousOutPoint)
+				}
+			}
+		}
+		if v.Count() != len(test.present) {
+			t.Errorf("%s: got %d transactions, want %d", test.name,
+				v.Count(), len(test.present))
+		}
+	}
+}
+
+// TestMempoolViewStats ensures the statistics of the memory pool view cover
+// the sizes of all transactions and the fees of those with a known fee.
+func TestMempoolViewStats(t *testing.T) {
+	t.Parallel()
+
+	// newTxs returns transactions with the passed fees which all have a
+	// size of 1000 bytes, so the fee rates equal the fees.  Negative fees
+	// describe transactions with an unknown fee.
+	newTxs := func(fees ...btcutil.Amount) []*MempoolTx {
+		txs := make([]*MempoolTx, 0, len(fees))
+		for i, fee := range fees {
+			op := wire.OutPoint{Hash: wire.ShaHash{byte(i + 1)}}
+			mtx := newTestMempoolTx(1000, fee, 1000, op)
+			if fee < 0 {
+				mtx.Fee = 0
+				mtx.FeeKnown = false
+			}
+			txs = append(txs, mtx)
+		}
+		return txs
+	}
+
+	tests := []struct {
+		name  string
+		txs   []*MempoolTx
+		stats MempoolStats
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "odd count",
+			txs:  newTxs(30000, 10000, 20000),
+			stats: MempoolStats{
+				Count:         3,
+				TotalSize:     3000,
+				TotalFee:      60000,
+				MinFeeRate:    10000,
+				MedianFeeRate: 20000,
+				MaxFeeRate:    30000,
+			},
+		},
+		{
+			name: "even count takes upper median",
+			txs:  newTxs(40000, 10000, 30000, 20000),
+			stats: MempoolStats{
+				Count:         4,
+				TotalSize:     4000,
+				TotalFee:      100000,
+				MinFeeRate:    10000,
+				MedianFeeRate: 30000,
+				MaxFeeRate:    40000,
+			},
+		},
+		{
+			name: "unknown fees excluded",
+			txs:  newTxs(-1, 20000, -1),
+			stats: MempoolStats{
+				Count:         3,
+				TotalSize:     3000,
+				TotalFee:      20000,
+				MinFeeRate:    20000,
+				MedianFeeRate: 20000,
+				MaxFeeRate:    20000,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		v := newTestMempoolView()
+		for _, mtx := range test.txs {
+			v.insertTx(mtx)
+		}
+		if stats := v.Stats(); *stats != test.stats {
+			t.Errorf("%s: got %+v, want %+v", test.name, *stats,
+				test.stats)
+		}
+	}
+}
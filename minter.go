@@ -0,0 +1,315 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+const (
+	// defaultMintInterval is the delay between mint attempts when none is
+	// configured.
+	defaultMintInterval = time.Minute
+
+	// defaultCoinStakeFee is the fee paid by the coinstake transactions
+	// built by a Minter when none is configured.
+	defaultCoinStakeFee = btcutil.Amount(peercoinCent)
+
+	// mintAttemptBufferSize is the number of mint attempts which can be
+	// queued before further attempts are dropped.
+	mintAttemptBufferSize = 20
+)
+
+var (
+	// ErrNoMintSigner is an error to describe the condition where a minter
+	// is created without a signer.
+	ErrNoMintSigner = errors.New("no mint signer configured")
+
+	// ErrNoStakeKernel is an error to describe the condition where a mint
+	// attempt found no output whose kernel meets the target.
+	ErrNoStakeKernel = errors.New("no stake kernel found")
+)
+
+// MintSigner is implemented by the signers which hold the keys of the outputs
+// staked by a Minter, such as a local key store or a client of a remote signer.
+// This allows minting without the keys being known to the RPC server.
+type MintSigner interface {
+	// SignCoinStake signs the input of the passed coinstake transaction,
+	// which spends the output described by the passed kernel.  The signer
+	// may also change the outputs paying the staker, such as to pay to a
+	// public key script, as long as their total value is unchanged.
+	SignCoinStake(tx *wire.MsgTx, kernel *StakeKernel) error
+
+	// SignMintBlock returns the signature of the block the RPC server built
+	// for the passed coinstake transaction, as described by the passed
+	// result of SendCoinStakeTransaction, with the key of the output paying
+	// the staker.
+	SignMintBlock(tx *wire.MsgTx,
+		result *btcjson.SendCoinStakeTransactionResult) ([]byte, error)
+}
+
+// MinterConfig describes the configuration of a Minter.
+type MinterConfig struct {
+	// Signer signs the coinstake transactions and the minted blocks.
+	Signer MintSigner
+
+	// Params are the stake kernel parameters.  The main network
+	// parameters are used when it is nil.
+	Params *StakeKernelParams
+
+	// Interval is the delay between mint attempts.  Each attempt searches
+	// the timestamps since the previous attempt for a kernel meeting the
+	// target.  It defaults to one minute.
+	Interval time.Duration
+
+	// CoinStakeFee is the fee paid by the coinstake transactions.  It
+	// defaults to 0.01 peercoin.
+	CoinStakeFee btcutil.Amount
+}
+
+// MintAttempt describes the outcome of a mint attempt.
+type MintAttempt struct {
+	// Time is when the attempt started and Evaluated is the number of
+	// kernels which were evaluated.
+	Time      time.Time
+	Evaluated int
+
+	// Kernel is the kernel which met the target, if any, and CoinStake is
+	// the coinstake transaction built for it.
+	Kernel    *StakeKernel
+	CoinStake *wire.MsgTx
+
+	// CoinStakeResult and SignatureResult are the results of submitting
+	// the coinstake transaction and the block signature respectively.
+	CoinStakeResult *btcjson.SendCoinStakeTransactionResult
+	SignatureResult *btcjson.SendMintBlockSignatureResult
+
+	// Err is the error which stopped the attempt.  It is ErrNoStakeKernel
+	// when no kernel met the target.
+	Err error
+}
+
+// Minted returns whether or not the attempt submitted a signed block.
+func (a *MintAttempt) Minted() bool {
+	return a.Err == nil && a.SignatureResult != nil
+}
+
+// newCoinStake returns a coinstake transaction with the passed timestamp
+// staking the output described by the passed kernel.  It pays the value of the
+// output and the reward, minus the fee, back to the script of the output.
+func newCoinStake(kernel *StakeKernel, txTime time.Time,
+	params *StakeKernelParams, fee btcutil.Amount) *wire.MsgTx {
+
+	reward := ProofOfStakeReward(kernel.CoinAge(txTime, params))
+
+	tx := wire.NewMsgTx()
+	tx.Time = time.Unix(txTime.Unix(), 0)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&kernel.OutPoint.Hash,
+		kernel.OutPoint.Index), nil))
+	tx.AddTxOut(wire.NewTxOut(0, nil))
+	tx.AddTxOut(wire.NewTxOut(int64(kernel.Amount+reward-fee),
+		kernel.PkScript))
+	return tx
+}
+
+// mintBackend houses the requests issued by a mint attempt.  It is implemented
+// by Client and allows the attempts to be tested without a server.
+type mintBackend interface {
+	GetNextRequiredTarget(proofOfStake bool) (uint32, error)
+	walletStakeKernels() ([]*StakeKernel, error)
+	SendCoinStakeTransaction(tx *wire.MsgTx) (*btcjson.SendCoinStakeTransactionResult, error)
+	SendMintBlockSignature(txSha *wire.ShaHash,
+		signature *[]byte) (*btcjson.SendMintBlockSignatureResult, error)
+}
+
+// Minter mints proof-of-stake blocks with the unspent outputs of the wallet of
+// the RPC server while keeping the keys of the outputs off the server.  Each
+// attempt searches for an output whose kernel meets the target of the next
+// proof-of-stake block, builds and signs a coinstake transaction staking it,
+// submits it with SendCoinStakeTransaction, and then signs and submits the
+// block the server built for it with SendMintBlockSignature.
+type Minter struct {
+	client   *Client
+	backend  mintBackend
+	config   MinterConfig
+	attempts chan *MintAttempt
+	quit     chan struct{}
+	wg       sync.WaitGroup
+
+	quitOnce sync.Once
+
+	// mintMtx serializes the mint attempts and protects lastSearch, the
+	// timestamp up to which kernels were searched.  It only advances when
+	// no kernel met the target or a block was minted, so the kernel of an
+	// attempt which failed afterwards is found again by the next one.
+	mintMtx    sync.Mutex
+	lastSearch time.Time
+}
+
+// NewMinter returns a new minter which attempts to mint a block at the
+// configured interval until it is stopped.
+func (c *Client) NewMinter(config *MinterConfig) (*Minter, error) {
+	if config.Signer == nil {
+		return nil, ErrNoMintSigner
+	}
+
+	m := &Minter{
+		client:   c,
+		backend:  c,
+		config:   *config,
+		attempts: make(chan *MintAttempt, mintAttemptBufferSize),
+		quit:     make(chan struct{}),
+	}
+	if m.config.Params == nil {
+		m.config.Params = &MainNetStakeKernelParams
+	}
+	if m.config.Interval <= 0 {
+		m.config.Interval = defaultMintInterval
+	}
+	if m.config.CoinStakeFee <= 0 {
+		m.config.CoinStakeFee = defaultCoinStakeFee
+	}
+
+	m.wg.Add(1)
+	go m.minter()
+	return m, nil
+}
+
+// Attempts returns a channel on which the outcome of each mint attempt is
+// delivered.  The channel is closed once the minter is stopped.  Attempts are
+// dropped when the channel buffer is full, so callers which are not interested
+// in them do not need to drain it.
+func (m *Minter) Attempts() <-chan *MintAttempt {
+	return m.attempts
+}
+
+// Stop stops the minter once the current attempt finishes.
+func (m *Minter) Stop() {
+	m.quitOnce.Do(func() { close(m.quit) })
+	m.wg.Wait()
+}
+
+// minter runs a mint attempt at the configured interval.  It must be run as a
+// goroutine.
+func (m *Minter) minter() {
+	defer m.wg.Done()
+	defer close(m.attempts)
+
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+	for {
+		attempt := m.mint()
+		switch {
+		case attempt.Minted():
+			log.Infof("Minted block with coinstake %v",
+				attempt.CoinStake.TxSha())
+		case attempt.Err != ErrNoStakeKernel:
+			log.Warnf("Mint attempt failed: %v", attempt.Err)
+		}
+		select {
+		case m.attempts <- attempt:
+		default:
+		}
+
+		select {
+		case <-ticker.C:
+		case <-m.quit:
+			return
+		case <-m.client.shutdown:
+			return
+		}
+	}
+}
+
+// MintOnce runs a single mint attempt immediately and returns its outcome.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (m *Minter) MintOnce() *MintAttempt {
+	return m.mint()
+}
+
+// mint runs a single mint attempt.
+func (m *Minter) mint() *MintAttempt {
+	m.mintMtx.Lock()
+	defer m.mintMtx.Unlock()
+
+	c := m.backend
+	params := m.config.Params
+	attempt := &MintAttempt{Time: time.Now()}
+
+	// Search the timestamps since the previous attempt, or for the last
+	// interval on the first attempt.
+	searchEnd := time.Unix(attempt.Time.Unix(), 0)
+	searchStart := m.lastSearch.Add(time.Second)
+	if m.lastSearch.IsZero() || searchEnd.Sub(searchStart) > m.config.Interval {
+		searchStart = searchEnd.Add(-m.config.Interval)
+	}
+
+	bits, err := c.GetNextRequiredTarget(true)
+	if err != nil {
+		attempt.Err = err
+		return attempt
+	}
+	kernels, err := c.walletStakeKernels()
+	if err != nil {
+		attempt.Err = err
+		return attempt
+	}
+
+	var txTime time.Time
+search:
+	for t := searchEnd; !t.Before(searchStart); t = t.Add(-time.Second) {
+		for _, kernel := range kernels {
+			attempt.Evaluated++
+			if kernel.Evaluate(bits, t, params).Satisfied {
+				attempt.Kernel = kernel
+				txTime = t
+				break search
+			}
+		}
+	}
+	if attempt.Kernel == nil {
+		m.lastSearch = searchEnd
+		attempt.Err = ErrNoStakeKernel
+		return attempt
+	}
+
+	tx := newCoinStake(attempt.Kernel, txTime, params,
+		m.config.CoinStakeFee)
+	attempt.CoinStake = tx
+	if err := m.config.Signer.SignCoinStake(tx, attempt.Kernel); err != nil {
+		attempt.Err = err
+		return attempt
+	}
+
+	attempt.CoinStakeResult, err = c.SendCoinStakeTransaction(tx)
+	if err != nil {
+		attempt.Err = err
+		return attempt
+	}
+
+	signature, err := m.config.Signer.SignMintBlock(tx,
+		attempt.CoinStakeResult)
+	if err != nil {
+		attempt.Err = err
+		return attempt
+	}
+	txSha := tx.TxSha()
+	attempt.SignatureResult, err = c.SendMintBlockSignature(&txSha,
+		&signature)
+	if err != nil {
+		attempt.Err = err
+		return attempt
+	}
+	m.lastSearch = searchEnd
+	return attempt
+}
@@ -0,0 +1,269 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// TestNewCoinStake ensures coinstake transactions spend the kernel output and
+// pay its value and the proof-of-stake reward, minus the fee, back to its
+// script after an empty first output.
+func TestNewCoinStake(t *testing.T) {
+	t.Parallel()
+
+	day := 24 * time.Hour
+	params := &MainNetStakeKernelParams
+	txPrevTime := time.Unix(1400000000, 0)
+	tests := []struct {
+		name   string
+		amount btcutil.Amount
+		age    time.Duration
+		fee    btcutil.Amount
+		value  int64
+	}{
+		// 365000 coin-days earn 999 coin-years worth of reward.
+		{"one year", 1000 * peercoinCoin, 365 * day, peercoinCent,
+			1000*peercoinCoin + 999*rewardCoinYear - peercoinCent},
+		// 3000 coin-days earn 8 coin-years worth of reward.
+		{"minimum age", 100 * peercoinCoin, 30 * day, peercoinCent,
+			100*peercoinCoin + 8*rewardCoinYear - peercoinCent},
+		{"no fee", 100 * peercoinCoin, 30 * day, 0,
+			100*peercoinCoin + 8*rewardCoinYear},
+	}
+
+	for _, test := range tests {
+		kernel := &StakeKernel{
+			OutPoint:      *wire.NewOutPoint(&wire.ShaHash{1}, 2),
+			Amount:        test.amount,
+			PkScript:      []byte{0x76, 0xa9},
+			BlockFromTime: txPrevTime,
+			TxPrevTime:    txPrevTime,
+		}
+
+		// The timestamp is truncated to whole seconds.
+		txTime := txPrevTime.Add(test.age)
+		tx := newCoinStake(kernel, txTime.Add(time.Millisecond), params,
+			test.fee)
+		if !tx.Time.Equal(txTime) {
+			t.Errorf("%s: got time %v, want %v", test.name, tx.Time,
+				txTime)
+		}
+		if len(tx.TxIn) != 1 ||
+			tx.TxIn[0].PreviousOutPoint != kernel.OutPoint {
+
+			t.Errorf("%s: got inputs %v, want one spending %v",
+				test.name, tx.TxIn, kernel.OutPoint)
+			continue
+		}
+		if len(tx.TxOut) != 2 {
+			t.Errorf("%s: got %d outputs, want 2", test.name,
+				len(tx.TxOut))
+			continue
+		}
+		if tx.TxOut[0].Value != 0 || len(tx.TxOut[0].PkScript) != 0 {
+			t.Errorf("%s: got first output %v, want empty",
+				test.name, tx.TxOut[0])
+		}
+		if tx.TxOut[1].Value != test.value {
+			t.Errorf("%s: got value %d, want %d", test.name,
+				tx.TxOut[1].Value, test.value)
+		}
+		if !bytes.Equal(tx.TxOut[1].PkScript, kernel.PkScript) {
+			t.Errorf("%s: got script %x, want %x", test.name,
+				tx.TxOut[1].PkScript, kernel.PkScript)
+		}
+	}
+}
+
+// fakeMintBackend is a mintBackend which replies with fixed kernels and
+// records the submitted coinstake transactions and signatures.
+type fakeMintBackend struct {
+	bits      uint32
+	targetErr error
+	kernels   []*StakeKernel
+
+	coinStakes []*wire.MsgTx
+	signatures [][]byte
+}
+
+func (b *fakeMintBackend) GetNextRequiredTarget(proofOfStake bool) (uint32, error) {
+	return b.bits, b.targetErr
+}
+
+func (b *fakeMintBackend) walletStakeKernels() ([]*StakeKernel, error) {
+	return b.kernels, nil
+}
+
+func (b *fakeMintBackend) SendCoinStakeTransaction(tx *wire.MsgTx) (*btcjson.SendCoinStakeTransactionResult, error) {
+	b.coinStakes = append(b.coinStakes, tx)
+	return &btcjson.SendCoinStakeTransactionResult{}, nil
+}
+
+func (b *fakeMintBackend) SendMintBlockSignature(txSha *wire.ShaHash,
+	signature *[]byte) (*btcjson.SendMintBlockSignatureResult, error) {
+
+	b.signatures = append(b.signatures, *signature)
+	return &btcjson.SendMintBlockSignatureResult{}, nil
+}
+
+// fakeMintSigner is a MintSigner which signs with fixed scripts and signatures
+// unless it is set to fail.
+type fakeMintSigner struct {
+	err error
+}
+
+func (s *fakeMintSigner) SignCoinStake(tx *wire.MsgTx, kernel *StakeKernel) error {
+	if s.err != nil {
+		return s.err
+	}
+	tx.TxIn[0].SignatureScript = []byte{0x01}
+	return nil
+}
+
+func (s *fakeMintSigner) SignMintBlock(tx *wire.MsgTx,
+	result *btcjson.SendCoinStakeTransactionResult) ([]byte, error) {
+
+	return []byte{0x02}, nil
+}
+
+// TestMinterMint ensures mint attempts stop searching at the first kernel which
+// meets the target, and that the searched timestamps are only skipped by the
+// next attempt once no kernel met the target or a block was minted.
+func TestMinterMint(t *testing.T) {
+	t.Parallel()
+
+	// The target is large enough for every eligible kernel to meet it.
+	day := 24 * time.Hour
+	now := time.Now()
+	newKernel := func(index uint32, age time.Duration) *StakeKernel {
+		return &StakeKernel{
+			OutPoint:      *wire.NewOutPoint(&wire.ShaHash{1}, index),
+			Amount:        100 * peercoinCoin,
+			BlockFromTime: now.Add(-age),
+			TxPrevTime:    now.Add(-age),
+		}
+	}
+	young := newKernel(0, 10*day)
+	first := newKernel(1, 60*day)
+	second := newKernel(2, 60*day)
+	backend := &fakeMintBackend{bits: 0x217fffff}
+	signer := &fakeMintSigner{}
+	m := &Minter{
+		backend: backend,
+		config: MinterConfig{
+			Signer:       signer,
+			Params:       &MainNetStakeKernelParams,
+			Interval:     time.Minute,
+			CoinStakeFee: peercoinCent,
+		},
+	}
+	searchEnd := func(attempt *MintAttempt) time.Time {
+		return time.Unix(attempt.Time.Unix(), 0)
+	}
+
+	// Nothing is searched when the target is not known.
+	errTarget := errors.New("no target")
+	backend.targetErr = errTarget
+	attempt := m.mint()
+	if attempt.Err != errTarget || attempt.Evaluated != 0 ||
+		!m.lastSearch.IsZero() {
+
+		t.Fatalf("got error %v after %d kernels with last search %v, "+
+			"want %v after none", attempt.Err, attempt.Evaluated,
+			m.lastSearch, errTarget)
+	}
+	backend.targetErr = nil
+
+	// The first attempt searches the timestamps of the last interval.
+	backend.kernels = []*StakeKernel{young}
+	attempt = m.mint()
+	if attempt.Err != ErrNoStakeKernel || attempt.Evaluated != 61 {
+		t.Fatalf("got error %v after %d kernels, want %v after 61",
+			attempt.Err, attempt.Evaluated, ErrNoStakeKernel)
+	}
+	if !m.lastSearch.Equal(searchEnd(attempt)) {
+		t.Fatalf("got last search %v, want %v", m.lastSearch,
+			searchEnd(attempt))
+	}
+
+	// Pretend the attempt ran earlier, so the next attempts search some
+	// timestamps again even when they run within the same second.
+	m.lastSearch = m.lastSearch.Add(-m.config.Interval / 2)
+	lastSearch := m.lastSearch
+
+	// The search stops at the first kernel meeting the target, but the
+	// timestamps are searched again when signing fails.
+	backend.kernels = []*StakeKernel{young, first, second}
+	errSign := errors.New("signer unavailable")
+	signer.err = errSign
+	attempt = m.mint()
+	if attempt.Err != errSign || attempt.Kernel != first ||
+		attempt.Evaluated != 2 {
+
+		t.Fatalf("got error %v with kernel %v after %d kernels, want "+
+			"%v with kernel %v after 2", attempt.Err, attempt.Kernel,
+			attempt.Evaluated, errSign, first)
+	}
+	if !m.lastSearch.Equal(lastSearch) {
+		t.Fatalf("got last search %v after failure, want %v",
+			m.lastSearch, lastSearch)
+	}
+	if len(backend.coinStakes) != 0 {
+		t.Fatalf("submitted %d coinstakes without signature",
+			len(backend.coinStakes))
+	}
+
+	// A block is minted with the first kernel at the latest timestamp.
+	signer.err = nil
+	attempt = m.mint()
+	if !attempt.Minted() || attempt.Kernel != first ||
+		attempt.Evaluated != 2 {
+
+		t.Fatalf("got error %v with kernel %v after %d kernels, want "+
+			"block with kernel %v after 2", attempt.Err,
+			attempt.Kernel, attempt.Evaluated, first)
+	}
+	if len(backend.coinStakes) != 1 || backend.coinStakes[0] != attempt.CoinStake {
+		t.Fatalf("got coinstakes %v, want %v", backend.coinStakes,
+			attempt.CoinStake)
+	}
+	tx := attempt.CoinStake
+	if !tx.Time.Equal(searchEnd(attempt)) ||
+		tx.TxIn[0].PreviousOutPoint != first.OutPoint ||
+		!bytes.Equal(tx.TxIn[0].SignatureScript, []byte{0x01}) {
+
+		t.Fatalf("got coinstake at %v spending %v with script %x, "+
+			"want signed at %v spending %v", tx.Time,
+			tx.TxIn[0].PreviousOutPoint, tx.TxIn[0].SignatureScript,
+			searchEnd(attempt), first.OutPoint)
+	}
+	if len(backend.signatures) != 1 ||
+		!bytes.Equal(backend.signatures[0], []byte{0x02}) {
+
+		t.Fatalf("got signatures %x, want 02", backend.signatures)
+	}
+	if !m.lastSearch.Equal(searchEnd(attempt)) {
+		t.Fatalf("got last search %v, want %v", m.lastSearch,
+			searchEnd(attempt))
+	}
+	lastSearch = m.lastSearch
+
+	// The next attempt only searches the timestamps after the last one.
+	backend.kernels = []*StakeKernel{young}
+	attempt = m.mint()
+	want := int(searchEnd(attempt).Sub(lastSearch) / time.Second)
+	if attempt.Err != ErrNoStakeKernel || attempt.Evaluated != want {
+		t.Fatalf("got error %v after %d kernels, want %v after %d",
+			attempt.Err, attempt.Evaluated, ErrNoStakeKernel, want)
+	}
+}
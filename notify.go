@@ -37,6 +37,33 @@ type notificationState struct {
 	notifyNewTxVerbose bool
 	notifyReceived     map[string]struct{}
 	notifySpent        map[btcjson.OutPoint]struct{}
+
+	// watchedReceived and watchedSpent hold the addresses and outpoints
+	// which are watched by the server without being part of the state that
+	// is re-established on reconnect.  This includes those passed to
+	// rescans and the outpoints the server automatically watches after
+	// notifying about funds received to a registered address.  They are
+	// only used to determine which parts of a transaction matched when
+	// delivering detailed transaction notifications.  Outpoints are
+	// removed once they are spent in a block, and both sets are bounded.
+	watchedReceived *watchedSet
+	watchedSpent    *watchedSet
+
+	// sharedReceived and sharedSpent count the references held by the
+	// observers of the client, such as AddressWatcher and FanoutServer, to
+	// the address and outpoint registrations they made, so the
+	// registrations are released once no observer needs them anymore.
+	// Registrations made directly with NotifyReceived and NotifySpent are
+	// never released by the observers, so they are not counted.
+	sharedReceived map[string]int
+	sharedSpent    map[btcjson.OutPoint]int
+
+	// txFilterLoaded is set once a transaction filter has been loaded with
+	// LoadTxFilter, in which case the filter made up of txFilterAddrs and
+	// txFilterOutPoints is reloaded on reconnect.
+	txFilterLoaded    bool
+	txFilterAddrs     map[string]struct{}
+	txFilterOutPoints map[btcjson.OutPoint]struct{}
 }
 
 // Copy returns a deep copy of the receiver.
@@ -55,15 +82,64 @@ func (s *notificationState) Copy() *notificationState {
 	for op := range s.notifySpent {
 		stateCopy.notifySpent[op] = struct{}{}
 	}
+	stateCopy.watchedReceived = s.watchedReceived.copy()
+	stateCopy.watchedSpent = s.watchedSpent.copy()
+	stateCopy.sharedReceived = nil
+	stateCopy.sharedSpent = nil
+	stateCopy.txFilterAddrs = make(map[string]struct{})
+	for addr := range s.txFilterAddrs {
+		stateCopy.txFilterAddrs[addr] = struct{}{}
+	}
+	stateCopy.txFilterOutPoints = make(map[btcjson.OutPoint]struct{})
+	for op := range s.txFilterOutPoints {
+		stateCopy.txFilterOutPoints[op] = struct{}{}
+	}
 
 	return &stateCopy
 }
 
+// isWatchedAddress returns whether or not the passed encoded address has been
+// registered with NotifyReceived, loaded into the transaction filter, or passed
+// to a rescan.
+//
+// This function MUST be called with the state lock held.
+func (s *notificationState) isWatchedAddress(addr string) bool {
+	if _, ok := s.notifyReceived[addr]; ok {
+		return true
+	}
+	if _, ok := s.txFilterAddrs[addr]; ok {
+		return true
+	}
+	return s.watchedReceived.contains(addr)
+}
+
+// isWatchedOutPoint returns whether or not the passed outpoint has been
+// registered with NotifySpent, loaded into the transaction filter, passed to a
+// rescan, or automatically watched by the server after funds were received to
+// a registered address.
+//
+// This function MUST be called with the state lock held.
+func (s *notificationState) isWatchedOutPoint(op btcjson.OutPoint) bool {
+	if _, ok := s.notifySpent[op]; ok {
+		return true
+	}
+	if _, ok := s.txFilterOutPoints[op]; ok {
+		return true
+	}
+	return s.watchedSpent.contains(op)
+}
+
 // newNotificationState returns a new notification state ready to be populated.
 func newNotificationState() *notificationState {
 	return &notificationState{
-		notifyReceived: make(map[string]struct{}),
-		notifySpent:    make(map[btcjson.OutPoint]struct{}),
+		notifyReceived:    make(map[string]struct{}),
+		notifySpent:       make(map[btcjson.OutPoint]struct{}),
+		watchedReceived:   newWatchedSet(maxWatchedEntries),
+		watchedSpent:      newWatchedSet(maxWatchedEntries),
+		sharedReceived:    make(map[string]int),
+		sharedSpent:       make(map[btcjson.OutPoint]int),
+		txFilterAddrs:     make(map[string]struct{}),
+		txFilterOutPoints: make(map[btcjson.OutPoint]struct{}),
 	}
 }
 
@@ -98,6 +174,16 @@ type NotificationHandlers struct {
 	// function is non-nil.
 	OnBlockConnected func(hash *wire.ShaHash, height int32, t time.Time)
 
+	// OnStakeBlockConnected is invoked under the same conditions as
+	// OnBlockConnected, but delivers the details of the block, such as
+	// whether it is a proof-of-stake or proof-of-work block, the outputs
+	// paying the staker, the stake modifier, and the minted amount.  The
+	// details are fetched from the server by a separate goroutine, so this
+	// callback is run async with the rest of the notification handlers
+	// and is safe for blocking client requests.  It is invoked in the
+	// order the blocks are connected.
+	OnStakeBlockConnected func(block *StakeBlockNtfn)
+
 	// OnBlockDisconnected is invoked when a block is disconnected from the
 	// longest (best) chain.  It will only be invoked if a preceding call to
 	// NotifyBlocks has been made to register for the notification and the
@@ -123,6 +209,20 @@ type NotificationHandlers struct {
 	// this to invoked indirectly as the result of a NotifyReceived call.
 	OnRedeemingTx func(transaction *btcutil.Tx, details *btcjson.BlockDetails)
 
+	// OnRecvTxDetails is invoked under the same conditions as OnRecvTx,
+	// but delivers the transaction along with typed details about the
+	// outputs paying to registered addresses, the inputs spending
+	// registered outpoints, and the block the transaction is mined in.
+	// Both handlers are invoked when both are non-nil.
+	OnRecvTxDetails func(ntfn *ChainTxNtfn)
+
+	// OnRedeemingTxDetails is invoked under the same conditions as
+	// OnRedeemingTx, but delivers the transaction along with typed details
+	// about the inputs spending registered outpoints, the outputs paying
+	// to registered addresses, and the block the transaction is mined in.
+	// Both handlers are invoked when both are non-nil.
+	OnRedeemingTxDetails func(ntfn *ChainTxNtfn)
+
 	// OnRescanFinished is invoked after a rescan finishes due to a previous
 	// call to Rescan or RescanEndHeight.  Finished rescans should be
 	// signaled on this notification, rather than relying on the return
@@ -147,6 +247,35 @@ type NotificationHandlers struct {
 	// made to register for the notification and the function is non-nil.
 	OnTxAcceptedVerbose func(txDetails *btcjson.TxRawResult)
 
+	// OnTxAcceptedDetails is invoked under the same conditions as
+	// OnTxAcceptedVerbose, but delivers the deserialized transaction along
+	// with its decoded outputs and, when the values of all inputs are
+	// known to the client, the fee it pays.  Both handlers are invoked
+	// when both are non-nil.
+	OnTxAcceptedDetails func(ntfn *TxAcceptedNtfn)
+
+	// OnRelevantTxAccepted is invoked when a transaction which pays to or
+	// spends from the transaction filter is accepted into the memory pool.
+	// It will only be invoked if a preceding call to LoadTxFilter has been
+	// made to load the filter and the function is non-nil.
+	OnRelevantTxAccepted func(transaction *btcutil.Tx)
+
+	// OnFilteredBlockConnected is invoked when a block is connected to the
+	// longest (best) chain.  The passed transactions are those in the
+	// block which pay to or spend from the transaction filter.  It will
+	// only be invoked if a preceding call to NotifyBlocks has been made to
+	// register for the notification, the server supports the transaction
+	// filter, and the function is non-nil.
+	OnFilteredBlockConnected func(height int32, header *wire.BlockHeader,
+		txns []*btcutil.Tx)
+
+	// OnFilteredBlockDisconnected is invoked when a block is disconnected
+	// from the longest (best) chain.  It will only be invoked if a
+	// preceding call to NotifyBlocks has been made to register for the
+	// notification, the server supports the transaction filter, and the
+	// function is non-nil.
+	OnFilteredBlockDisconnected func(height int32, header *wire.BlockHeader)
+
 	// OnBtcdConnected is invoked when a wallet connects or disconnects from
 	// btcd.
 	//
@@ -185,15 +314,12 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 		return
 	}
 
+	// Let the components observing all notifications see it first.
+	c.ntfnObservers.notify(ntfn)
+
 	switch ntfn.Method {
 	// OnBlockConnected
 	case btcjson.BlockConnectedNtfnMethod:
-		// Ignore the notification if the client is not interested in
-		// it.
-		if c.ntfnHandlers.OnBlockConnected == nil {
-			return
-		}
-
 		blockSha, blockHeight, blockTime, err := parseChainNtfnParams(ntfn.Params)
 		if err != nil {
 			log.Warnf("Received invalid block connected "+
@@ -201,30 +327,51 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 			return
 		}
 
-		c.ntfnHandlers.OnBlockConnected(blockSha, blockHeight, blockTime)
+		// Track the block regardless of whether or not the client is
+		// interested in the notification so missed blocks can be
+		// replayed on reconnect.
+		c.chainState.connectBlock(blockSha, blockHeight, blockTime)
 
-	// OnBlockDisconnected
-	case btcjson.BlockDisconnectedNtfnMethod:
 		// Ignore the notification if the client is not interested in
 		// it.
-		if c.ntfnHandlers.OnBlockDisconnected == nil {
+		if c.ntfnHandlers.OnBlockConnected == nil {
 			return
 		}
 
+		c.ntfnHandlers.OnBlockConnected(blockSha, blockHeight, blockTime)
+
+	// OnBlockDisconnected
+	case btcjson.BlockDisconnectedNtfnMethod:
 		blockSha, blockHeight, blockTime, err := parseChainNtfnParams(ntfn.Params)
 		if err != nil {
-			log.Warnf("Received invalid block connected "+
+			log.Warnf("Received invalid block disconnected "+
 				"notification: %v", err)
 			return
 		}
 
-		c.ntfnHandlers.OnBlockDisconnected(blockSha, blockHeight, blockTime)
+		// Track the block regardless of whether or not the client is
+		// interested in the notification so missed blocks can be
+		// replayed on reconnect.
+		c.chainState.disconnectBlock(blockHeight)
+		if c.chainTxDedup != nil {
+			c.chainTxDedup.disconnectBlock(blockSha.String())
+		}
 
-	// OnRecvTx
-	case btcjson.RecvTxNtfnMethod:
 		// Ignore the notification if the client is not interested in
 		// it.
-		if c.ntfnHandlers.OnRecvTx == nil {
+		if c.ntfnHandlers.OnBlockDisconnected == nil {
+			return
+		}
+
+		c.ntfnHandlers.OnBlockDisconnected(blockSha, blockHeight, blockTime)
+
+	// OnRecvTx and OnRecvTxDetails
+	case btcjson.RecvTxNtfnMethod:
+		// Ignore the notification if neither the client nor any rescans
+		// started with RescanRangeAsync are interested in it.
+		if c.ntfnHandlers.OnRecvTx == nil &&
+			c.ntfnHandlers.OnRecvTxDetails == nil &&
+			!c.rescanNtfns.active() {
 			return
 		}
 
@@ -235,13 +382,52 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 			return
 		}
 
-		c.ntfnHandlers.OnRecvTx(tx, block)
+		// Track the outputs found by rescans started with
+		// RescanRangeAsync regardless of whether or not the client is
+		// interested in the notification so later chunks of the rescans
+		// watch them for spends.
+		c.rescanNtfns.recvTx(tx, block, c.chainParams())
 
-	// OnRedeemingTx
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnRecvTx == nil &&
+			c.ntfnHandlers.OnRecvTxDetails == nil {
+			return
+		}
+
+		// Drop duplicate notifications when requested.
+		delivery := TxDeliveryUntracked
+		if c.chainTxDedup != nil {
+			var dup bool
+			delivery, dup = c.chainTxDedup.check(ntfn.Method,
+				tx.Sha(), block)
+			if dup {
+				log.Tracef("Dropping duplicate [%s] for %v",
+					ntfn.Method, tx.Sha())
+				return
+			}
+		}
+
+		if c.ntfnHandlers.OnRecvTx != nil {
+			c.ntfnHandlers.OnRecvTx(tx, block)
+		}
+		if c.ntfnHandlers.OnRecvTxDetails != nil {
+			details, err := c.newChainTxNtfn(tx, block)
+			if err != nil {
+				log.Warnf("Received invalid recvtx "+
+					"notification: %v", err)
+				return
+			}
+			details.Delivery = delivery
+			c.ntfnHandlers.OnRecvTxDetails(details)
+		}
+
+	// OnRedeemingTx and OnRedeemingTxDetails
 	case btcjson.RedeemingTxNtfnMethod:
 		// Ignore the notification if the client is not interested in
 		// it.
-		if c.ntfnHandlers.OnRedeemingTx == nil {
+		if c.ntfnHandlers.OnRedeemingTx == nil &&
+			c.ntfnHandlers.OnRedeemingTxDetails == nil {
 			return
 		}
 
@@ -252,16 +438,35 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 			return
 		}
 
-		c.ntfnHandlers.OnRedeemingTx(tx, block)
+		// Drop duplicate notifications when requested.
+		delivery := TxDeliveryUntracked
+		if c.chainTxDedup != nil {
+			var dup bool
+			delivery, dup = c.chainTxDedup.check(ntfn.Method,
+				tx.Sha(), block)
+			if dup {
+				log.Tracef("Dropping duplicate [%s] for %v",
+					ntfn.Method, tx.Sha())
+				return
+			}
+		}
 
-	// OnRescanFinished
-	case btcjson.RescanFinishedNtfnMethod:
-		// Ignore the notification if the client is not interested in
-		// it.
-		if c.ntfnHandlers.OnRescanFinished == nil {
-			return
+		if c.ntfnHandlers.OnRedeemingTx != nil {
+			c.ntfnHandlers.OnRedeemingTx(tx, block)
+		}
+		if c.ntfnHandlers.OnRedeemingTxDetails != nil {
+			details, err := c.newChainTxNtfn(tx, block)
+			if err != nil {
+				log.Warnf("Received invalid redeemingtx "+
+					"notification: %v", err)
+				return
+			}
+			details.Delivery = delivery
+			c.ntfnHandlers.OnRedeemingTxDetails(details)
 		}
 
+	// OnRescanFinished
+	case btcjson.RescanFinishedNtfnMethod:
 		hash, height, blkTime, err := parseRescanProgressParams(ntfn.Params)
 		if err != nil {
 			log.Warnf("Received invalid rescanfinished "+
@@ -269,16 +474,21 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 			return
 		}
 
-		c.ntfnHandlers.OnRescanFinished(hash, height, blkTime)
+		// Track the progress regardless of whether or not the client
+		// is interested in the notification so any rescans started
+		// with RescanRangeAsync can resume from it.
+		c.rescanNtfns.notify(height, true)
 
-	// OnRescanProgress
-	case btcjson.RescanProgressNtfnMethod:
 		// Ignore the notification if the client is not interested in
 		// it.
-		if c.ntfnHandlers.OnRescanProgress == nil {
+		if c.ntfnHandlers.OnRescanFinished == nil {
 			return
 		}
 
+		c.ntfnHandlers.OnRescanFinished(hash, height, blkTime)
+
+	// OnRescanProgress
+	case btcjson.RescanProgressNtfnMethod:
 		hash, height, blkTime, err := parseRescanProgressParams(ntfn.Params)
 		if err != nil {
 			log.Warnf("Received invalid rescanprogress "+
@@ -286,6 +496,17 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 			return
 		}
 
+		// Track the progress regardless of whether or not the client
+		// is interested in the notification so any rescans started
+		// with RescanRangeAsync can resume from it.
+		c.rescanNtfns.notify(height, false)
+
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnRescanProgress == nil {
+			return
+		}
+
 		c.ntfnHandlers.OnRescanProgress(hash, height, blkTime)
 
 	// OnTxAccepted
@@ -305,11 +526,12 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 
 		c.ntfnHandlers.OnTxAccepted(hash, amt)
 
-	// OnTxAcceptedVerbose
+	// OnTxAcceptedVerbose and OnTxAcceptedDetails
 	case btcjson.TxAcceptedVerboseNtfnMethod:
 		// Ignore the notification if the client is not interested in
 		// it.
-		if c.ntfnHandlers.OnTxAcceptedVerbose == nil {
+		if c.ntfnHandlers.OnTxAcceptedVerbose == nil &&
+			c.ntfnHandlers.OnTxAcceptedDetails == nil {
 			return
 		}
 
@@ -320,7 +542,81 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 			return
 		}
 
-		c.ntfnHandlers.OnTxAcceptedVerbose(rawTx)
+		if c.ntfnHandlers.OnTxAcceptedVerbose != nil {
+			c.ntfnHandlers.OnTxAcceptedVerbose(rawTx)
+		}
+		if c.ntfnHandlers.OnTxAcceptedDetails != nil {
+			details, err := c.newTxAcceptedNtfn(rawTx)
+			if err != nil {
+				log.Warnf("Received invalid tx accepted "+
+					"verbose notification: %v", err)
+				return
+			}
+			c.ntfnHandlers.OnTxAcceptedDetails(details)
+		}
+
+	// OnRelevantTxAccepted
+	case relevantTxAcceptedNtfnMethod:
+		tx, err := parseRelevantTxAcceptedParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid relevant tx accepted "+
+				"notification: %v", err)
+			return
+		}
+
+		// Track the outputs the server added to the transaction filter
+		// regardless of whether or not the client is interested in the
+		// notification so they are reloaded on reconnect.
+		c.trackTxFilterMatches(tx)
+
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnRelevantTxAccepted == nil {
+			return
+		}
+
+		c.ntfnHandlers.OnRelevantTxAccepted(tx)
+
+	// OnFilteredBlockConnected
+	case filteredBlockConnectedNtfnMethod:
+		height, header, txns, err := parseFilteredBlockConnectedParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid filtered block connected "+
+				"notification: %v", err)
+			return
+		}
+
+		// Track the outputs the server added to the transaction filter
+		// regardless of whether or not the client is interested in the
+		// notification so they are reloaded on reconnect.
+		for _, tx := range txns {
+			c.trackTxFilterMatches(tx)
+		}
+
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnFilteredBlockConnected == nil {
+			return
+		}
+
+		c.ntfnHandlers.OnFilteredBlockConnected(height, header, txns)
+
+	// OnFilteredBlockDisconnected
+	case filteredBlockDisconnectedNtfnMethod:
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnFilteredBlockDisconnected == nil {
+			return
+		}
+
+		height, header, err := parseFilteredBlockDisconnectedParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid filtered block disconnected "+
+				"notification: %v", err)
+			return
+		}
+
+		c.ntfnHandlers.OnFilteredBlockDisconnected(height, header)
 
 	// OnBtcdConnected
 	case btcjson.BtcdConnectedNtfnMethod:
@@ -476,9 +772,8 @@ func parseChainTxNtfnParams(params []json.RawMessage) (*btcutil.Tx,
 		return nil, nil, err
 	}
 
-	// TODO: Change recvtx and redeemingtx callback signatures to use
-	// nicer types for details about the block (block sha as a
-	// wire.ShaHash, block time as a time.Time, etc.).
+	// See OnRecvTxDetails and OnRedeemingTxDetails for nicer types for
+	// the details about the block.
 	tx := btcutil.NewTx(&msgTx)
 	if block != nil {
 		tx.SetIndex(block.Index)
@@ -540,15 +835,9 @@ func parseTxAcceptedNtfnParams(params []json.RawMessage) (*wire.ShaHash,
 		return nil, 0, err
 	}
 
-	// Unmarshal second parameter as a floating point number.
-	var famt float64
-	err = json.Unmarshal(params[1], &famt)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	// Bounds check amount.
-	amt, err := btcutil.NewAmount(famt)
+	// Unmarshal second parameter as an exact amount.
+	var amt Amount
+	err = json.Unmarshal(params[1], &amt)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -578,12 +867,134 @@ func parseTxAcceptedVerboseNtfnParams(params []json.RawMessage) (*btcjson.TxRawR
 		return nil, err
 	}
 
-	// TODO: change txacceptedverbose notification callbacks to use nicer
-	// types for all details about the transaction (i.e. decoding hashes
-	// from their string encoding).
+	// See OnTxAcceptedDetails for nicer types for all details about the
+	// transaction.
 	return &rawTx, nil
 }
 
+// parseHexTx hex decodes and deserializes the passed transaction.
+func parseHexTx(txHex string) (*btcutil.Tx, error) {
+	serializedTx, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, err
+	}
+	var msgTx wire.MsgTx
+	err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+	if err != nil {
+		return nil, err
+	}
+	return btcutil.NewTx(&msgTx), nil
+}
+
+// parseHexBlockHeader hex decodes and deserializes the passed block header.
+func parseHexBlockHeader(headerHex string) (*wire.BlockHeader, error) {
+	serializedHeader, err := hex.DecodeString(headerHex)
+	if err != nil {
+		return nil, err
+	}
+	var header wire.BlockHeader
+	err = header.Deserialize(bytes.NewReader(serializedHeader))
+	if err != nil {
+		return nil, err
+	}
+	return &header, nil
+}
+
+// parseRelevantTxAcceptedParams parses out the transaction from the parameters
+// of a relevanttxaccepted notification.
+func parseRelevantTxAcceptedParams(params []json.RawMessage) (*btcutil.Tx, error) {
+	if len(params) != 1 {
+		return nil, wrongNumParams(len(params))
+	}
+
+	// Unmarshal first parameter as a string.
+	var txHex string
+	err := json.Unmarshal(params[0], &txHex)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseHexTx(txHex)
+}
+
+// parseFilteredBlockConnectedParams parses out the block height, header, and
+// matching transactions from the parameters of a filteredblockconnected
+// notification.
+func parseFilteredBlockConnectedParams(params []json.RawMessage) (int32,
+	*wire.BlockHeader, []*btcutil.Tx, error) {
+
+	if len(params) != 3 {
+		return 0, nil, nil, wrongNumParams(len(params))
+	}
+
+	// Unmarshal first parameter as an integer.
+	var height int32
+	err := json.Unmarshal(params[0], &height)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	// Unmarshal second parameter as a string.
+	var headerHex string
+	err = json.Unmarshal(params[1], &headerHex)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	// Unmarshal third parameter as an array of strings.
+	var txHexes []string
+	err = json.Unmarshal(params[2], &txHexes)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	header, err := parseHexBlockHeader(headerHex)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	txns := make([]*btcutil.Tx, 0, len(txHexes))
+	for _, txHex := range txHexes {
+		tx, err := parseHexTx(txHex)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		txns = append(txns, tx)
+	}
+
+	return height, header, txns, nil
+}
+
+// parseFilteredBlockDisconnectedParams parses out the block height and header
+// from the parameters of a filteredblockdisconnected notification.
+func parseFilteredBlockDisconnectedParams(params []json.RawMessage) (int32,
+	*wire.BlockHeader, error) {
+
+	if len(params) != 2 {
+		return 0, nil, wrongNumParams(len(params))
+	}
+
+	// Unmarshal first parameter as an integer.
+	var height int32
+	err := json.Unmarshal(params[0], &height)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Unmarshal second parameter as a string.
+	var headerHex string
+	err = json.Unmarshal(params[1], &headerHex)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	header, err := parseHexBlockHeader(headerHex)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return height, header, nil
+}
+
 // parseBtcdConnectedNtfnParams parses out the connection status of btcd
 // and btcwallet from the parameters of a btcdconnected notification.
 func parseBtcdConnectedNtfnParams(params []json.RawMessage) (bool, error) {
@@ -617,9 +1028,9 @@ func parseAccountBalanceNtfnParams(params []json.RawMessage) (account string,
 		return "", 0, false, err
 	}
 
-	// Unmarshal second parameter as a floating point number.
-	var fbal float64
-	err = json.Unmarshal(params[1], &fbal)
+	// Unmarshal second parameter as an exact amount.
+	var bal Amount
+	err = json.Unmarshal(params[1], &bal)
 	if err != nil {
 		return "", 0, false, err
 	}
@@ -630,12 +1041,6 @@ func parseAccountBalanceNtfnParams(params []json.RawMessage) (account string,
 		return "", 0, false, err
 	}
 
-	// Bounds check amount.
-	bal, err := btcutil.NewAmount(fbal)
-	if err != nil {
-		return "", 0, false, err
-	}
-
 	return account, btcutil.Amount(bal), confirmed, nil
 }
 
@@ -686,8 +1091,8 @@ func (r FutureNotifyBlocksResult) Receive() error {
 //
 // NOTE: This is a btcd extension and requires a websocket connection.
 func (c *Client) NotifyBlocksAsync() FutureNotifyBlocksResult {
-	// Not supported in HTTP POST mode.
-	if c.config.HTTPPostMode {
+	// Not supported in HTTP POST mode unless emulated by polling.
+	if c.config.HTTPPostMode && c.config.NtfnPollInterval <= 0 {
 		return newFutureError(ErrNotificationsNotSupported)
 	}
 
@@ -697,7 +1102,14 @@ func (c *Client) NotifyBlocksAsync() FutureNotifyBlocksResult {
 		return newNilFutureResult()
 	}
 
+	// Register the notification with the poller instead of the RPC
+	// server when running in HTTP POST mode.
 	cmd := btcjson.NewNotifyBlocksCmd()
+	if c.config.HTTPPostMode {
+		c.trackRegisteredNtfns(cmd)
+		return newNilFutureResult()
+	}
+
 	return c.sendCmd(cmd)
 }
 
@@ -710,7 +1122,8 @@ func (c *Client) NotifyBlocksAsync() FutureNotifyBlocksResult {
 // The notifications delivered as a result of this call will be via one of
 // OnBlockConnected or OnBlockDisconnected.
 //
-// NOTE: This is a btcd extension and requires a websocket connection.
+// NOTE: This is a btcd extension and requires a websocket connection unless
+// the NtfnPollInterval config option is set.
 func (c *Client) NotifyBlocks() error {
 	return c.NotifyBlocksAsync().Receive()
 }
@@ -781,6 +1194,15 @@ func (c *Client) NotifySpentAsync(outpoints []*wire.OutPoint) FutureNotifySpentR
 	for _, outpoint := range outpoints {
 		ops = append(ops, newOutPointFromWire(outpoint))
 	}
+
+	// The outpoints are now needed by the caller, so the observers must
+	// not release them.
+	c.ntfnState.Lock()
+	for _, op := range ops {
+		delete(c.ntfnState.sharedSpent, op)
+	}
+	c.ntfnState.Unlock()
+
 	cmd := btcjson.NewNotifySpentCmd(ops)
 	return c.sendCmd(cmd)
 }
@@ -822,8 +1244,8 @@ func (r FutureNotifyNewTransactionsResult) Receive() error {
 //
 // NOTE: This is a btcd extension and requires a websocket connection.
 func (c *Client) NotifyNewTransactionsAsync(verbose bool) FutureNotifyNewTransactionsResult {
-	// Not supported in HTTP POST mode.
-	if c.config.HTTPPostMode {
+	// Not supported in HTTP POST mode unless emulated by polling.
+	if c.config.HTTPPostMode && c.config.NtfnPollInterval <= 0 {
 		return newFutureError(ErrNotificationsNotSupported)
 	}
 
@@ -833,7 +1255,14 @@ func (c *Client) NotifyNewTransactionsAsync(verbose bool) FutureNotifyNewTransac
 		return newNilFutureResult()
 	}
 
+	// Register the notification with the poller instead of the RPC
+	// server when running in HTTP POST mode.
 	cmd := btcjson.NewNotifyNewTransactionsCmd(&verbose)
+	if c.config.HTTPPostMode {
+		c.trackRegisteredNtfns(cmd)
+		return newNilFutureResult()
+	}
+
 	return c.sendCmd(cmd)
 }
 
@@ -847,7 +1276,8 @@ func (c *Client) NotifyNewTransactionsAsync(verbose bool) FutureNotifyNewTransac
 // OnTxAccepted (when verbose is false) or OnTxAcceptedVerbose (when verbose is
 // true).
 //
-// NOTE: This is a btcd extension and requires a websocket connection.
+// NOTE: This is a btcd extension and requires a websocket connection unless
+// the NtfnPollInterval config option is set.
 func (c *Client) NotifyNewTransactions(verbose bool) error {
 	return c.NotifyNewTransactionsAsync(verbose).Receive()
 }
@@ -911,6 +1341,15 @@ func (c *Client) NotifyReceivedAsync(addresses []btcutil.Address) FutureNotifyRe
 	for _, addr := range addresses {
 		addrs = append(addrs, addr.String())
 	}
+
+	// The addresses are now needed by the caller, so the observers must
+	// not release them.
+	c.ntfnState.Lock()
+	for _, addr := range addrs {
+		delete(c.ntfnState.sharedReceived, addr)
+	}
+	c.ntfnState.Unlock()
+
 	cmd := btcjson.NewNotifyReceivedCmd(addrs)
 	return c.sendCmd(cmd)
 }
@@ -936,6 +1375,200 @@ func (c *Client) NotifyReceived(addresses []btcutil.Address) error {
 	return c.NotifyReceivedAsync(addresses).Receive()
 }
 
+// stopNotifyReceived unregisters the client from notifications of new
+// transactions paying to the passed encoded addresses, which are removed from
+// the notification state so they are not registered again on reconnect.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func (c *Client) stopNotifyReceived(addresses []string) error {
+	// Not supported in HTTP POST mode.
+	if c.config.HTTPPostMode {
+		return ErrNotificationsNotSupported
+	}
+
+	// Ignore the request if the client is not interested in
+	// notifications.
+	if c.ntfnHandlers == nil {
+		return nil
+	}
+
+	cmd := btcjson.NewStopNotifyReceivedCmd(addresses)
+	_, err := receiveFuture(c.sendCmd(cmd))
+	return err
+}
+
+// stopNotifySpent unregisters the client from notifications of transactions
+// spending the passed outpoints, which are removed from the notification state
+// so they are not registered again on reconnect.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func (c *Client) stopNotifySpent(outpoints []btcjson.OutPoint) error {
+	// Not supported in HTTP POST mode.
+	if c.config.HTTPPostMode {
+		return ErrNotificationsNotSupported
+	}
+
+	// Ignore the request if the client is not interested in
+	// notifications.
+	if c.ntfnHandlers == nil {
+		return nil
+	}
+
+	cmd := btcjson.NewStopNotifySpentCmd(outpoints)
+	_, err := receiveFuture(c.sendCmd(cmd))
+	return err
+}
+
+// retainReceived registers the client for notifications of transactions
+// paying to the passed encoded addresses on behalf of an observer of the client
+// and adds a reference to each of them.  Only the addresses which are not
+// registered yet are sent to the server.  Addresses which were registered with
+// NotifyReceived are left as is since they are never released.  Each address
+// must only be passed once.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) retainReceived(addresses []string) error {
+	c.ntfnState.Lock()
+	var added []string
+	for _, addr := range addresses {
+		if refs, ok := c.ntfnState.sharedReceived[addr]; ok {
+			c.ntfnState.sharedReceived[addr] = refs + 1
+			continue
+		}
+		if _, ok := c.ntfnState.notifyReceived[addr]; ok {
+			continue
+		}
+		c.ntfnState.sharedReceived[addr] = 1
+		added = append(added, addr)
+	}
+	c.ntfnState.Unlock()
+
+	if len(added) == 0 {
+		return nil
+	}
+	if err := c.notifyReceivedInternal(added).Receive(); err != nil {
+		c.ntfnState.Lock()
+		for _, addr := range added {
+			if refs, ok := c.ntfnState.sharedReceived[addr]; ok {
+				if refs <= 1 {
+					delete(c.ntfnState.sharedReceived, addr)
+				} else {
+					c.ntfnState.sharedReceived[addr] = refs - 1
+				}
+			}
+		}
+		c.ntfnState.Unlock()
+		return err
+	}
+	return nil
+}
+
+// releaseReceived removes a reference held by an observer of the client to
+// each of the passed encoded addresses, and unregisters the client from
+// notifications of transactions paying to those which are no longer referenced.
+// Addresses which were registered with NotifyReceived are never unregistered.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) releaseReceived(addresses []string) error {
+	c.ntfnState.Lock()
+	var released []string
+	for _, addr := range addresses {
+		refs, ok := c.ntfnState.sharedReceived[addr]
+		if !ok {
+			continue
+		}
+		if refs > 1 {
+			c.ntfnState.sharedReceived[addr] = refs - 1
+			continue
+		}
+		delete(c.ntfnState.sharedReceived, addr)
+		released = append(released, addr)
+	}
+	c.ntfnState.Unlock()
+
+	if len(released) == 0 {
+		return nil
+	}
+	return c.stopNotifyReceived(released)
+}
+
+// retainSpent registers the client for notifications of transactions spending
+// the passed outpoints on behalf of an observer of the client and adds a
+// reference to each of them.  Only the outpoints which are not registered yet
+// are sent to the server.  Outpoints which were registered with NotifySpent are
+// left as is since they are never released.  Each outpoint must only be passed
+// once.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) retainSpent(outpoints []btcjson.OutPoint) error {
+	c.ntfnState.Lock()
+	var added []btcjson.OutPoint
+	for _, op := range outpoints {
+		if refs, ok := c.ntfnState.sharedSpent[op]; ok {
+			c.ntfnState.sharedSpent[op] = refs + 1
+			continue
+		}
+		if _, ok := c.ntfnState.notifySpent[op]; ok {
+			continue
+		}
+		c.ntfnState.sharedSpent[op] = 1
+		added = append(added, op)
+	}
+	c.ntfnState.Unlock()
+
+	if len(added) == 0 {
+		return nil
+	}
+	if err := c.notifySpentInternal(added).Receive(); err != nil {
+		c.ntfnState.Lock()
+		for _, op := range added {
+			if refs, ok := c.ntfnState.sharedSpent[op]; ok {
+				if refs <= 1 {
+					delete(c.ntfnState.sharedSpent, op)
+				} else {
+					c.ntfnState.sharedSpent[op] = refs - 1
+				}
+			}
+		}
+		c.ntfnState.Unlock()
+		return err
+	}
+	return nil
+}
+
+// releaseSpent removes a reference held by an observer of the client to each
+// of the passed outpoints, and unregisters the client from notifications of
+// transactions spending those which are no longer referenced.  Outpoints which
+// were registered with NotifySpent are never unregistered.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) releaseSpent(outpoints []btcjson.OutPoint) error {
+	c.ntfnState.Lock()
+	var released []btcjson.OutPoint
+	for _, op := range outpoints {
+		refs, ok := c.ntfnState.sharedSpent[op]
+		if !ok {
+			continue
+		}
+		if refs > 1 {
+			c.ntfnState.sharedSpent[op] = refs - 1
+			continue
+		}
+		delete(c.ntfnState.sharedSpent, op)
+		released = append(released, op)
+	}
+	c.ntfnState.Unlock()
+
+	if len(released) == 0 {
+		return nil
+	}
+	return c.stopNotifySpent(released)
+}
+
 // FutureRescanResult is a future promise to deliver the result of a RescanAsync
 // or RescanEndHeightAsync RPC invocation (or an applicable error).
 type FutureRescanResult chan *response
@@ -957,11 +1590,12 @@ func (r FutureRescanResult) Receive() error {
 //
 // See Rescan for the blocking version and more details.
 //
-// NOTE: Rescan requests are not issued on client reconnect and must be
-// performed manually (ideally with a new start height based on the last
-// rescan progress notification).  See the OnClientConnected notification
-// callback for a good callsite to reissue rescan requests on connect and
-// reconnect.
+// NOTE: Rescan requests are not issued on client reconnect, in which case
+// ErrClientDisconnect is returned, and must be performed manually (ideally with
+// a new start height based on the last rescan progress notification).  See the
+// OnClientConnected notification callback for a good callsite to reissue rescan
+// requests on connect and reconnect, or RescanRangeAsync for rescans which
+// resume automatically.
 //
 // NOTE: This is a btcd extension and requires a websocket connection.
 func (c *Client) RescanAsync(startBlock *wire.ShaHash,
@@ -997,6 +1631,7 @@ func (c *Client) RescanAsync(startBlock *wire.ShaHash,
 		ops = append(ops, newOutPointFromWire(op))
 	}
 
+	c.trackWatchedFilter(addrs, ops)
 	cmd := btcjson.NewRescanCmd(startBlockShaStr, addrs, ops, nil)
 	return c.sendCmd(cmd)
 }
@@ -1020,11 +1655,12 @@ func (c *Client) RescanAsync(startBlock *wire.ShaHash,
 // See RescanEndBlock to also specify an ending block to finish the rescan
 // without continuing through the best block on the main chain.
 //
-// NOTE: Rescan requests are not issued on client reconnect and must be
-// performed manually (ideally with a new start height based on the last
-// rescan progress notification).  See the OnClientConnected notification
-// callback for a good callsite to reissue rescan requests on connect and
-// reconnect.
+// NOTE: Rescan requests are not issued on client reconnect, in which case
+// ErrClientDisconnect is returned, and must be performed manually (ideally with
+// a new start height based on the last rescan progress notification).  See the
+// OnClientConnected notification callback for a good callsite to reissue rescan
+// requests on connect and reconnect, or RescanRangeAsync for rescans which
+// resume automatically.
 //
 // NOTE: This is a btcd extension and requires a websocket connection.
 func (c *Client) Rescan(startBlock *wire.ShaHash,
@@ -1077,11 +1713,33 @@ func (c *Client) RescanEndBlockAsync(startBlock *wire.ShaHash,
 		ops = append(ops, newOutPointFromWire(op))
 	}
 
+	c.trackWatchedFilter(addrs, ops)
 	cmd := btcjson.NewRescanCmd(startBlockShaStr, addrs, ops,
 		&endBlockShaStr)
 	return c.sendCmd(cmd)
 }
 
+// rescanInternal is the same as RescanEndBlockAsync except it accepts the
+// converted addresses and outpoints as parameters so the client can more
+// efficiently rescan the registered notification state on reconnect.
+func (c *Client) rescanInternal(startBlock string, addresses []string,
+	outpoints []btcjson.OutPoint, endBlock string) FutureRescanResult {
+
+	// Not supported in HTTP POST mode.
+	if c.config.HTTPPostMode {
+		return newFutureError(ErrNotificationsNotSupported)
+	}
+
+	// Ignore the notification if the client is not interested in
+	// notifications.
+	if c.ntfnHandlers == nil {
+		return newNilFutureResult()
+	}
+
+	cmd := btcjson.NewRescanCmd(startBlock, addresses, outpoints, &endBlock)
+	return c.sendCmd(cmd)
+}
+
 // RescanEndBlock rescans the block chain starting from the provided starting
 // block up to the provided ending block for transactions that pay to the
 // passed addresses and transactions which spend the passed outpoints.
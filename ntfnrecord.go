@@ -0,0 +1,254 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// recordedNotification is the JSON representation of a notification written by
+// a NotificationRecorder.  Each recorded notification is written on a separate
+// line.
+type recordedNotification struct {
+	Time   time.Time         `json:"time"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// NotificationRecorder writes every notification received by a client, along
+// with the time it was received, to a JSON-lines stream which can be fed back
+// through ReplayNotifications.
+type NotificationRecorder struct {
+	client *Client
+	w      io.Writer
+	closer io.Closer
+	signal chan struct{}
+	quit   chan struct{}
+	wg     sync.WaitGroup
+
+	quitOnce sync.Once
+
+	mtx     sync.Mutex
+	pending []*recordedNotification
+	err     error
+}
+
+// Enforce NotificationRecorder satisfies the ntfnObserver interface.
+var _ ntfnObserver = (*NotificationRecorder)(nil)
+
+// NewNotificationRecorder returns a new recorder which writes every
+// notification received by the client to the passed writer until it is closed.
+// The client must have been created with notification handlers, although none
+// of the handlers need to be set.
+func (c *Client) NewNotificationRecorder(w io.Writer) (*NotificationRecorder, error) {
+	if c.ntfnHandlers == nil {
+		return nil, ErrNoNotificationHandlers
+	}
+
+	r := &NotificationRecorder{
+		client: c,
+		w:      w,
+		signal: make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+	}
+	c.ntfnObservers.add(r)
+	r.wg.Add(1)
+	go r.writer()
+	return r, nil
+}
+
+// RecordNotifications returns a new recorder which appends every notification
+// received by the client to the file at the passed path, creating it when it
+// does not exist.  The file is closed when the recorder is closed.
+func (c *Client) RecordNotifications(path string) (*NotificationRecorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.NewNotificationRecorder(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.closer = f
+	return r, nil
+}
+
+// observeNotification queues the passed notification to be written by the
+// recorder goroutine.  It is part of the ntfnObserver interface implementation.
+func (r *NotificationRecorder) observeNotification(ntfn *rawNotification) {
+	record := &recordedNotification{
+		Time:   time.Now(),
+		Method: ntfn.Method,
+		Params: ntfn.Params,
+	}
+	r.mtx.Lock()
+	r.pending = append(r.pending, record)
+	r.mtx.Unlock()
+
+	select {
+	case r.signal <- struct{}{}:
+	default:
+	}
+}
+
+// flush writes the queued notifications.  Notifications are dropped once a
+// write fails.
+func (r *NotificationRecorder) flush() {
+	r.mtx.Lock()
+	pending := r.pending
+	r.pending = nil
+	failed := r.err != nil
+	r.mtx.Unlock()
+	if failed {
+		return
+	}
+
+	for _, record := range pending {
+		line, err := json.Marshal(record)
+		if err == nil {
+			_, err = r.w.Write(append(line, '\n'))
+		}
+		if err != nil {
+			log.Errorf("Unable to record notification: %v", err)
+			r.mtx.Lock()
+			r.err = err
+			r.mtx.Unlock()
+			return
+		}
+	}
+}
+
+// writer writes the queued notifications.  It must be run as a goroutine.
+func (r *NotificationRecorder) writer() {
+out:
+	for {
+		select {
+		case <-r.signal:
+			r.flush()
+
+		case <-r.quit:
+			break out
+		}
+	}
+	r.flush()
+	r.wg.Done()
+}
+
+// Close stops recording, writes any notifications which are still queued, and
+// closes the file of recorders created with RecordNotifications.  It returns
+// the first error encountered while writing, if any.
+func (r *NotificationRecorder) Close() error {
+	r.quitOnce.Do(func() {
+		r.client.ntfnObservers.remove(r)
+		close(r.quit)
+	})
+	r.wg.Wait()
+
+	r.mtx.Lock()
+	err := r.err
+	r.mtx.Unlock()
+	if r.closer != nil {
+		if closeErr := r.closer.Close(); err == nil {
+			err = closeErr
+		}
+		r.closer = nil
+	}
+	return err
+}
+
+// newOfflineClient returns a client which is not connected to any server, for
+// use by ReplayNotifications.  Any requests issued with it fail with
+// ErrClientNotConnected.
+func newOfflineClient(config *ConnConfig, ntfnHandlers *NotificationHandlers) *Client {
+	if config == nil {
+		config = &ConnConfig{}
+	}
+	return &Client{
+		config:          config,
+		requestMap:      make(map[uint64]*list.Element),
+		requestList:     list.New(),
+		ntfnHandlers:    ntfnHandlers,
+		ntfnState:       newNotificationState(),
+		chainState:      newChainNtfnState(),
+		txOutValues:     newTxOutValueCache(maxCachedTxOutValues),
+		ntfnObservers:   newNtfnObservers(),
+		rescanNtfns:     newRescanNtfnSubscribers(),
+		ntfnStateDirty:  make(chan struct{}, 1),
+		connEstablished: make(chan struct{}),
+		disconnect:      make(chan struct{}),
+		shutdown:        make(chan struct{}),
+	}
+}
+
+// ReplayNotifications reads the notifications recorded by a
+// NotificationRecorder from the passed reader and invokes the passed
+// notification handlers for them, in order and as fast as possible, exactly as
+// a connected client would.  The only options of the passed config which are
+// used are ChainParams and NtfnDedupWindow, and it may be nil.
+//
+// The handlers are invoked with an offline client, so any requests they issue
+// fail with ErrClientNotConnected, and OnClientConnected and
+// OnStakeBlockConnected are never invoked.  This allows reproducing the
+// behavior of handlers for a particular sequence of notifications without an
+// RPC server.
+func ReplayNotifications(r io.Reader, config *ConnConfig,
+	ntfnHandlers *NotificationHandlers) error {
+
+	if ntfnHandlers == nil {
+		return ErrNoNotificationHandlers
+	}
+	c := newOfflineClient(config, ntfnHandlers)
+	if c.config.NtfnDedupWindow > 0 {
+		c.chainTxDedup = newChainTxDedup(c.config.NtfnDedupWindow)
+	}
+
+	reader := bufio.NewReader(r)
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(bytes.TrimSpace(line)) != 0 {
+			var record recordedNotification
+			if err := json.Unmarshal(line, &record); err != nil {
+				return fmt.Errorf("invalid notification on "+
+					"line %d: %v", lineNum, err)
+			}
+
+			log.Tracef("Replaying notification [%s] received at %v",
+				record.Method, record.Time)
+			c.deliverNotification(&rawNotification{
+				Method: record.Method,
+				Params: record.Params,
+			})
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// ReplayNotificationsFile replays the notifications recorded in the file at the
+// passed path.  See ReplayNotifications for more details.
+func ReplayNotificationsFile(path string, config *ConnConfig,
+	ntfnHandlers *NotificationHandlers) error {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return ReplayNotifications(f, config, ntfnHandlers)
+}
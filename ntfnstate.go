@@ -0,0 +1,292 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ppcsuite/ppcd/btcjson"
+)
+
+var (
+	// ErrNoNotificationStateStore is an error to describe the condition
+	// where the caller is trying to restore the notification state without
+	// a notification state store being configured.
+	ErrNoNotificationStateStore = errors.New("no notification state " +
+		"store configured")
+)
+
+// NotificationState is a serializable snapshot of the notifications registered
+// with the RPC server.  It is returned by ExportNotificationState and can be
+// registered again, such as after a restart, with ImportNotificationState.
+type NotificationState struct {
+	NotifyBlocks       bool               `json:"notifyblocks"`
+	NotifyNewTx        bool               `json:"notifynewtx"`
+	NotifyNewTxVerbose bool               `json:"notifynewtxverbose"`
+	NotifyReceived     []string           `json:"notifyreceived"`
+	NotifySpent        []btcjson.OutPoint `json:"notifyspent"`
+	TxFilterLoaded     bool               `json:"txfilterloaded"`
+	TxFilterAddresses  []string           `json:"txfilteraddresses"`
+	TxFilterOutPoints  []btcjson.OutPoint `json:"txfilteroutpoints"`
+}
+
+// sortedAddresses returns the passed set of addresses as a sorted slice.
+func sortedAddresses(set map[string]struct{}) []string {
+	addrs := make([]string, 0, len(set))
+	for addr := range set {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// outPointSorter implements sort.Interface to allow a slice of outpoints to be
+// sorted by hash and index.
+type outPointSorter []btcjson.OutPoint
+
+// Len returns the number of outpoints in the slice.  It is part of the
+// sort.Interface implementation.
+func (s outPointSorter) Len() int {
+	return len(s)
+}
+
+// Swap swaps the outpoints at the passed indices.  It is part of the
+// sort.Interface implementation.
+func (s outPointSorter) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// Less returns whether the outpoint with index i should sort before the
+// outpoint with index j.  It is part of the sort.Interface implementation.
+func (s outPointSorter) Less(i, j int) bool {
+	if s[i].Hash != s[j].Hash {
+		return s[i].Hash < s[j].Hash
+	}
+	return s[i].Index < s[j].Index
+}
+
+// sortedOutPoints returns the passed set of outpoints as a sorted slice.
+func sortedOutPoints(set map[btcjson.OutPoint]struct{}) []btcjson.OutPoint {
+	ops := make([]btcjson.OutPoint, 0, len(set))
+	for op := range set {
+		ops = append(ops, op)
+	}
+	sort.Sort(outPointSorter(ops))
+	return ops
+}
+
+// ExportNotificationState returns a serializable snapshot of the notifications
+// currently registered with the RPC server.  The addresses and outpoints are
+// sorted so snapshots of the same state are identical.
+//
+// This function is safe for concurrent access.
+func (c *Client) ExportNotificationState() *NotificationState {
+	stateCopy := c.ntfnState.Copy()
+	return &NotificationState{
+		NotifyBlocks:       stateCopy.notifyBlocks,
+		NotifyNewTx:        stateCopy.notifyNewTx,
+		NotifyNewTxVerbose: stateCopy.notifyNewTxVerbose,
+		NotifyReceived:     sortedAddresses(stateCopy.notifyReceived),
+		NotifySpent:        sortedOutPoints(stateCopy.notifySpent),
+		TxFilterLoaded:     stateCopy.txFilterLoaded,
+		TxFilterAddresses:  sortedAddresses(stateCopy.txFilterAddrs),
+		TxFilterOutPoints:  sortedOutPoints(stateCopy.txFilterOutPoints),
+	}
+}
+
+// ImportNotificationState registers all of the notifications in the passed
+// snapshot, typically returned by ExportNotificationState before a restart,
+// with the RPC server.  The notifications are added to any which are already
+// registered, except for the transaction filter which replaces any previously
+// loaded filter when the snapshot has one.  Calling this function has no effect
+// if there are no notification handlers.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) ImportNotificationState(state *NotificationState) error {
+	// Nothing to do if the caller is not interested in notifications.
+	if c.ntfnHandlers == nil {
+		return nil
+	}
+
+	importState := newNotificationState()
+	importState.notifyBlocks = state.NotifyBlocks
+	importState.notifyNewTx = state.NotifyNewTx
+	importState.notifyNewTxVerbose = state.NotifyNewTxVerbose
+	for _, addr := range state.NotifyReceived {
+		importState.notifyReceived[addr] = struct{}{}
+	}
+	for _, op := range state.NotifySpent {
+		importState.notifySpent[op] = struct{}{}
+	}
+	importState.txFilterLoaded = state.TxFilterLoaded
+	for _, addr := range state.TxFilterAddresses {
+		importState.txFilterAddrs[addr] = struct{}{}
+	}
+	for _, op := range state.TxFilterOutPoints {
+		importState.txFilterOutPoints[op] = struct{}{}
+	}
+
+	return c.registerNtfnState(importState)
+}
+
+// NotificationStateStore is the interface used by the client to save the
+// registered notifications each time they change.  See the NtfnStateStore
+// config option.
+type NotificationStateStore interface {
+	// LoadNotificationState returns the most recently saved notification
+	// state, or nil when no state has been saved yet.
+	LoadNotificationState() (*NotificationState, error)
+
+	// SaveNotificationState replaces the saved notification state with
+	// the passed state.
+	SaveNotificationState(state *NotificationState) error
+}
+
+// FileNotificationStateStore is a NotificationStateStore which saves the
+// notification state as JSON to a file.
+type FileNotificationStateStore struct {
+	path string
+}
+
+// Enforce FileNotificationStateStore satisfies the NotificationStateStore
+// interface.
+var _ NotificationStateStore = (*FileNotificationStateStore)(nil)
+
+// NewFileNotificationStateStore returns a new notification state store which
+// saves the state to the file at the passed path.
+func NewFileNotificationStateStore(path string) *FileNotificationStateStore {
+	return &FileNotificationStateStore{path: path}
+}
+
+// LoadNotificationState returns the notification state saved in the file.  Nil
+// is returned when the file does not exist.  It is part of the
+// NotificationStateStore interface implementation.
+func (s *FileNotificationStateStore) LoadNotificationState() (*NotificationState, error) {
+	serialized, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var state NotificationState
+	if err := json.Unmarshal(serialized, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SaveNotificationState writes the passed notification state to the file.  The
+// state is first written to a temporary file in the same directory which then
+// replaces the file so a crash never leaves a partially written state behind.
+// It is part of the NotificationStateStore interface implementation.
+func (s *FileNotificationStateStore) SaveNotificationState(state *NotificationState) error {
+	serialized, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.path, serialized)
+}
+
+// writeFileAtomic writes the passed data to a temporary file and renames it to
+// the passed path, so the file is never left partially written.
+func writeFileAtomic(path string, data []byte) error {
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path),
+		filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// RestoreNotificationState loads the notification state from the store set
+// with the NtfnStateStore config option and registers it with the RPC server
+// using ImportNotificationState.  It is typically called once after creating
+// the client.  Nothing is registered when no state has been saved yet.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) RestoreNotificationState() error {
+	if c.config.NtfnStateStore == nil {
+		return ErrNoNotificationStateStore
+	}
+
+	state, err := c.config.NtfnStateStore.LoadNotificationState()
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return nil
+	}
+	return c.ImportNotificationState(state)
+}
+
+// ntfnStateChanged signals the notification state saver, if any, that the
+// notification state changed.  It never blocks.
+func (c *Client) ntfnStateChanged() {
+	select {
+	case c.ntfnStateDirty <- struct{}{}:
+	default:
+	}
+}
+
+// saveNtfnState saves the current notification state to the configured
+// notification state store.
+func (c *Client) saveNtfnState() {
+	err := c.config.NtfnStateStore.SaveNotificationState(
+		c.ExportNotificationState())
+	if err != nil {
+		log.Warnf("Unable to save notification state: %v", err)
+	}
+}
+
+// ntfnStateSaver saves the notification state to the configured notification
+// state store each time it changes.  It must be run as a goroutine.
+func (c *Client) ntfnStateSaver() {
+out:
+	for {
+		select {
+		case <-c.ntfnStateDirty:
+			c.saveNtfnState()
+
+		case <-c.shutdown:
+			break out
+		}
+	}
+
+	// Save any changes which happened right before shutdown.
+	select {
+	case <-c.ntfnStateDirty:
+		c.saveNtfnState()
+	default:
+	}
+
+	c.wg.Done()
+	log.Tracef("RPC client notification state saver done for %s",
+		c.config.Host)
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ppcsuite/ppcd/btcjson"
+)
+
+// TestFileNotificationStateStore ensures the file backed notification state
+// store round trips saved states, replaces them atomically, and reports missing
+// and malformed files as expected.
+func TestFileNotificationStateStore(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "ntfnstate")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ntfnstate.json")
+	store := NewFileNotificationStateStore(path)
+
+	// Nothing is loaded before a state is saved.
+	state, err := store.LoadNotificationState()
+	if err != nil || state != nil {
+		t.Fatalf("LoadNotificationState: got %v (err %v), want nil",
+			state, err)
+	}
+
+	tests := []struct {
+		name  string
+		state *NotificationState
+	}{
+		{
+			name:  "empty",
+			state: &NotificationState{},
+		},
+		{
+			name: "registered notifications",
+			state: &NotificationState{
+				NotifyBlocks:   true,
+				NotifyNewTx:    true,
+				NotifyReceived: []string{"PA1", "PB2"},
+				NotifySpent: []btcjson.OutPoint{
+					{Hash: "aa", Index: 1},
+				},
+			},
+		},
+		{
+			name: "replaced by filter",
+			state: &NotificationState{
+				NotifyNewTxVerbose: true,
+				TxFilterLoaded:     true,
+				TxFilterAddresses:  []string{"PC3"},
+				TxFilterOutPoints: []btcjson.OutPoint{
+					{Hash: "bb", Index: 0},
+					{Hash: "cc", Index: 2},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if err := store.SaveNotificationState(test.state); err != nil {
+			t.Errorf("%s: SaveNotificationState: %v", test.name, err)
+			continue
+		}
+		got, err := store.LoadNotificationState()
+		if err != nil {
+			t.Errorf("%s: LoadNotificationState: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.state) {
+			t.Errorf("%s: loaded %+v, want %+v", test.name, got,
+				test.state)
+		}
+	}
+
+	// Saving must not leave temporary files behind.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(files) != 1 || files[0].Name() != filepath.Base(path) {
+		t.Errorf("unexpected files after saving: %d", len(files))
+	}
+
+	// A malformed file is an error rather than an empty state.
+	if err := ioutil.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := store.LoadNotificationState(); err == nil {
+		t.Error("LoadNotificationState: expected error for malformed " +
+			"file")
+	}
+}
+
+// TestSortedOutPoints ensures outpoints are sorted by hash and then index so
+// snapshots of the same state are identical.
+func TestSortedOutPoints(t *testing.T) {
+	t.Parallel()
+
+	set := map[btcjson.OutPoint]struct{}{
+		{Hash: "bb", Index: 0}: {},
+		{Hash: "aa", Index: 2}: {},
+		{Hash: "aa", Index: 1}: {},
+	}
+	want := []btcjson.OutPoint{
+		{Hash: "aa", Index: 1},
+		{Hash: "aa", Index: 2},
+		{Hash: "bb", Index: 0},
+	}
+	if got := sortedOutPoints(set); !reflect.DeepEqual(got, want) {
+		t.Errorf("sortedOutPoints: got %v, want %v", got, want)
+	}
+}
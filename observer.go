@@ -0,0 +1,129 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNoNotificationHandlers is an error to describe the condition where the
+// caller is trying to use a component which relies on notifications with a
+// client that was created without notification handlers.
+var ErrNoNotificationHandlers = errors.New("the client was created without " +
+	"notification handlers")
+
+// ntfnObserver is implemented by the components which process every
+// notification received by the client in addition to the notification handlers.
+// Observers are invoked from the same goroutine as the notification handlers, so
+// they must not block.
+type ntfnObserver interface {
+	observeNotification(ntfn *rawNotification)
+}
+
+// ntfnObservers houses the set of observers registered with a client.
+type ntfnObservers struct {
+	sync.Mutex
+	observers map[ntfnObserver]struct{}
+}
+
+// newNtfnObservers returns a new empty set of notification observers.
+func newNtfnObservers() *ntfnObservers {
+	return &ntfnObservers{
+		observers: make(map[ntfnObserver]struct{}),
+	}
+}
+
+// add registers the passed observer.
+//
+// This function is safe for concurrent access.
+func (o *ntfnObservers) add(observer ntfnObserver) {
+	o.Lock()
+	o.observers[observer] = struct{}{}
+	o.Unlock()
+}
+
+// remove unregisters the passed observer.
+//
+// This function is safe for concurrent access.
+func (o *ntfnObservers) remove(observer ntfnObserver) {
+	o.Lock()
+	delete(o.observers, observer)
+	o.Unlock()
+}
+
+// notify delivers the passed notification to all registered observers.
+//
+// This function is safe for concurrent access.
+func (o *ntfnObservers) notify(ntfn *rawNotification) {
+	o.Lock()
+	defer o.Unlock()
+
+	for observer := range o.observers {
+		observer.observeNotification(ntfn)
+	}
+}
+
+// maxNtfnQueueSize is the maximum number of notifications an ntfnQueue holds.
+// An observer which falls this far behind, such as because it is stuck on a
+// blocking request, loses the queued notifications and must resync instead.
+const maxNtfnQueueSize = 10000
+
+// ntfnQueue is a bounded queue of notifications which allows an observer to
+// hand notifications off to its own goroutine without blocking.  Once the
+// queue is full, the queued notifications are dropped and the consumer is told
+// how many were lost the next time it pops the queue, so it can resync.
+type ntfnQueue struct {
+	mtx     sync.Mutex
+	queue   []*rawNotification
+	dropped int
+	maxSize int
+	signal  chan struct{}
+}
+
+// newNtfnQueue returns a new empty notification queue.
+func newNtfnQueue() *ntfnQueue {
+	return &ntfnQueue{
+		maxSize: maxNtfnQueueSize,
+		signal:  make(chan struct{}, 1),
+	}
+}
+
+// push adds the passed notification to the queue and signals the consumer.  The
+// queued notifications, along with the passed one, are dropped when the queue
+// is full.  It never blocks.
+//
+// This function is safe for concurrent access.
+func (q *ntfnQueue) push(ntfn *rawNotification) {
+	q.mtx.Lock()
+	if len(q.queue) >= q.maxSize {
+		q.dropped += len(q.queue) + 1
+		q.queue = nil
+	} else {
+		q.queue = append(q.queue, ntfn)
+	}
+	q.mtx.Unlock()
+
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+// popAll removes and returns all queued notifications in the order they were
+// pushed, along with the number of notifications dropped since the previous
+// call because the queue was full.  The consumer must resync before processing
+// the returned notifications when any were dropped.
+//
+// This function is safe for concurrent access.
+func (q *ntfnQueue) popAll() ([]*rawNotification, int) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	ntfns, dropped := q.queue, q.dropped
+	q.queue = nil
+	q.dropped = 0
+	return ntfns, dropped
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestNtfnQueue ensures the notification queue keeps the order of the pushed
+// notifications and drops its backlog once full.
+func TestNtfnQueue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		maxSize int
+		pushed  int
+		queued  []string
+		dropped int
+	}{
+		{
+			name:    "empty",
+			maxSize: 3,
+		},
+		{
+			name:    "below bound",
+			maxSize: 3,
+			pushed:  3,
+			queued:  []string{"0", "1", "2"},
+		},
+		{
+			name:    "overflow drops backlog",
+			maxSize: 3,
+			pushed:  4,
+			dropped: 4,
+		},
+		{
+			name:    "queued after overflow",
+			maxSize: 3,
+			pushed:  6,
+			queued:  []string{"4", "5"},
+			dropped: 4,
+		},
+	}
+
+	for _, test := range tests {
+		q := newNtfnQueue()
+		q.maxSize = test.maxSize
+		for i := 0; i < test.pushed; i++ {
+			q.push(&rawNotification{Method: fmt.Sprint(i)})
+		}
+
+		ntfns, dropped := q.popAll()
+		if dropped != test.dropped {
+			t.Errorf("%s: got %d dropped, want %d", test.name,
+				dropped, test.dropped)
+		}
+		if len(ntfns) != len(test.queued) {
+			t.Errorf("%s: got %d queued, want %d", test.name,
+				len(ntfns), len(test.queued))
+			continue
+		}
+		for i, ntfn := range ntfns {
+			if ntfn.Method != test.queued[i] {
+				t.Errorf("%s: notification %d is %s, want %s",
+					test.name, i, ntfn.Method,
+					test.queued[i])
+			}
+		}
+
+		// Popping resets the queue and the dropped count.
+		ntfns, dropped = q.popAll()
+		if len(ntfns) != 0 || dropped != 0 {
+			t.Errorf("%s: queue not reset: %d queued, %d dropped",
+				test.name, len(ntfns), dropped)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// newRawNotification returns a new raw notification for the passed method with
+// each of the passed parameters marshalled the same way the RPC server would.
+func newRawNotification(method string, params ...interface{}) (*rawNotification, error) {
+	rawParams := make([]json.RawMessage, 0, len(params))
+	for _, param := range params {
+		marshalled, err := json.Marshal(param)
+		if err != nil {
+			return nil, err
+		}
+		rawParams = append(rawParams, marshalled)
+	}
+
+	return &rawNotification{Method: method, Params: rawParams}, nil
+}
+
+// emulateNotification creates a raw notification from the passed method and
+// parameters and delivers it exactly as if it had been received from the RPC
+// server.
+func (c *Client) emulateNotification(method string, params ...interface{}) {
+	ntfn, err := newRawNotification(method, params...)
+	if err != nil {
+		log.Warnf("Unable to create emulated [%s] notification: %v",
+			method, err)
+		return
+	}
+
+	log.Tracef("Emulating notification [%s]", method)
+	c.deliverNotification(ntfn)
+}
+
+// pollBlocks compares the main chain of the RPC server with the blocks the
+// client was last notified about and emulates the block disconnected and block
+// connected notifications for any differences.  The first poll only records
+// the current best block.
+func (c *Client) pollBlocks() error {
+	blockCount, err := c.GetBlockCount()
+	if err != nil {
+		return err
+	}
+	bestHeight := int32(blockCount)
+
+	// Start tracking from the current best block on the first poll.
+	if hash, _ := c.LastSeenBlock(); hash == nil {
+		hash, err := c.GetBlockHash(blockCount)
+		if err != nil {
+			return err
+		}
+		block, err := c.GetBlockVerbose(hash, false)
+		if err != nil {
+			return err
+		}
+		c.chainState.connectBlock(hash, bestHeight, time.Unix(block.Time, 0))
+		return nil
+	}
+
+	disconnected, connected, err := c.chainDiff(c.chainState.Blocks(), bestHeight)
+	if err != nil {
+		return err
+	}
+	for _, blk := range disconnected {
+		c.emulateNotification(btcjson.BlockDisconnectedNtfnMethod,
+			blk.hash.String(), blk.height, blk.time.Unix())
+	}
+	for _, blk := range connected {
+		c.emulateNotification(btcjson.BlockConnectedNtfnMethod,
+			blk.hash.String(), blk.height, blk.time.Unix())
+	}
+	return nil
+}
+
+// pollMempool compares the transactions in the memory pool of the RPC server
+// with the passed set of previously seen transactions and emulates the
+// transaction accepted notifications for each new transaction.  The returned
+// set should be passed to the next call.  No notifications are emulated when
+// the passed set is nil, which is the case on the first poll.
+func (c *Client) pollMempool(seen map[wire.ShaHash]struct{}, notifyNewTx,
+	notifyNewTxVerbose bool) (map[wire.ShaHash]struct{}, error) {
+
+	txHashes, err := c.GetRawMempool()
+	if err != nil {
+		return seen, err
+	}
+
+	mempool := make(map[wire.ShaHash]struct{}, len(txHashes))
+	for _, txHash := range txHashes {
+		mempool[*txHash] = struct{}{}
+		if seen == nil {
+			continue
+		}
+		if _, ok := seen[*txHash]; ok {
+			continue
+		}
+
+		// The transaction might have been mined or evicted since the
+		// memory pool was fetched, so lookup failures are not fatal.
+		if notifyNewTx {
+			tx, err := c.GetRawTransaction(txHash)
+			if err != nil {
+				log.Debugf("Unable to fetch accepted transaction "+
+					"%v: %v", txHash, err)
+				continue
+			}
+			var amount Amount
+			for _, txOut := range tx.MsgTx().TxOut {
+				amount += Amount(txOut.Value)
+			}
+			c.emulateNotification(btcjson.TxAcceptedNtfnMethod,
+				txHash.String(), amount)
+		}
+		if notifyNewTxVerbose {
+			rawTx, err := c.GetRawTransactionVerbose(txHash)
+			if err != nil {
+				log.Debugf("Unable to fetch accepted transaction "+
+					"%v: %v", txHash, err)
+				continue
+			}
+			c.emulateNotification(btcjson.TxAcceptedVerboseNtfnMethod,
+				rawTx)
+		}
+	}
+
+	return mempool, nil
+}
+
+// ntfnPoller periodically polls the RPC server for new blocks and memory pool
+// transactions in order to emulate the notifications registered with
+// NotifyBlocks and NotifyNewTransactions when running in HTTP POST mode.  It
+// must be run as a goroutine.
+func (c *Client) ntfnPoller() {
+	ticker := time.NewTicker(c.config.NtfnPollInterval)
+	defer ticker.Stop()
+
+	var mempool map[wire.ShaHash]struct{}
+out:
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.shutdown:
+			break out
+		}
+
+		c.ntfnState.Lock()
+		notifyBlocks := c.ntfnState.notifyBlocks
+		notifyNewTx := c.ntfnState.notifyNewTx
+		notifyNewTxVerbose := c.ntfnState.notifyNewTxVerbose
+		c.ntfnState.Unlock()
+
+		if notifyBlocks {
+			if err := c.pollBlocks(); err != nil {
+				log.Warnf("Unable to poll %s for blocks: %v",
+					c.config.Host, err)
+			}
+		}
+
+		if notifyNewTx || notifyNewTxVerbose {
+			var err error
+			mempool, err = c.pollMempool(mempool, notifyNewTx,
+				notifyNewTxVerbose)
+			if err != nil {
+				log.Warnf("Unable to poll %s for transactions: %v",
+					c.config.Host, err)
+			}
+		}
+	}
+	c.wg.Done()
+	log.Tracef("RPC client notification poller done for %s", c.config.Host)
+}
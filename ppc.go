@@ -8,6 +8,8 @@ import (
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"math/big"
 	"strconv"
 
 	"github.com/ppcsuite/ppcd/btcjson"
@@ -158,20 +160,66 @@ func (c *Client) GetNextRequiredTarget(proofOfStake bool) (uint32, error) {
 	return c.GetNextRequiredTargetAsync(proofOfStake).Receive()
 }
 
-// FutureGetNextRequiredTargetVerboseResult is a future promise to deliver the result of a
-// GetNextRequiredTargetVerboseAsync RPC invocation (or an applicable error).
+// maxNextRequiredTargetsTries is the number of times the next required targets
+// are requested when the best block changes while they are being requested.
+const maxNextRequiredTargetsTries = 3
+
+// RequiredTarget describes the target a block hash must not exceed.
+type RequiredTarget struct {
+	Bits       uint32
+	Target     *big.Int
+	Difficulty float64
+}
+
+// newRequiredTarget returns the details of the target with the passed compact
+// representation.
+func newRequiredTarget(bits uint32) RequiredTarget {
+	return RequiredTarget{
+		Bits:       bits,
+		Target:     CompactToTarget(bits),
+		Difficulty: CompactToDifficulty(bits),
+	}
+}
+
+// NextRequiredTargetResult houses the target required for the next
+// proof-of-stake or proof-of-work block as returned by the verbose form of the
+// getnextrequiredtarget RPC.
+type NextRequiredTargetResult struct {
+	RequiredTarget
+}
+
+// UnmarshalJSON unmarshals the result of the verbose getnextrequiredtarget RPC,
+// which carries the compact representation of the target, and derives the
+// target and difficulty from it.
+func (r *NextRequiredTargetResult) UnmarshalJSON(data []byte) error {
+	var result struct {
+		Target *uint32 `json:"target"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+	if result.Target == nil {
+		return errors.New("next required target result has no target")
+	}
+	r.RequiredTarget = newRequiredTarget(*result.Target)
+	return nil
+}
+
+// FutureGetNextRequiredTargetVerboseResult is a future promise to deliver the
+// result of a GetNextRequiredTargetVerboseAsync RPC invocation (or an
+// applicable error).
 type FutureGetNextRequiredTargetVerboseResult chan *response
 
-// Receive waits for the response promised by the future and returns the data
-// structure from the server with information about the requested block.
-func (r FutureGetNextRequiredTargetVerboseResult) Receive() (*btcjson.NextRequiredTargetResult, error) {
+// Receive waits for the response promised by the future and returns the
+// target required for the next block.
+func (r FutureGetNextRequiredTargetVerboseResult) Receive() (*NextRequiredTargetResult, error) {
 	res, err := receiveFuture(r)
 	if err != nil {
 		return nil, err
 	}
 
 	// Unmarshal the raw result into a NextRequiredTargetResult.
-	var nrtResult btcjson.NextRequiredTargetResult
+	var nrtResult NextRequiredTargetResult
 	err = json.Unmarshal(res, &nrtResult)
 	if err != nil {
 		return nil, err
@@ -179,26 +227,111 @@ func (r FutureGetNextRequiredTargetVerboseResult) Receive() (*btcjson.NextRequir
 	return &nrtResult, nil
 }
 
-// GetNextRequiredTargetVerboseAsync returns an instance of a type that can be used to get
-// the result of the RPC at some future time by invoking the Receive function on
-// the returned instance.
+// GetNextRequiredTargetVerboseAsync returns an instance of a type that can be
+// used to get the result of the RPC at some future time by invoking the
+// Receive function on the returned instance.
 //
 // See GetNextRequiredTargetVerbose for the blocking version and more details.
 func (c *Client) GetNextRequiredTargetVerboseAsync(proofOfStake bool) FutureGetNextRequiredTargetVerboseResult {
-
 	verbose := true
 	cmd := btcjson.NewGetNextRequiredTargetCmd(&proofOfStake, &verbose)
 
 	return c.sendCmd(cmd)
 }
 
-// GetNextRequiredTargetVerbose returns... TODO(mably)
+// GetNextRequiredTargetVerbose returns the compact representation, target, and
+// difficulty of the target required for the next proof-of-stake or
+// proof-of-work block.
 //
-// See GetNextRequiredTarget to retrieve a raw block instead.
-func (c *Client) GetNextRequiredTargetVerbose(proofOfStake bool) (*btcjson.NextRequiredTargetResult, error) {
+// See GetNextRequiredTarget to retrieve the compact target only, and
+// GetNextRequiredTargets to retrieve both targets along with the best block
+// they were computed against.
+func (c *Client) GetNextRequiredTargetVerbose(proofOfStake bool) (*NextRequiredTargetResult, error) {
 	return c.GetNextRequiredTargetVerboseAsync(proofOfStake).Receive()
 }
 
+// NextRequiredTargetsResult houses the targets required for the block
+// following the best block at the time of the request.
+type NextRequiredTargetsResult struct {
+	BlockHash    *wire.ShaHash
+	BlockHeight  int32
+	ProofOfStake RequiredTarget
+	ProofOfWork  RequiredTarget
+}
+
+// FutureGetNextRequiredTargetsResult is a future promise to deliver the result
+// of a GetNextRequiredTargetsAsync invocation (or an applicable error).
+type FutureGetNextRequiredTargetsResult struct {
+	client    *Client
+	bestBlock FutureGetBestBlockResult
+	pos       FutureNextRequiredTargetResult
+	pow       FutureNextRequiredTargetResult
+	bestAfter FutureGetBestBlockResult
+}
+
+// Receive waits for the responses promised by the future and returns the
+// targets required for the next block.
+func (r *FutureGetNextRequiredTargetsResult) Receive() (*NextRequiredTargetsResult, error) {
+	for tries := 1; ; tries++ {
+		hash, height, err := r.bestBlock.Receive()
+		if err != nil {
+			return nil, err
+		}
+		posBits, err := r.pos.Receive()
+		if err != nil {
+			return nil, err
+		}
+		powBits, err := r.pow.Receive()
+		if err != nil {
+			return nil, err
+		}
+		hashAfter, _, err := r.bestAfter.Receive()
+		if err != nil {
+			return nil, err
+		}
+
+		// Request the targets again when a block was connected while
+		// they were being requested, since they might have been
+		// computed against different blocks.
+		if !hash.IsEqual(hashAfter) && tries < maxNextRequiredTargetsTries {
+			*r = *r.client.GetNextRequiredTargetsAsync()
+			continue
+		}
+
+		return &NextRequiredTargetsResult{
+			BlockHash:    hash,
+			BlockHeight:  height,
+			ProofOfStake: newRequiredTarget(posBits),
+			ProofOfWork:  newRequiredTarget(powBits),
+		}, nil
+	}
+}
+
+// GetNextRequiredTargetsAsync returns an instance of a type that can be used to
+// get the result of the RPCs at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetNextRequiredTargets for the blocking version and more details.
+func (c *Client) GetNextRequiredTargetsAsync() *FutureGetNextRequiredTargetsResult {
+	return &FutureGetNextRequiredTargetsResult{
+		client:    c,
+		bestBlock: c.GetBestBlockAsync(),
+		pos:       c.GetNextRequiredTargetAsync(true),
+		pow:       c.GetNextRequiredTargetAsync(false),
+		bestAfter: c.GetBestBlockAsync(),
+	}
+}
+
+// GetNextRequiredTargets returns the targets required for the next
+// proof-of-stake and proof-of-work blocks, along with the best block they were
+// computed against.  The targets are requested again, up to three times in
+// total, when a block is connected while they are being requested.
+//
+// NOTE: This is a btcd extension since it relies on GetBestBlock.
+func (c *Client) GetNextRequiredTargets() (*NextRequiredTargetsResult, error) {
+	return c.GetNextRequiredTargetsAsync().Receive()
+}
+
 // FutureLastProofOfWorkRewardResult is a future promise to deliver the result of a
 // GetNextRequiredTargetAsync RPC invocation (or an applicable error).
 type FutureLastProofOfWorkRewardResult chan *response
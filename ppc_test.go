@@ -0,0 +1,53 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// TestNextRequiredTargetResultUnmarshalJSON ensures the target and difficulty
+// are derived from the compact target of the verbose getnextrequiredtarget
+// result.
+func TestNextRequiredTargetResultUnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in    string
+		bits  uint32
+		valid bool
+	}{
+		{`{"target":486604799}`, 0x1d00ffff, true},
+		{`{"target":503382015}`, 0x1e00ffff, true},
+		{`{}`, 0, false},
+		{`{"target":"1d00ffff"}`, 0, false},
+		{`{"target":-1}`, 0, false},
+	}
+
+	for _, test := range tests {
+		var result NextRequiredTargetResult
+		err := json.Unmarshal([]byte(test.in), &result)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("Unmarshal(%s): expected error", test.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%s): unexpected error: %v", test.in,
+				err)
+			continue
+		}
+		want := newRequiredTarget(test.bits)
+		if result.Bits != want.Bits ||
+			result.Target.Cmp(want.Target) != 0 ||
+			result.Difficulty != want.Difficulty {
+
+			t.Errorf("Unmarshal(%s): got %+v, want %+v", test.in,
+				result.RequiredTarget, want)
+		}
+	}
+}
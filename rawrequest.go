@@ -76,3 +76,42 @@ func (c *Client) RawRequestAsync(method string, params []json.RawMessage) Future
 func (c *Client) RawRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
 	return c.RawRequestAsync(method, params).Receive()
 }
+
+// sendRawCmd sends a request for the passed method, which is not known to
+// btcjson, with each of the passed parameters marshalled to JSON.  The passed
+// command is associated with the request so it can be examined once the
+// request completes successfully, such as to track the notification state.
+func (c *Client) sendRawCmd(method string, cmd interface{}, params ...interface{}) chan *response {
+	rawParams := make([]json.RawMessage, 0, len(params))
+	for _, param := range params {
+		marshalled, err := json.Marshal(param)
+		if err != nil {
+			return newFutureError(err)
+		}
+		rawParams = append(rawParams, marshalled)
+	}
+
+	id := c.NextID()
+	rawRequest := &btcjson.Request{
+		Jsonrpc: "1.0",
+		ID:      id,
+		Method:  method,
+		Params:  rawParams,
+	}
+	marshalledJSON, err := json.Marshal(rawRequest)
+	if err != nil {
+		return newFutureError(err)
+	}
+
+	responseChan := make(chan *response, 1)
+	jReq := &jsonRequest{
+		id:             id,
+		method:         method,
+		cmd:            cmd,
+		marshalledJSON: marshalledJSON,
+		responseChan:   responseChan,
+	}
+	c.sendRequest(jReq)
+
+	return responseChan
+}
@@ -8,6 +8,8 @@ import (
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/ppcsuite/btcutil"
 	"github.com/ppcsuite/ppcd/btcjson"
@@ -113,6 +115,38 @@ func (c *Client) GetRawTransaction(txHash *wire.ShaHash) (*btcutil.Tx, error) {
 	return c.GetRawTransactionAsync(txHash).Receive()
 }
 
+// Vout models an output of a transaction returned by the getrawtransaction and
+// decoderawtransaction commands, with the value of the output decoded exactly.
+// The float64 Value of the embedded result is decoded as well for
+// compatibility.
+type Vout struct {
+	btcjson.Vout
+	Value Amount `json:"value"`
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface and unmarshals both
+// the exact value and the embedded result.
+func (v *Vout) UnmarshalJSON(data []byte) error {
+	type vout Vout
+	return unmarshalAmounts(data, (*vout)(v), &v.Vout)
+}
+
+// TxRawResult models the data of a transaction returned by the
+// getrawtransaction, decoderawtransaction, and searchrawtransactions commands,
+// with the values of the outputs decoded exactly.  The outputs of the embedded
+// result are decoded as well for compatibility.
+type TxRawResult struct {
+	btcjson.TxRawResult
+	Vout []Vout `json:"vout"`
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface and unmarshals both
+// the exact outputs and the embedded result.
+func (r *TxRawResult) UnmarshalJSON(data []byte) error {
+	type result TxRawResult
+	return unmarshalAmounts(data, (*result)(r), &r.TxRawResult)
+}
+
 // FutureGetRawTransactionVerboseResult is a future promise to deliver the
 // result of a GetRawTransactionVerboseAsync RPC invocation (or an applicable
 // error).
@@ -120,14 +154,14 @@ type FutureGetRawTransactionVerboseResult chan *response
 
 // Receive waits for the response promised by the future and returns information
 // about a transaction given its hash.
-func (r FutureGetRawTransactionVerboseResult) Receive() (*btcjson.TxRawResult, error) {
+func (r FutureGetRawTransactionVerboseResult) Receive() (*TxRawResult, error) {
 	res, err := receiveFuture(r)
 	if err != nil {
 		return nil, err
 	}
 
 	// Unmarshal result as a gettrawtransaction result object.
-	var rawTxResult btcjson.TxRawResult
+	var rawTxResult TxRawResult
 	err = json.Unmarshal(res, &rawTxResult)
 	if err != nil {
 		return nil, err
@@ -155,24 +189,88 @@ func (c *Client) GetRawTransactionVerboseAsync(txHash *wire.ShaHash) FutureGetRa
 // its hash.
 //
 // See GetRawTransaction to obtain only the transaction already deserialized.
-func (c *Client) GetRawTransactionVerbose(txHash *wire.ShaHash) (*btcjson.TxRawResult, error) {
+func (c *Client) GetRawTransactionVerbose(txHash *wire.ShaHash) (*TxRawResult, error) {
 	return c.GetRawTransactionVerboseAsync(txHash).Receive()
 }
 
+// TxRawResultWithTime houses the information about a transaction returned by
+// the server along with the timestamp of the transaction, which Peercoin
+// transactions carry in addition to the time of the block they are mined in.
+type TxRawResultWithTime struct {
+	*TxRawResult
+	TxTime time.Time
+}
+
+// newTxRawResultWithTime returns the passed information about a transaction
+// along with its timestamp, which is read from the serialized transaction.
+func newTxRawResultWithTime(result *TxRawResult,
+	serializedTx []byte) (*TxRawResultWithTime, error) {
+
+	var msgTx wire.MsgTx
+	if err := msgTx.Deserialize(bytes.NewReader(serializedTx)); err != nil {
+		return nil, err
+	}
+	return &TxRawResultWithTime{TxRawResult: result, TxTime: msgTx.Time}, nil
+}
+
+// FutureGetRawTransactionVerboseWithTimeResult is a future promise to deliver
+// the result of a GetRawTransactionVerboseWithTimeAsync RPC invocation (or an
+// applicable error).
+type FutureGetRawTransactionVerboseWithTimeResult struct {
+	future FutureGetRawTransactionVerboseResult
+}
+
+// Receive waits for the response promised by the future and returns information
+// about a transaction along with its timestamp.
+func (r FutureGetRawTransactionVerboseWithTimeResult) Receive() (*TxRawResultWithTime, error) {
+	result, err := r.future.Receive()
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode the serialized transaction hex to raw bytes.
+	serializedTx, err := hex.DecodeString(result.Hex)
+	if err != nil {
+		return nil, err
+	}
+	return newTxRawResultWithTime(result, serializedTx)
+}
+
+// GetRawTransactionVerboseWithTimeAsync returns an instance of a type that can
+// be used to get the result of the RPC at some future time by invoking the
+// Receive function on the returned instance.
+//
+// See GetRawTransactionVerboseWithTime for the blocking version and more
+// details.
+func (c *Client) GetRawTransactionVerboseWithTimeAsync(txHash *wire.ShaHash) FutureGetRawTransactionVerboseWithTimeResult {
+	return FutureGetRawTransactionVerboseWithTimeResult{
+		future: c.GetRawTransactionVerboseAsync(txHash),
+	}
+}
+
+// GetRawTransactionVerboseWithTime returns information about a transaction
+// given its hash along with its timestamp.
+//
+// See GetRawTransactionVerbose to obtain the information without the
+// timestamp.
+func (c *Client) GetRawTransactionVerboseWithTime(txHash *wire.ShaHash) (*TxRawResultWithTime, error) {
+	return c.GetRawTransactionVerboseWithTimeAsync(txHash).Receive()
+}
+
 // FutureDecodeRawTransactionResult is a future promise to deliver the result
 // of a DecodeRawTransactionAsync RPC invocation (or an applicable error).
 type FutureDecodeRawTransactionResult chan *response
 
 // Receive waits for the response promised by the future and returns information
 // about a transaction given its serialized bytes.
-func (r FutureDecodeRawTransactionResult) Receive() (*btcjson.TxRawResult, error) {
+func (r FutureDecodeRawTransactionResult) Receive() (*TxRawResult, error) {
 	res, err := receiveFuture(r)
 	if err != nil {
 		return nil, err
 	}
 
 	// Unmarshal result as a decoderawtransaction result object.
-	var rawTxResult btcjson.TxRawResult
+	var rawTxResult TxRawResult
 	err = json.Unmarshal(res, &rawTxResult)
 	if err != nil {
 		return nil, err
@@ -194,10 +292,46 @@ func (c *Client) DecodeRawTransactionAsync(serializedTx []byte) FutureDecodeRawT
 
 // DecodeRawTransaction returns information about a transaction given its
 // serialized bytes.
-func (c *Client) DecodeRawTransaction(serializedTx []byte) (*btcjson.TxRawResult, error) {
+func (c *Client) DecodeRawTransaction(serializedTx []byte) (*TxRawResult, error) {
 	return c.DecodeRawTransactionAsync(serializedTx).Receive()
 }
 
+// FutureDecodeRawTransactionWithTimeResult is a future promise to deliver the
+// result of a DecodeRawTransactionWithTimeAsync RPC invocation (or an
+// applicable error).
+type FutureDecodeRawTransactionWithTimeResult struct {
+	future       FutureDecodeRawTransactionResult
+	serializedTx []byte
+}
+
+// Receive waits for the response promised by the future and returns information
+// about a transaction given its serialized bytes along with its timestamp.
+func (r FutureDecodeRawTransactionWithTimeResult) Receive() (*TxRawResultWithTime, error) {
+	result, err := r.future.Receive()
+	if err != nil {
+		return nil, err
+	}
+	return newTxRawResultWithTime(result, r.serializedTx)
+}
+
+// DecodeRawTransactionWithTimeAsync returns an instance of a type that can be
+// used to get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See DecodeRawTransactionWithTime for the blocking version and more details.
+func (c *Client) DecodeRawTransactionWithTimeAsync(serializedTx []byte) FutureDecodeRawTransactionWithTimeResult {
+	return FutureDecodeRawTransactionWithTimeResult{
+		future:       c.DecodeRawTransactionAsync(serializedTx),
+		serializedTx: serializedTx,
+	}
+}
+
+// DecodeRawTransactionWithTime returns information about a transaction given
+// its serialized bytes along with its timestamp.
+func (c *Client) DecodeRawTransactionWithTime(serializedTx []byte) (*TxRawResultWithTime, error) {
+	return c.DecodeRawTransactionWithTimeAsync(serializedTx).Receive()
+}
+
 // FutureCreateRawTransactionResult is a future promise to deliver the result
 // of a CreateRawTransactionAsync RPC invocation (or an applicable error).
 type FutureCreateRawTransactionResult chan *response
@@ -240,9 +374,15 @@ func (r FutureCreateRawTransactionResult) Receive() (*wire.MsgTx, error) {
 func (c *Client) CreateRawTransactionAsync(inputs []btcjson.TransactionInput,
 	amounts map[btcutil.Address]btcutil.Amount) FutureCreateRawTransactionResult {
 
+	if c.config.FeePolicy != nil {
+		if err := c.config.FeePolicy.checkAmounts(amounts); err != nil {
+			return newFutureError(err)
+		}
+	}
+
 	convertedAmts := make(map[string]float64, len(amounts))
 	for addr, amount := range amounts {
-		convertedAmts[addr.String()] = amount.ToBTC()
+		convertedAmts[addr.String()] = Amount(amount).ToPPC()
 	}
 	cmd := btcjson.NewCreateRawTransactionCmd(inputs, convertedAmts)
 	return c.sendCmd(cmd)
@@ -250,12 +390,68 @@ func (c *Client) CreateRawTransactionAsync(inputs []btcjson.TransactionInput,
 
 // CreateRawTransaction returns a new transaction spending the provided inputs
 // and sending to the provided addresses.
+//
+// When the FeePolicy config option is set, a *DustOutputError is returned
+// without creating the transaction if one of the amounts is less than the
+// minimum output value.
 func (c *Client) CreateRawTransaction(inputs []btcjson.TransactionInput,
 	amounts map[btcutil.Address]btcutil.Amount) (*wire.MsgTx, error) {
 
 	return c.CreateRawTransactionAsync(inputs, amounts).Receive()
 }
 
+// FutureCreateRawTransactionWithTimeResult is a future promise to deliver the
+// result of a CreateRawTransactionWithTimeAsync RPC invocation (or an
+// applicable error).
+type FutureCreateRawTransactionWithTimeResult struct {
+	future FutureCreateRawTransactionResult
+	txTime time.Time
+}
+
+// Receive waits for the response promised by the future and returns a new
+// transaction with the requested timestamp spending the provided inputs and
+// sending to the provided addresses.
+func (r FutureCreateRawTransactionWithTimeResult) Receive() (*wire.MsgTx, error) {
+	msgTx, err := r.future.Receive()
+	if err != nil {
+		return nil, err
+	}
+
+	// The server stamps the transaction with its current time.  Since the
+	// transaction is not signed yet, the timestamp can be replaced.
+	msgTx.Time = r.txTime
+	return msgTx, nil
+}
+
+// CreateRawTransactionWithTimeAsync returns an instance of a type that can be
+// used to get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See CreateRawTransactionWithTime for the blocking version and more details.
+func (c *Client) CreateRawTransactionWithTimeAsync(inputs []btcjson.TransactionInput,
+	amounts map[btcutil.Address]btcutil.Amount,
+	txTime time.Time) FutureCreateRawTransactionWithTimeResult {
+
+	// Transaction timestamps only have a precision of one second.
+	return FutureCreateRawTransactionWithTimeResult{
+		future: c.CreateRawTransactionAsync(inputs, amounts),
+		txTime: time.Unix(txTime.Unix(), 0),
+	}
+}
+
+// CreateRawTransactionWithTime returns a new transaction with the passed
+// timestamp spending the provided inputs and sending to the provided addresses.
+// The timestamp must not be earlier than the timestamps of the transactions
+// creating the inputs and, for the transaction to be accepted, not later than
+// the network time.
+//
+// See CreateRawTransaction to use the current time of the server instead.
+func (c *Client) CreateRawTransactionWithTime(inputs []btcjson.TransactionInput,
+	amounts map[btcutil.Address]btcutil.Amount, txTime time.Time) (*wire.MsgTx, error) {
+
+	return c.CreateRawTransactionWithTimeAsync(inputs, amounts, txTime).Receive()
+}
+
 // FutureSendRawTransactionResult is a future promise to deliver the result
 // of a SendRawTransactionAsync RPC invocation (or an applicable error).
 type FutureSendRawTransactionResult chan *response
@@ -287,6 +483,12 @@ func (r FutureSendRawTransactionResult) Receive() (*wire.ShaHash, error) {
 func (c *Client) SendRawTransactionAsync(tx *wire.MsgTx, allowHighFees bool) FutureSendRawTransactionResult {
 	txHex := ""
 	if tx != nil {
+		if c.config.ValidateTxTime {
+			if err := checkTxTime(tx, time.Now()); err != nil {
+				return newFutureError(err)
+			}
+		}
+
 		// Serialize the transaction and convert to hex string.
 		buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
 		if err := tx.Serialize(buf); err != nil {
@@ -296,15 +498,86 @@ func (c *Client) SendRawTransactionAsync(tx *wire.MsgTx, allowHighFees bool) Fut
 	}
 
 	cmd := btcjson.NewSendRawTransactionCmd(txHex, &allowHighFees)
+	if tx != nil && c.config.FeePolicy != nil {
+		return c.sendCheckedRawTransaction(tx, cmd)
+	}
 	return c.sendCmd(cmd)
 }
 
 // SendRawTransaction submits the encoded transaction to the server which will
 // then relay it to the network.
+//
+// When the ValidateTxTime config option is set, a *TxTimeError is returned
+// without submitting the transaction if its timestamp is later than the local
+// clock.
+//
+// When the FeePolicy config option is set, a *DustOutputError or an
+// *InsufficientFeeError is returned without submitting the transaction if it
+// does not follow the fee policy.
 func (c *Client) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*wire.ShaHash, error) {
 	return c.SendRawTransactionAsync(tx, allowHighFees).Receive()
 }
 
+// TxTimeError describes a transaction whose timestamp is later than the network
+// time, which the server rejects.
+type TxTimeError struct {
+	TxHash wire.ShaHash
+	TxTime time.Time
+
+	// NetworkTime is the time the timestamp was checked against.
+	NetworkTime time.Time
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *TxTimeError) Error() string {
+	return fmt.Sprintf("timestamp %v of transaction %v is %v later than "+
+		"the network time", e.TxTime, e.TxHash,
+		e.TxTime.Sub(e.NetworkTime))
+}
+
+// checkTxTime returns a *TxTimeError if the timestamp of the passed transaction
+// is later than the passed network time.
+func checkTxTime(tx *wire.MsgTx, networkTime time.Time) error {
+	networkTime = time.Unix(networkTime.Unix(), 0)
+	if !tx.Time.After(networkTime) {
+		return nil
+	}
+	return &TxTimeError{
+		TxHash:      tx.TxSha(),
+		TxTime:      tx.Time,
+		NetworkTime: networkTime,
+	}
+}
+
+// CheckTransactionTime returns a *TxTimeError if the timestamp of the passed
+// transaction is later than the network time, which is taken as the later of
+// the local clock and the time of the best block of the server.  The server
+// would reject such a transaction.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) CheckTransactionTime(tx *wire.MsgTx) error {
+	networkTime := time.Now()
+	if !tx.Time.After(networkTime) {
+		return nil
+	}
+
+	// The local clock may be behind, in which case the best block gives a
+	// later network time.
+	hash, err := c.GetBestBlockHash()
+	if err != nil {
+		return err
+	}
+	block, err := c.GetBlockVerbose(hash, false)
+	if err != nil {
+		return err
+	}
+	if blockTime := time.Unix(block.Time, 0); blockTime.After(networkTime) {
+		networkTime = blockTime
+	}
+	return checkTxTime(tx, networkTime)
+}
+
 // FutureSignRawTransactionResult is a future promise to deliver the result
 // of one of the SignRawTransactionAsync family of RPC invocations (or an
 // applicable error).
@@ -574,14 +847,14 @@ type FutureSearchRawTransactionsVerboseResult chan *response
 
 // Receive waits for the response promised by the future and returns the
 // found raw transactions.
-func (r FutureSearchRawTransactionsVerboseResult) Receive() ([]*btcjson.TxRawResult, error) {
+func (r FutureSearchRawTransactionsVerboseResult) Receive() ([]*TxRawResult, error) {
 	res, err := receiveFuture(r)
 	if err != nil {
 		return nil, err
 	}
 
 	// Unmarshal as an array of raw transaction results.
-	var result []*btcjson.TxRawResult
+	var result []*TxRawResult
 	err = json.Unmarshal(res, &result)
 	if err != nil {
 		return nil, err
@@ -609,6 +882,6 @@ func (c *Client) SearchRawTransactionsVerboseAsync(address btcutil.Address, skip
 // specifically been enabled.
 //
 // See SearchRawTransactions to retrieve a list of raw transactions instead.
-func (c *Client) SearchRawTransactionsVerbose(address btcutil.Address, skip, count int) ([]*btcjson.TxRawResult, error) {
+func (c *Client) SearchRawTransactionsVerbose(address btcutil.Address, skip, count int) ([]*TxRawResult, error) {
 	return c.SearchRawTransactionsVerboseAsync(address, skip, count).Receive()
 }
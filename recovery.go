@@ -0,0 +1,370 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+const (
+	// maxSeenBlocks is the maximum number of recently connected blocks
+	// tracked by the client in order to detect which blocks were
+	// disconnected from the main chain while the client was not connected
+	// to the RPC server.
+	maxSeenBlocks = 100
+)
+
+// seenBlock houses details about a block the client has been notified about
+// being connected to the main chain.
+type seenBlock struct {
+	hash   wire.ShaHash
+	height int32
+	time   time.Time
+}
+
+// chainNtfnState is used to track the most recent blocks the client has been
+// notified about so any block connected and disconnected notifications missed
+// while the client was disconnected can be replayed on reconnect.
+type chainNtfnState struct {
+	sync.Mutex
+	blocks      []seenBlock // ordered by increasing height
+	subscribers map[chan struct{}]struct{}
+}
+
+// newChainNtfnState returns a new chain notification state ready to track
+// connected blocks.
+func newChainNtfnState() *chainNtfnState {
+	return &chainNtfnState{
+		blocks:      make([]seenBlock, 0, maxSeenBlocks),
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+}
+
+// subscribe returns a channel which is signaled each time a block is connected
+// to or disconnected from the main chain.  The channel is buffered and signals
+// are coalesced, so a receiver which falls behind only sees a single pending
+// signal.
+//
+// This function is safe for concurrent access.
+func (s *chainNtfnState) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	s.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.Unlock()
+	return ch
+}
+
+// unsubscribe stops signaling the passed channel, which must have been returned
+// by a previous call to subscribe.
+//
+// This function is safe for concurrent access.
+func (s *chainNtfnState) unsubscribe(ch chan struct{}) {
+	s.Lock()
+	delete(s.subscribers, ch)
+	s.Unlock()
+}
+
+// signalSubscribers signals all subscribed channels without blocking.
+//
+// This function MUST be called with the state lock held.
+func (s *chainNtfnState) signalSubscribers() {
+	for ch := range s.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// connectBlock records the passed block as the new tip of the main chain.
+// Any previously tracked blocks at the same height or higher are removed since
+// they are no longer part of the main chain.
+//
+// This function is safe for concurrent access.
+func (s *chainNtfnState) connectBlock(hash *wire.ShaHash, height int32, t time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.removeFrom(height)
+	if len(s.blocks) == maxSeenBlocks {
+		copy(s.blocks, s.blocks[1:])
+		s.blocks = s.blocks[:len(s.blocks)-1]
+	}
+	s.blocks = append(s.blocks, seenBlock{hash: *hash, height: height, time: t})
+	s.signalSubscribers()
+}
+
+// disconnectBlock removes the block at the passed height, along with any
+// tracked blocks above it, from the tracked main chain.
+//
+// This function is safe for concurrent access.
+func (s *chainNtfnState) disconnectBlock(height int32) {
+	s.Lock()
+	s.removeFrom(height)
+	s.signalSubscribers()
+	s.Unlock()
+}
+
+// removeFrom removes all tracked blocks with a height greater than or equal to
+// the passed height.
+//
+// This function MUST be called with the state lock held.
+func (s *chainNtfnState) removeFrom(height int32) {
+	i := len(s.blocks)
+	for i > 0 && s.blocks[i-1].height >= height {
+		i--
+	}
+	s.blocks = s.blocks[:i]
+}
+
+// Blocks returns a copy of the tracked blocks ordered by increasing height.
+//
+// This function is safe for concurrent access.
+func (s *chainNtfnState) Blocks() []seenBlock {
+	s.Lock()
+	defer s.Unlock()
+
+	blocks := make([]seenBlock, len(s.blocks))
+	copy(blocks, s.blocks)
+	return blocks
+}
+
+// LastSeenBlock returns the hash and height of the most recent block the client
+// has been notified about being connected to the main chain.  The returned hash
+// is nil when no block connected notifications have been received yet.
+//
+// This function is safe for concurrent access.
+func (c *Client) LastSeenBlock() (*wire.ShaHash, int32) {
+	c.chainState.Lock()
+	defer c.chainState.Unlock()
+
+	if len(c.chainState.blocks) == 0 {
+		return nil, 0
+	}
+	best := c.chainState.blocks[len(c.chainState.blocks)-1]
+	hash := best.hash
+	return &hash, best.height
+}
+
+// contains returns whether or not the block with the passed hash is tracked as
+// part of the main chain.
+//
+// This function is safe for concurrent access.
+func (s *chainNtfnState) contains(hash *wire.ShaHash) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	for i := range s.blocks {
+		if s.blocks[i].hash.IsEqual(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// deliverNotification delivers the passed notification to the notification
+// handlers.  Notifications are delivered one at a time regardless of whether
+// they are received from the server or emulated by the client, and they are
+// queued instead while missed notifications are being replayed.
+func (c *Client) deliverNotification(ntfn *rawNotification) {
+	c.ntfnMtx.Lock()
+	defer c.ntfnMtx.Unlock()
+
+	if c.ntfnHeld {
+		c.heldNtfns = append(c.heldNtfns, ntfn)
+		return
+	}
+	c.handleNotification(ntfn)
+}
+
+// holdNotifications queues the notifications received from now on until
+// releaseNotifications is called, so missed notifications can be replayed
+// before them.
+func (c *Client) holdNotifications() {
+	c.ntfnMtx.Lock()
+	c.ntfnHeld = true
+	c.ntfnMtx.Unlock()
+}
+
+// releaseNotifications delivers the passed replayed notifications followed by
+// the notifications queued since holdNotifications was called, and then stops
+// queueing notifications.  Queued block connected notifications for blocks
+// which were already replayed are dropped.  It does nothing when notifications
+// are not held.
+func (c *Client) releaseNotifications(replayed []*rawNotification) {
+	c.ntfnMtx.Lock()
+	defer c.ntfnMtx.Unlock()
+
+	if !c.ntfnHeld {
+		return
+	}
+	for _, ntfn := range replayed {
+		c.handleNotification(ntfn)
+	}
+	for _, ntfn := range c.heldNtfns {
+		if ntfn.Method == btcjson.BlockConnectedNtfnMethod {
+			hash, _, _, err := parseChainNtfnParams(ntfn.Params)
+			if err == nil && c.chainState.contains(hash) {
+				continue
+			}
+		}
+		c.handleNotification(ntfn)
+	}
+	c.heldNtfns = nil
+	c.ntfnHeld = false
+}
+
+// chainDiff compares the passed blocks the client was notified about with the
+// main chain of the RPC server, which has the passed best height, and returns
+// the tracked blocks which are no longer part of the main chain, ordered from
+// the tip down, followed by the blocks connected to the main chain since the
+// fork point, ordered by increasing height.  Nothing is returned when no blocks
+// have been tracked yet.
+//
+// This function issues blocking RPCs, so it must not be called from the input
+// handler.
+func (c *Client) chainDiff(seen []seenBlock, bestHeight int32) (disconnected, connected []seenBlock, err error) {
+	if len(seen) == 0 {
+		return nil, nil, nil
+	}
+
+	// Find the most recent seen block that is still part of the main chain.
+	fork := -1
+	for i := len(seen) - 1; i >= 0; i-- {
+		if seen[i].height > bestHeight {
+			continue
+		}
+		hash, err := c.GetBlockHash(int64(seen[i].height))
+		if err != nil {
+			return nil, nil, err
+		}
+		if hash.IsEqual(&seen[i].hash) {
+			fork = i
+			break
+		}
+	}
+
+	// The blocks after the fork point are no longer part of the main
+	// chain.
+	for i := len(seen) - 1; i > fork; i-- {
+		disconnected = append(disconnected, seen[i])
+	}
+
+	startHeight := seen[0].height
+	if fork == -1 {
+		log.Warnf("No common block found in the last %d seen blocks, "+
+			"treating blocks from height %d as connected", len(seen),
+			startHeight)
+	} else {
+		startHeight = seen[fork].height + 1
+	}
+
+	// Look up all blocks connected to the main chain since the fork point.
+	for height := startHeight; height <= bestHeight; height++ {
+		hash, err := c.GetBlockHash(int64(height))
+		if err != nil {
+			return nil, nil, err
+		}
+		block, err := c.GetBlockVerbose(hash, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		connected = append(connected, seenBlock{
+			hash:   *hash,
+			height: height,
+			time:   time.Unix(block.Time, 0),
+		})
+	}
+
+	return disconnected, connected, nil
+}
+
+// replayMissedBlocks compares the passed blocks the client was notified about
+// before it was disconnected with the current main chain of the RPC server and
+// delivers the block disconnected and block connected notifications that were
+// missed while the client was disconnected, followed by the notifications held
+// since the client reconnected.  When the RescanMissedBlocks config option is
+// set, the registered addresses and outpoints are also rescanned over the range
+// of missed blocks so any missed recvtx and redeemingtx notifications are
+// delivered as well.
+//
+// This function issues blocking RPCs, so it must not be called from the input
+// handler.  It is intended to be called by the resendRequests function once the
+// client has reconnected.
+func (c *Client) replayMissedBlocks(seen []seenBlock) error {
+	// Nothing to do if the caller is not interested in block
+	// notifications.
+	if c.ntfnHandlers == nil {
+		return nil
+	}
+	stateCopy := c.ntfnState.Copy()
+	if !stateCopy.notifyBlocks {
+		return nil
+	}
+
+	bestHash, bestHeight, err := c.GetBestBlock()
+	if err != nil {
+		return err
+	}
+	disconnected, connected, err := c.chainDiff(seen, bestHeight)
+	if err != nil {
+		return err
+	}
+
+	// Notify the blocks which are no longer part of the main chain as
+	// disconnected, from the tip down, followed by all blocks connected to
+	// the main chain since the fork point.  The notifications are delivered
+	// exactly as if they had been received from the RPC server, before any
+	// notification received since the client reconnected.
+	replayed := make([]*rawNotification, 0, len(disconnected)+len(connected))
+	for i := range disconnected {
+		blk := &disconnected[i]
+		log.Debugf("Replaying missed [blockdisconnected] for block %v "+
+			"(height %d)", blk.hash, blk.height)
+		ntfn, err := newRawNotification(btcjson.BlockDisconnectedNtfnMethod,
+			blk.hash.String(), blk.height, blk.time.Unix())
+		if err != nil {
+			return err
+		}
+		replayed = append(replayed, ntfn)
+	}
+	for i := range connected {
+		blk := &connected[i]
+		log.Debugf("Replaying missed [blockconnected] for block %v "+
+			"(height %d)", blk.hash, blk.height)
+		ntfn, err := newRawNotification(btcjson.BlockConnectedNtfnMethod,
+			blk.hash.String(), blk.height, blk.time.Unix())
+		if err != nil {
+			return err
+		}
+		replayed = append(replayed, ntfn)
+	}
+	c.releaseNotifications(replayed)
+
+	// Rescan the missed blocks for the registered addresses and outpoints
+	// if requested.
+	if !c.config.RescanMissedBlocks || len(connected) == 0 {
+		return nil
+	}
+	if len(stateCopy.notifyReceived) == 0 && len(stateCopy.notifySpent) == 0 {
+		return nil
+	}
+	addresses := make([]string, 0, len(stateCopy.notifyReceived))
+	for addr := range stateCopy.notifyReceived {
+		addresses = append(addresses, addr)
+	}
+	outpoints := make([]btcjson.OutPoint, 0, len(stateCopy.notifySpent))
+	for op := range stateCopy.notifySpent {
+		outpoints = append(outpoints, op)
+	}
+	log.Debugf("Rescanning missed blocks %d through %d",
+		connected[0].height, bestHeight)
+	return c.rescanInternal(connected[0].hash.String(), addresses,
+		outpoints, bestHash.String()).Receive()
+}
@@ -0,0 +1,500 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/chaincfg"
+	"github.com/ppcsuite/ppcd/txscript"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// ErrRescanCanceled is an error to describe the condition where a rescan
+// started with RescanRangeAsync was canceled by the caller before it finished.
+var ErrRescanCanceled = errors.New("rescan canceled")
+
+const (
+	// defaultRescanChunkSize is the number of blocks rescanned by each
+	// rescan request issued by RescanRangeAsync when no chunk size is
+	// specified.
+	defaultRescanChunkSize = 10000
+
+	// rescanProgressBufferSize is the number of rescan progress updates
+	// that can be queued before further updates are dropped.
+	rescanProgressBufferSize = 20
+)
+
+// rescanNtfn describes a rescanprogress or rescanfinished notification.
+type rescanNtfn struct {
+	height   int32
+	finished bool
+}
+
+// rescanSubscription receives the rescan notifications for a rescan started
+// with RescanRangeAsync and collects the outpoints of the mined outputs paying
+// to the addresses of the rescan, which the server only watches for spends
+// during the rescan request that found them.
+type rescanSubscription struct {
+	ntfns     chan rescanNtfn
+	addresses map[string]struct{}
+
+	// found is protected by the mutex of the subscribers.
+	found map[btcjson.OutPoint]struct{}
+}
+
+// rescanNtfnSubscribers delivers the rescanprogress and rescanfinished
+// notifications to the rescans started with RescanRangeAsync.
+type rescanNtfnSubscribers struct {
+	sync.Mutex
+	subscribers map[*rescanSubscription]struct{}
+}
+
+// newRescanNtfnSubscribers returns a new set of rescan notification subscribers.
+func newRescanNtfnSubscribers() *rescanNtfnSubscribers {
+	return &rescanNtfnSubscribers{
+		subscribers: make(map[*rescanSubscription]struct{}),
+	}
+}
+
+// subscribe returns a subscription on which rescan notifications are delivered
+// for a rescan of the passed addresses.  Notifications are dropped when the
+// channel buffer of the subscription is full.
+//
+// This function is safe for concurrent access.
+func (s *rescanNtfnSubscribers) subscribe(addresses []string) *rescanSubscription {
+	sub := &rescanSubscription{
+		ntfns:     make(chan rescanNtfn, rescanProgressBufferSize),
+		addresses: make(map[string]struct{}, len(addresses)),
+		found:     make(map[btcjson.OutPoint]struct{}),
+	}
+	for _, addr := range addresses {
+		sub.addresses[addr] = struct{}{}
+	}
+	s.Lock()
+	s.subscribers[sub] = struct{}{}
+	s.Unlock()
+	return sub
+}
+
+// unsubscribe stops delivering notifications to the passed subscription, which
+// must have been returned by a previous call to subscribe.
+//
+// This function is safe for concurrent access.
+func (s *rescanNtfnSubscribers) unsubscribe(sub *rescanSubscription) {
+	s.Lock()
+	delete(s.subscribers, sub)
+	s.Unlock()
+}
+
+// active returns whether or not there are any subscriptions.
+//
+// This function is safe for concurrent access.
+func (s *rescanNtfnSubscribers) active() bool {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.subscribers) > 0
+}
+
+// notify delivers a rescan notification to all subscribers without blocking.
+//
+// This function is safe for concurrent access.
+func (s *rescanNtfnSubscribers) notify(height int32, finished bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	for sub := range s.subscribers {
+		sub.deliver(rescanNtfn{height: height, finished: finished})
+	}
+}
+
+// deliver queues the passed notification without blocking.  Progress
+// notifications are dropped when the channel buffer is full, while finished
+// ones replace the oldest queued notification since rescans wait for them.
+func (sub *rescanSubscription) deliver(ntfn rescanNtfn) {
+	for {
+		select {
+		case sub.ntfns <- ntfn:
+			return
+		default:
+		}
+		if !ntfn.finished {
+			return
+		}
+
+		select {
+		case <-sub.ntfns:
+		default:
+		}
+	}
+}
+
+// recvTx adds the outputs of the passed transaction which pay to the addresses
+// of a subscription to the outpoints it found.  Transactions which are not
+// mined, as described by the passed block details, are ignored since they are
+// not the result of a rescan.
+//
+// This function is safe for concurrent access.
+func (s *rescanNtfnSubscribers) recvTx(tx *btcutil.Tx,
+	block *btcjson.BlockDetails, params *chaincfg.Params) {
+
+	s.Lock()
+	defer s.Unlock()
+
+	if block == nil || len(s.subscribers) == 0 {
+		return
+	}
+	for i, txOut := range tx.MsgTx().TxOut {
+		// Outputs with non-standard scripts can not pay to an address.
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(txOut.PkScript,
+			params)
+		if err != nil {
+			continue
+		}
+		op := newOutPointFromWire(wire.NewOutPoint(tx.Sha(), uint32(i)))
+		for sub := range s.subscribers {
+			for _, addr := range addrs {
+				_, ok := sub.addresses[addr.EncodeAddress()]
+				if !ok {
+					_, ok = sub.addresses[addr.String()]
+				}
+				if ok {
+					sub.found[op] = struct{}{}
+					break
+				}
+			}
+		}
+	}
+}
+
+// foundOutPoints returns the outpoints the passed subscription found so far.
+//
+// This function is safe for concurrent access.
+func (s *rescanNtfnSubscribers) foundOutPoints(sub *rescanSubscription) []btcjson.OutPoint {
+	s.Lock()
+	defer s.Unlock()
+	return sortedOutPoints(sub.found)
+}
+
+// RescanProgress describes the progress of a rescan started with
+// RescanRangeAsync.
+type RescanProgress struct {
+	StartHeight int32
+	EndHeight   int32
+
+	// Height is the height of the last block which has been rescanned.
+	// It is one less than StartHeight until the first progress is
+	// reported.
+	Height int32
+
+	// Elapsed is the time since the rescan started and ETA is the
+	// estimated time until it finishes based on the rate of progress so
+	// far.  ETA is zero until there has been some progress.
+	Elapsed time.Duration
+	ETA     time.Duration
+
+	// Done is set on the final update, which also carries the error which
+	// stopped the rescan, if any.
+	Done bool
+	Err  error
+}
+
+// FutureRescanRangeResult is a future promise to deliver the result of a
+// RescanRangeAsync invocation (or an applicable error).  Unlike the futures of
+// single RPC invocations, it also provides a channel of progress updates.
+type FutureRescanRangeResult struct {
+	progress chan *RescanProgress
+	result   chan *response
+	quit     chan struct{}
+	quitOnce sync.Once
+}
+
+// Progress returns a channel on which the progress of the rescan is delivered.
+// The channel is closed once the rescan completes, right after delivering a
+// final update with Done set.  Updates are dropped when the channel buffer is
+// full, so callers which are not interested in them do not need to drain it.
+func (r *FutureRescanRangeResult) Progress() <-chan *RescanProgress {
+	return r.progress
+}
+
+// Cancel stops the rescan once the current chunk finishes.  Receive returns
+// ErrRescanCanceled unless the rescan already completed.
+func (r *FutureRescanRangeResult) Cancel() {
+	r.quitOnce.Do(func() { close(r.quit) })
+}
+
+// Receive waits for the rescan to finish and returns an error if it was not
+// successful.
+func (r *FutureRescanRangeResult) Receive() error {
+	_, err := receiveFuture(r.result)
+	return err
+}
+
+// waitForReconnect blocks until the client is connected again.  It returns
+// false if the client is shutdown or the passed quit channel is closed first.
+func (c *Client) waitForReconnect(quit chan struct{}) bool {
+	for c.Disconnected() {
+		select {
+		case <-time.After(connectionRetryInterval):
+		case <-quit:
+			return false
+		case <-c.shutdown:
+			return false
+		}
+	}
+	return true
+}
+
+// rescanRange is the main loop of a rescan started with RescanRangeAsync.  It
+// must be run as a goroutine.
+func (c *Client) rescanRange(startHeight, endHeight, chunkSize int32,
+	addresses []string, outpoints []btcjson.OutPoint,
+	f *FutureRescanRangeResult) {
+
+	defer close(f.progress)
+
+	sub := c.rescanNtfns.subscribe(addresses)
+	defer c.rescanNtfns.unsubscribe(sub)
+
+	started := time.Now()
+	height := startHeight - 1
+	sendProgress := func(err error, done bool) {
+		progress := &RescanProgress{
+			StartHeight: startHeight,
+			EndHeight:   endHeight,
+			Height:      height,
+			Elapsed:     time.Since(started),
+			Done:        done,
+			Err:         err,
+		}
+		if scanned := height - startHeight + 1; scanned > 0 && !done {
+			remaining := endHeight - height
+			progress.ETA = progress.Elapsed * time.Duration(remaining) /
+				time.Duration(scanned)
+		}
+		select {
+		case f.progress <- progress:
+		default:
+			log.Debugf("Dropping rescan progress at height %d",
+				height)
+		}
+	}
+	finish := func(err error) {
+		sendProgress(err, true)
+		f.result <- &response{err: err}
+	}
+
+	for height < endHeight {
+		chunkStart := height + 1
+		chunkEnd := chunkStart + chunkSize - 1
+		if chunkEnd > endHeight {
+			chunkEnd = endHeight
+		}
+
+		startHash, err := c.GetBlockHash(int64(chunkStart))
+		if err != nil {
+			finish(err)
+			return
+		}
+		endHash, err := c.GetBlockHash(int64(chunkEnd))
+		if err != nil {
+			finish(err)
+			return
+		}
+
+		// The server only watches the outputs paying to the addresses
+		// for spends during the rescan request which found them, so
+		// pass those found by the previous chunks along with the
+		// original outpoints.
+		chunkOutPoints := outpoints
+		if found := c.rescanNtfns.foundOutPoints(sub); len(found) > 0 {
+			chunkOutPoints = make([]btcjson.OutPoint, 0,
+				len(outpoints)+len(found))
+			chunkOutPoints = append(chunkOutPoints, outpoints...)
+			chunkOutPoints = append(chunkOutPoints, found...)
+		}
+
+		log.Debugf("Rescanning blocks %d through %d", chunkStart,
+			chunkEnd)
+		result := c.rescanInternal(startHash.String(), addresses,
+			chunkOutPoints, endHash.String())
+
+		// Track the progress notifications for this chunk until the
+		// rescan request completes and the rescanfinished notification
+		// for the chunk is received.  The server does not guarantee the
+		// notifications of the rescan are delivered before the reply,
+		// but they are delivered before the rescanfinished notification,
+		// so waiting for it ensures the outpoints found by the chunk
+		// are known before starting the next one.  Notifications for
+		// heights outside of the chunk belong to other rescans and are
+		// ignored.
+		var rescanErr error
+		var replied, finished bool
+		disconnect := c.disconnectChan()
+	chunk:
+		for {
+			select {
+			case ntfn := <-sub.ntfns:
+				if ntfn.height < chunkStart || ntfn.height > chunkEnd {
+					continue
+				}
+				if ntfn.height > height {
+					height = ntfn.height
+					sendProgress(nil, false)
+				}
+				if ntfn.finished && ntfn.height == chunkEnd {
+					finished = true
+					if replied {
+						break chunk
+					}
+				}
+
+			case r := <-result:
+				rescanErr = r.err
+				replied = true
+				if rescanErr != nil || finished {
+					break chunk
+				}
+				disconnect = c.disconnectChan()
+
+			case <-disconnect:
+				// The rescanfinished notification is lost when
+				// the client disconnects after the reply.  The
+				// reply itself is delivered once reconnected.
+				if replied {
+					rescanErr = ErrClientDisconnect
+					break chunk
+				}
+				disconnect = nil
+			}
+		}
+
+		switch {
+		case rescanErr == ErrClientDisconnect:
+			// Resume from the last reported progress once the
+			// client has reconnected.
+			log.Infof("Rescan interrupted at height %d, resuming "+
+				"on reconnect", height)
+			if !c.waitForReconnect(f.quit) {
+				select {
+				case <-f.quit:
+					finish(ErrRescanCanceled)
+				default:
+					finish(ErrClientShutdown)
+				}
+				return
+			}
+			continue
+
+		case rescanErr != nil:
+			finish(rescanErr)
+			return
+		}
+
+		select {
+		case <-f.quit:
+			finish(ErrRescanCanceled)
+			return
+		case <-c.shutdown:
+			finish(ErrClientShutdown)
+			return
+		default:
+		}
+	}
+
+	finish(nil)
+}
+
+// RescanRangeAsync returns an instance of a type that can be used to get the
+// result of rescanning a range of blocks at some future time by invoking the
+// Receive function on the returned instance.
+//
+// See RescanRange for the blocking version and more details.
+func (c *Client) RescanRangeAsync(startHeight, endHeight int32,
+	addresses []btcutil.Address, outpoints []*wire.OutPoint,
+	chunkSize int32) *FutureRescanRangeResult {
+
+	f := &FutureRescanRangeResult{
+		progress: make(chan *RescanProgress, rescanProgressBufferSize),
+		result:   make(chan *response, 1),
+		quit:     make(chan struct{}),
+	}
+
+	// Not supported in HTTP POST mode.
+	if c.config.HTTPPostMode {
+		close(f.progress)
+		f.result <- &response{err: ErrNotificationsNotSupported}
+		return f
+	}
+
+	// Ignore the rescan if the client is not interested in notifications.
+	if c.ntfnHandlers == nil {
+		close(f.progress)
+		f.result <- &response{}
+		return f
+	}
+
+	if chunkSize <= 0 {
+		chunkSize = defaultRescanChunkSize
+	}
+	addrs := make([]string, 0, len(addresses))
+	for _, addr := range addresses {
+		addrs = append(addrs, addr.String())
+	}
+	ops := make([]btcjson.OutPoint, 0, len(outpoints))
+	for _, op := range outpoints {
+		ops = append(ops, newOutPointFromWire(op))
+	}
+	c.trackWatchedFilter(addrs, ops)
+
+	go func() {
+		// Rescan through the current best block when no end height is
+		// specified.
+		if endHeight < 0 {
+			blockCount, err := c.GetBlockCount()
+			if err != nil {
+				close(f.progress)
+				f.result <- &response{err: err}
+				return
+			}
+			endHeight = int32(blockCount)
+		}
+		c.rescanRange(startHeight, endHeight, chunkSize, addrs, ops, f)
+	}()
+	return f
+}
+
+// RescanRange rescans the blocks from startHeight through endHeight, or through
+// the current best block when endHeight is negative, for transactions paying to
+// the passed addresses and spending the passed outpoints.  Unlike Rescan, the
+// range is split into separate rescan requests of chunkSize blocks each, or
+// 10000 blocks when chunkSize is not positive, and a rescan interrupted by a
+// disconnect automatically resumes from the last reported progress once the
+// client has reconnected.  Calling this function has no effect if there are no
+// notification handlers and will result in an error if the client is
+// configured to run in HTTP POST mode.
+//
+// The notifications delivered as a result of this call are the same as those of
+// Rescan, with OnRescanFinished invoked once for each chunk.  The outputs paying
+// to the passed addresses which are found by a chunk are passed to the later
+// chunks, so spends of them are found just like by a single rescan.  Progress is
+// tracked from the OnRescanProgress and OnRescanFinished notifications, so
+// rescans of overlapping ranges should not be run concurrently.
+//
+// See RescanRangeAsync to also receive progress updates, including an estimate
+// of the remaining time, and to cancel the rescan.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func (c *Client) RescanRange(startHeight, endHeight int32,
+	addresses []btcutil.Address, outpoints []*wire.OutPoint,
+	chunkSize int32) error {
+
+	return c.RescanRangeAsync(startHeight, endHeight, addresses, outpoints,
+		chunkSize).Receive()
+}
@@ -0,0 +1,343 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/txscript"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// StakeOutput describes the output of a coinstake transaction paying the
+// staker of a proof-of-stake block.
+type StakeOutput struct {
+	OutPoint wire.OutPoint
+	Amount   btcutil.Amount
+
+	// Address is the address the output pays to.  It is nil when the
+	// output script is non-standard.
+	Address btcutil.Address
+}
+
+// StakeBlockNtfn houses the details of a block connected to the longest (best)
+// chain as delivered to the OnStakeBlockConnected notification handler.
+type StakeBlockNtfn struct {
+	Hash   *wire.ShaHash
+	Height int32
+	Time   time.Time
+
+	// ProofOfStake is set when the block is a proof-of-stake block, in
+	// which case CoinStake is its coinstake transaction.  Otherwise the
+	// block is a proof-of-work block and CoinStake is nil.
+	ProofOfStake bool
+	CoinBase     *btcutil.Tx
+	CoinStake    *btcutil.Tx
+
+	// StakeInputs are the outpoints staked by the coinstake transaction
+	// and StakerOutputs are the outputs paying the staker.  Both are empty
+	// for proof-of-work blocks.
+	StakeInputs   []wire.OutPoint
+	StakerOutputs []StakeOutput
+
+	// StakeModifier is the stake modifier of the block as reported by the
+	// getblock RPC, and GeneratedStakeModifier is set when the block
+	// generated a new stake modifier instead of carrying over the one of
+	// its parent.
+	StakeModifier          uint64
+	GeneratedStakeModifier bool
+
+	// Minted is the amount created by the block.  For proof-of-work blocks
+	// it is the value of the coinbase outputs, since Peercoin destroys
+	// transaction fees instead of paying them to the miner.  For
+	// proof-of-stake blocks it is the value of the coinstake outputs minus
+	// the value of the staked inputs.  MintedErr is set, and Minted is
+	// zero, when the value of a staked input could not be looked up.
+	Minted    btcutil.Amount
+	MintedErr error
+}
+
+// stakeBlockVerboseResult models the data from the getblock command when the
+// verbose flag is set, including the Peercoin specific fields describing the
+// stake modifier of the block.
+type stakeBlockVerboseResult struct {
+	btcjson.GetBlockVerboseResult
+	Flags            string `json:"flags"`
+	Modifier         string `json:"modifier"`
+	ModifierChecksum string `json:"modifierchecksum"`
+}
+
+// isCoinStake returns whether or not the passed transaction is a coinstake
+// transaction, which spends at least one output and has an empty first output.
+func isCoinStake(msgTx *wire.MsgTx) bool {
+	if len(msgTx.TxIn) == 0 || len(msgTx.TxOut) < 2 {
+		return false
+	}
+	prevOut := &msgTx.TxIn[0].PreviousOutPoint
+	if prevOut.Index == wire.MaxPrevOutIndex &&
+		prevOut.Hash.IsEqual(&wire.ShaHash{}) {
+
+		return false
+	}
+	firstOut := msgTx.TxOut[0]
+	return firstOut.Value == 0 && len(firstOut.PkScript) == 0
+}
+
+// newStakeBlockNtfn fetches the block with the passed hash and returns its
+// proof-of-stake details.  Only a failure to fetch the block itself is
+// returned as an error.  The details which could not be determined, such as
+// the minted amount when a staked input can not be looked up, are recorded in
+// the returned notification instead.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called from
+// the input reader goroutine.
+func (c *Client) newStakeBlockNtfn(hash *wire.ShaHash) (*StakeBlockNtfn, error) {
+	res, err := receiveFuture(c.GetBlockVerboseAsync(hash, true))
+	if err != nil {
+		return nil, err
+	}
+	var block stakeBlockVerboseResult
+	if err := json.Unmarshal(res, &block); err != nil {
+		return nil, err
+	}
+	ntfn, err := c.stakeBlockDetails(hash, &block.GetBlockVerboseResult)
+	if err != nil {
+		return nil, err
+	}
+
+	ntfn.StakeModifier, err = strconv.ParseUint(block.Modifier, 16, 64)
+	if err != nil {
+		log.Warnf("Block %v has an invalid stake modifier %q: %v", hash,
+			block.Modifier, err)
+	}
+	for _, flag := range strings.Fields(block.Flags) {
+		if flag == "stake-modifier" {
+			ntfn.GeneratedStakeModifier = true
+		}
+	}
+	return ntfn, nil
+}
+
+// stakeBlockDetails returns the proof-of-stake details of the passed block,
+// which must have been fetched with its transactions, apart from its stake
+// modifier.  The values of the staked inputs are looked up with
+// GetRawTransaction unless they are known to the client already.  A failed
+// lookup is recorded as the MintedErr of the returned details.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called from
+// the input reader goroutine.
+func (c *Client) stakeBlockDetails(hash *wire.ShaHash,
+	block *btcjson.GetBlockVerboseResult) (*StakeBlockNtfn, error) {
+
+	var err error
+	if len(block.RawTx) == 0 {
+		return nil, fmt.Errorf("block %v has no transactions", hash)
+	}
+
+	ntfn := &StakeBlockNtfn{
+		Hash:   hash,
+		Height: int32(block.Height),
+		Time:   time.Unix(block.Time, 0),
+	}
+	ntfn.CoinBase, err = parseHexTx(block.RawTx[0].Hex)
+	if err != nil {
+		return nil, err
+	}
+	if len(block.RawTx) > 1 {
+		tx, err := parseHexTx(block.RawTx[1].Hex)
+		if err != nil {
+			return nil, err
+		}
+		if isCoinStake(tx.MsgTx()) {
+			ntfn.ProofOfStake = true
+			ntfn.CoinStake = tx
+		}
+	}
+
+	if !ntfn.ProofOfStake {
+		for _, txOut := range ntfn.CoinBase.MsgTx().TxOut {
+			ntfn.Minted += btcutil.Amount(txOut.Value)
+		}
+		return ntfn, nil
+	}
+
+	coinStake := ntfn.CoinStake.MsgTx()
+	for _, txIn := range coinStake.TxIn {
+		prevOut := txIn.PreviousOutPoint
+		ntfn.StakeInputs = append(ntfn.StakeInputs, prevOut)
+
+		if ntfn.MintedErr != nil {
+			continue
+		}
+		value, ok := c.txOutValues.lookup(&prevOut)
+		if !ok {
+			prevTx, err := c.GetRawTransaction(&prevOut.Hash)
+			switch {
+			case err != nil:
+				ntfn.MintedErr = err
+			case int(prevOut.Index) >= len(prevTx.MsgTx().TxOut):
+				ntfn.MintedErr = fmt.Errorf("transaction %v has no "+
+					"output %d", prevOut.Hash, prevOut.Index)
+			default:
+				txOut := prevTx.MsgTx().TxOut[prevOut.Index]
+				value = btcutil.Amount(txOut.Value)
+			}
+			if ntfn.MintedErr != nil {
+				ntfn.Minted = 0
+				continue
+			}
+		}
+		ntfn.Minted -= value
+	}
+
+	params := c.chainParams()
+	for i, txOut := range coinStake.TxOut {
+		if ntfn.MintedErr == nil {
+			ntfn.Minted += btcutil.Amount(txOut.Value)
+		}
+
+		// The first output of a coinstake transaction is always empty.
+		if i == 0 {
+			continue
+		}
+		output := StakeOutput{
+			OutPoint: wire.OutPoint{Hash: *ntfn.CoinStake.Sha(),
+				Index: uint32(i)},
+			Amount: btcutil.Amount(txOut.Value),
+		}
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(txOut.PkScript,
+			params)
+		if err == nil && len(addrs) > 0 {
+			output.Address = addrs[0]
+		}
+		ntfn.StakerOutputs = append(ntfn.StakerOutputs, output)
+	}
+
+	return ntfn, nil
+}
+
+// stakeBlockNotifier delivers the OnStakeBlockConnected notifications.  Since
+// the details of each block must be fetched from the server, the block
+// connected notifications are queued and processed by a separate goroutine.
+type stakeBlockNotifier struct {
+	client *Client
+	queue  *ntfnQueue
+}
+
+// Enforce stakeBlockNotifier satisfies the ntfnObserver interface.
+var _ ntfnObserver = (*stakeBlockNotifier)(nil)
+
+// startStakeBlockNotifier registers a notifier for the OnStakeBlockConnected
+// notification handler with the client and starts its goroutine.
+func (c *Client) startStakeBlockNotifier() {
+	n := &stakeBlockNotifier{
+		client: c,
+		queue:  newNtfnQueue(),
+	}
+	c.ntfnObservers.add(n)
+	c.wg.Add(1)
+	go n.handler()
+}
+
+// observeNotification queues the block connected notifications for processing
+// by the notifier goroutine.  It is part of the ntfnObserver interface
+// implementation.
+func (n *stakeBlockNotifier) observeNotification(ntfn *rawNotification) {
+	if ntfn.Method == btcjson.BlockConnectedNtfnMethod {
+		n.queue.push(ntfn)
+	}
+}
+
+// handler fetches the details of the queued blocks and invokes the
+// OnStakeBlockConnected notification handler with them in the order the
+// blocks were connected.  When notifications were dropped because the handler
+// fell too far behind, the blocks of the main chain after the last delivered
+// block are delivered instead.  It must be run as a goroutine.
+func (n *stakeBlockNotifier) handler() {
+	c := n.client
+	lastHeight := int32(-1)
+out:
+	for {
+		select {
+		case <-n.queue.signal:
+			ntfns, dropped := n.queue.popAll()
+			caughtUp := int32(-1)
+			if dropped > 0 {
+				log.Warnf("Stake block notifier dropped %d "+
+					"notifications, catching up from "+
+					"height %d", dropped, lastHeight)
+				lastHeight = n.catchUp(lastHeight)
+				caughtUp = lastHeight
+			}
+			for _, ntfn := range ntfns {
+				hash, height, _, err := parseChainNtfnParams(ntfn.Params)
+				if err != nil {
+					log.Warnf("Received invalid block "+
+						"connected notification: %v", err)
+					continue
+				}
+
+				// Blocks delivered while catching up are not
+				// delivered again.
+				if height <= caughtUp {
+					continue
+				}
+				n.deliver(hash)
+				lastHeight = height
+			}
+
+		case <-c.shutdown:
+			break out
+		}
+	}
+	c.ntfnObservers.remove(n)
+	c.wg.Done()
+}
+
+// deliver fetches the details of the block with the passed hash and invokes the
+// OnStakeBlockConnected notification handler with them.
+func (n *stakeBlockNotifier) deliver(hash *wire.ShaHash) {
+	c := n.client
+	details, err := c.newStakeBlockNtfn(hash)
+	if err != nil {
+		log.Warnf("Unable to fetch details of block %v: %v", hash, err)
+		return
+	}
+	c.ntfnHandlers.OnStakeBlockConnected(details)
+}
+
+// catchUp delivers the blocks of the main chain after the passed height of the
+// last delivered block through the current best block, and returns the height
+// of the last block it delivered.  Nothing is delivered when no block has been
+// delivered yet since the height to start from is not known.
+func (n *stakeBlockNotifier) catchUp(height int32) int32 {
+	if height < 0 {
+		return height
+	}
+
+	c := n.client
+	bestHeight, err := c.GetBlockCount()
+	if err != nil {
+		log.Warnf("Unable to fetch best block height: %v", err)
+		return height
+	}
+	for int64(height) < bestHeight {
+		hash, err := c.GetBlockHash(int64(height) + 1)
+		if err != nil {
+			log.Warnf("Unable to fetch block hash at height %d: %v",
+				height+1, err)
+			return height
+		}
+		n.deliver(hash)
+		height++
+	}
+	return height
+}
@@ -0,0 +1,250 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// StakeModifierCheckpoint describes the expected stake modifier of the block at
+// a known height.  Each of the expected values is not checked when it is nil.
+type StakeModifierCheckpoint struct {
+	Height int32
+
+	// Hash is the expected hash of the block.
+	Hash *wire.ShaHash
+
+	// StakeModifier is the expected kernel stake modifier of the block, as
+	// returned by GetKernelStakeModifier.
+	StakeModifier *uint64
+
+	// Checksum is the expected stake modifier checksum of the block, as
+	// reported by the getblock RPC.  Since the checksum of a block covers
+	// the checksum of its parent, it verifies the stake modifiers of all
+	// blocks up to the checkpoint, like the stake modifier checkpoints of
+	// the Peercoin reference implementation.
+	Checksum *uint32
+}
+
+// StakeModifierCheckStatus identifies the outcome of verifying a stake modifier
+// checkpoint.
+type StakeModifierCheckStatus int
+
+// Constants used to identify the kind of a StakeModifierCheckStatus.
+const (
+	// StakeModifierMatch indicates the block matches the checkpoint.
+	StakeModifierMatch StakeModifierCheckStatus = iota
+
+	// StakeModifierMismatch indicates the stake modifier of the block
+	// differs from the checkpoint.
+	StakeModifierMismatch
+
+	// StakeModifierBlockMismatch indicates the hash of the block at the
+	// checkpoint height differs from the checkpoint.
+	StakeModifierBlockMismatch
+
+	// StakeModifierNotReached indicates the best chain of the server does
+	// not reach the checkpoint height yet.
+	StakeModifierNotReached
+
+	// StakeModifierChecksumMismatch indicates the stake modifier checksum
+	// of the block differs from the checkpoint.
+	StakeModifierChecksumMismatch
+
+	// StakeModifierLookupFailed indicates the block at the checkpoint
+	// height could not be looked up, such as when it was disconnected
+	// while the checkpoints were being verified.
+	StakeModifierLookupFailed
+)
+
+// stakeModifierCheckStatusStrings is a map of stake modifier check statuses
+// back to their constant names for pretty printing.
+var stakeModifierCheckStatusStrings = map[StakeModifierCheckStatus]string{
+	StakeModifierMatch:         "StakeModifierMatch",
+	StakeModifierMismatch:      "StakeModifierMismatch",
+	StakeModifierBlockMismatch: "StakeModifierBlockMismatch",
+	StakeModifierNotReached:    "StakeModifierNotReached",
+
+	StakeModifierChecksumMismatch: "StakeModifierChecksumMismatch",
+	StakeModifierLookupFailed:     "StakeModifierLookupFailed",
+}
+
+// String returns the StakeModifierCheckStatus in human-readable form.
+func (s StakeModifierCheckStatus) String() string {
+	if str, ok := stakeModifierCheckStatusStrings[s]; ok {
+		return str
+	}
+	return fmt.Sprintf("Unknown StakeModifierCheckStatus (%d)", int(s))
+}
+
+// StakeModifierCheck houses the outcome of verifying a stake modifier
+// checkpoint against the server.
+type StakeModifierCheck struct {
+	Checkpoint StakeModifierCheckpoint
+	Status     StakeModifierCheckStatus
+
+	// Hash, StakeModifier, and Checksum are the hash, kernel stake
+	// modifier, and stake modifier checksum of the block at the checkpoint
+	// height on the server.  They are unset when the checkpoint is not
+	// reached, and the stake modifier and checksum are only looked up when
+	// the checkpoint expects them.
+	Hash          *wire.ShaHash
+	StakeModifier uint64
+	Checksum      uint32
+
+	// Err is the error of the failed lookup when the status is
+	// StakeModifierLookupFailed.
+	Err error
+}
+
+// StakeModifierVerification houses the outcome of verifying a table of stake
+// modifier checkpoints against the server.
+type StakeModifierVerification struct {
+	BestHeight int32
+	Checks     []StakeModifierCheck
+}
+
+// diverged returns whether or not the passed status indicates the block at the
+// checkpoint height differs from the checkpoint.
+func (s StakeModifierCheckStatus) diverged() bool {
+	switch s {
+	case StakeModifierMismatch, StakeModifierBlockMismatch,
+		StakeModifierChecksumMismatch:
+		return true
+	}
+	return false
+}
+
+// Diverged returns whether or not the server is on a chain which diverges from
+// any of the checkpoints, in which case it should not be trusted for minting.
+// Checkpoints which are not reached yet, or whose lookups failed, are not
+// considered.
+func (v *StakeModifierVerification) Diverged() bool {
+	return v.FirstDivergence() != nil
+}
+
+// FirstDivergence returns the first check, in the order of the checkpoints,
+// which diverges from its checkpoint, or nil when there is none.
+func (v *StakeModifierVerification) FirstDivergence() *StakeModifierCheck {
+	for i := range v.Checks {
+		if v.Checks[i].Status.diverged() {
+			return &v.Checks[i]
+		}
+	}
+	return nil
+}
+
+// Incomplete returns whether or not any of the checkpoints could not be
+// verified because its lookups failed.
+func (v *StakeModifierVerification) Incomplete() bool {
+	for i := range v.Checks {
+		if v.Checks[i].Status == StakeModifierLookupFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// stakeModifierChecksum returns the stake modifier checksum of the block with
+// the passed hash as reported by the getblock RPC.
+func (c *Client) stakeModifierChecksum(hash *wire.ShaHash) (uint32, error) {
+	res, err := receiveFuture(c.GetBlockVerboseAsync(hash, false))
+	if err != nil {
+		return 0, err
+	}
+	var block stakeBlockVerboseResult
+	if err := json.Unmarshal(res, &block); err != nil {
+		return 0, err
+	}
+	checksum, err := strconv.ParseUint(block.ModifierChecksum, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("block %v has an invalid stake modifier "+
+			"checksum %q: %v", hash, block.ModifierChecksum, err)
+	}
+	return uint32(checksum), nil
+}
+
+// checkStakeModifierCheckpoint looks up the block at the height of the passed
+// checkpoint and returns its check.  A failed lookup is recorded in the check.
+func (c *Client) checkStakeModifierCheckpoint(
+	checkpoint *StakeModifierCheckpoint) StakeModifierCheck {
+
+	check := StakeModifierCheck{Checkpoint: *checkpoint}
+	var err error
+	check.Hash, err = c.GetBlockHash(int64(checkpoint.Height))
+	if err == nil && checkpoint.StakeModifier != nil {
+		check.StakeModifier, err = c.GetKernelStakeModifier(check.Hash)
+	}
+	if err == nil && checkpoint.Checksum != nil {
+		check.Checksum, err = c.stakeModifierChecksum(check.Hash)
+	}
+	if err != nil {
+		log.Warnf("Unable to verify stake modifier checkpoint at "+
+			"height %d: %v", checkpoint.Height, err)
+		check.Status = StakeModifierLookupFailed
+		check.Err = err
+		return check
+	}
+
+	switch {
+	case checkpoint.Hash != nil && !checkpoint.Hash.IsEqual(check.Hash):
+		check.Status = StakeModifierBlockMismatch
+	case checkpoint.StakeModifier != nil &&
+		*checkpoint.StakeModifier != check.StakeModifier:
+		check.Status = StakeModifierMismatch
+	case checkpoint.Checksum != nil && *checkpoint.Checksum != check.Checksum:
+		check.Status = StakeModifierChecksumMismatch
+	default:
+		check.Status = StakeModifierMatch
+	}
+	if check.Status != StakeModifierMatch {
+		log.Warnf("Stake modifier checkpoint at height %d failed: %v "+
+			"(block %v, modifier %016x, checksum %08x)",
+			checkpoint.Height, check.Status, check.Hash,
+			check.StakeModifier, check.Checksum)
+	}
+	return check
+}
+
+// VerifyStakeModifierCheckpoints checks the blocks of the best chain of the
+// server at the heights of the passed checkpoints against them.  The returned
+// verification reports, for each checkpoint, whether the hash, kernel stake
+// modifier, and stake modifier checksum of the block match, which detects
+// servers on a chain with bad stake modifiers before they are trusted for
+// minting.  Failed lookups of the blocks, such as when a block near the tip is
+// disconnected during the verification, are reported as the status of their
+// checkpoints instead of failing the whole verification.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) VerifyStakeModifierCheckpoints(
+	checkpoints []StakeModifierCheckpoint) (*StakeModifierVerification, error) {
+
+	blockCount, err := c.GetBlockCount()
+	if err != nil {
+		return nil, err
+	}
+
+	v := &StakeModifierVerification{
+		BestHeight: int32(blockCount),
+		Checks:     make([]StakeModifierCheck, 0, len(checkpoints)),
+	}
+	for i := range checkpoints {
+		checkpoint := &checkpoints[i]
+		if checkpoint.Height > v.BestHeight {
+			v.Checks = append(v.Checks, StakeModifierCheck{
+				Checkpoint: *checkpoint,
+				Status:     StakeModifierNotReached,
+			})
+			continue
+		}
+		v.Checks = append(v.Checks, c.checkStakeModifierCheckpoint(checkpoint))
+	}
+	return v, nil
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestStakeModifierVerification ensures diverged checkpoints are reported,
+// while checkpoints which are not reached or could not be looked up are not
+// considered divergences.
+func TestStakeModifierVerification(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		statuses   []StakeModifierCheckStatus
+		diverged   bool
+		first      int // index of the first divergence, or -1
+		incomplete bool
+	}{
+		{
+			name:  "no checkpoints",
+			first: -1,
+		},
+		{
+			name: "all match",
+			statuses: []StakeModifierCheckStatus{
+				StakeModifierMatch, StakeModifierMatch,
+			},
+			first: -1,
+		},
+		{
+			name: "not reached",
+			statuses: []StakeModifierCheckStatus{
+				StakeModifierMatch, StakeModifierNotReached,
+			},
+			first: -1,
+		},
+		{
+			name: "lookup failed",
+			statuses: []StakeModifierCheckStatus{
+				StakeModifierMatch, StakeModifierLookupFailed,
+			},
+			first:      -1,
+			incomplete: true,
+		},
+		{
+			name: "modifier mismatch",
+			statuses: []StakeModifierCheckStatus{
+				StakeModifierMatch, StakeModifierMismatch,
+			},
+			diverged: true,
+			first:    1,
+		},
+		{
+			name: "checksum mismatch after lookup failure",
+			statuses: []StakeModifierCheckStatus{
+				StakeModifierLookupFailed,
+				StakeModifierChecksumMismatch,
+				StakeModifierBlockMismatch,
+			},
+			diverged:   true,
+			first:      1,
+			incomplete: true,
+		},
+	}
+
+	for _, test := range tests {
+		v := &StakeModifierVerification{}
+		for i, status := range test.statuses {
+			check := StakeModifierCheck{Status: status}
+			check.Checkpoint.Height = int32(i)
+			if status == StakeModifierLookupFailed {
+				check.Err = errors.New("lookup failed")
+			}
+			v.Checks = append(v.Checks, check)
+		}
+
+		if got := v.Diverged(); got != test.diverged {
+			t.Errorf("%s: Diverged: got %v, want %v", test.name, got,
+				test.diverged)
+		}
+		first := v.FirstDivergence()
+		switch {
+		case test.first == -1 && first != nil:
+			t.Errorf("%s: FirstDivergence: got height %d, want nil",
+				test.name, first.Checkpoint.Height)
+		case test.first != -1 && first == nil:
+			t.Errorf("%s: FirstDivergence: got nil, want height %d",
+				test.name, test.first)
+		case first != nil && first.Checkpoint.Height != int32(test.first):
+			t.Errorf("%s: FirstDivergence: got height %d, want %d",
+				test.name, first.Checkpoint.Height, test.first)
+		}
+		if got := v.Incomplete(); got != test.incomplete {
+			t.Errorf("%s: Incomplete: got %v, want %v", test.name,
+				got, test.incomplete)
+		}
+	}
+}
+
+// TestStakeModifierCheckStatusStringer ensures the statuses print their
+// constant names.
+func TestStakeModifierCheckStatusStringer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   StakeModifierCheckStatus
+		want string
+	}{
+		{StakeModifierMatch, "StakeModifierMatch"},
+		{StakeModifierMismatch, "StakeModifierMismatch"},
+		{StakeModifierBlockMismatch, "StakeModifierBlockMismatch"},
+		{StakeModifierNotReached, "StakeModifierNotReached"},
+		{StakeModifierChecksumMismatch, "StakeModifierChecksumMismatch"},
+		{StakeModifierLookupFailed, "StakeModifierLookupFailed"},
+		{0xff, "Unknown StakeModifierCheckStatus (255)"},
+	}
+
+	for _, test := range tests {
+		if got := test.in.String(); got != test.want {
+			t.Errorf("String: got %q, want %q", got, test.want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// stakeForecastStep is the interval the weight of an output is assumed to be
+// constant over when forecasting.  Kernel hashes are still evaluated once per
+// second.
+const stakeForecastStep = 10 * time.Minute
+
+// hashSpace is the number of possible kernel hashes.
+var hashSpace = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// StakeForecastOutput houses the staking forecast of an output.
+type StakeForecastOutput struct {
+	OutPoint wire.OutPoint
+	Amount   btcutil.Amount
+
+	// CoinAge is the age of the output in coin-days at the start of the
+	// forecast.  It is zero while the output is younger than the minimum
+	// stake age.
+	CoinAge int64
+
+	// EligibleTime is the earliest time the output may stake and
+	// MaxWeightTime is the time its weight stops increasing.
+	EligibleTime  time.Time
+	MaxWeightTime time.Time
+
+	// Probability is the probability of the output minting a block during
+	// the forecast.
+	Probability float64
+}
+
+// StakeForecast houses the staking forecast of a set of outputs over a period
+// of time at a constant difficulty.
+type StakeForecast struct {
+	Start      time.Time
+	Horizon    time.Duration
+	Bits       uint32
+	Difficulty float64
+
+	// Outputs are the forecasts of the outputs, ordered by the time they
+	// become eligible to stake.
+	Outputs []StakeForecastOutput
+
+	// Probability is the probability of at least one of the outputs
+	// minting a block during the forecast.
+	Probability float64
+}
+
+// secondProbability returns the probability of the kernel hash of the passed
+// kernel meeting the target per coin-day with the passed compact
+// representation in a single second at the passed time.
+func secondProbability(kernel *StakeKernel, bits uint32, t time.Time,
+	params *StakeKernelParams) float64 {
+
+	if !kernel.Eligible(t, params) {
+		return 0
+	}
+	target := kernel.Target(bits, t, params)
+	if target.Sign() <= 0 {
+		return 0
+	}
+	if target.Cmp(hashSpace) >= 0 {
+		return 1
+	}
+	p, _ := new(big.Rat).SetFrac(target, hashSpace).Float64()
+	return p
+}
+
+// forecastKernel returns the forecast of the passed kernel from the passed
+// start time over the passed horizon.
+func forecastKernel(kernel *StakeKernel, bits uint32, start time.Time,
+	horizon time.Duration, params *StakeKernelParams) StakeForecastOutput {
+
+	output := StakeForecastOutput{
+		OutPoint:      kernel.OutPoint,
+		Amount:        kernel.Amount,
+		CoinAge:       kernel.CoinAge(start, params),
+		EligibleTime:  kernel.EligibleTime(params),
+		MaxWeightTime: kernel.TxPrevTime.Add(params.StakeMaxAge),
+	}
+
+	// The probability of not minting is the product of the probabilities
+	// of not minting each second, which is summed in log space for
+	// precision.
+	end := start.Add(horizon)
+	var logMiss float64
+	for t := start; t.Before(end); t = t.Add(stakeForecastStep) {
+		step := stakeForecastStep
+		if remaining := end.Sub(t); remaining < step {
+			step = remaining
+		}
+		p := secondProbability(kernel, bits, t, params)
+		if p >= 1 {
+			output.Probability = 1
+			return output
+		}
+		logMiss += math.Log1p(-p) * step.Seconds()
+	}
+	output.Probability = -math.Expm1(logMiss)
+	return output
+}
+
+// ForecastStakeKernels returns the staking forecast of the passed kernels from
+// the passed start time over the passed horizon, assuming the target per
+// coin-day with the passed compact representation stays constant.
+func ForecastStakeKernels(kernels []*StakeKernel, bits uint32, start time.Time,
+	horizon time.Duration, params *StakeKernelParams) *StakeForecast {
+
+	forecast := &StakeForecast{
+		Start:      start,
+		Horizon:    horizon,
+		Bits:       bits,
+		Difficulty: CompactToDifficulty(bits),
+		Outputs:    make([]StakeForecastOutput, 0, len(kernels)),
+	}
+	miss := 1.0
+	for _, kernel := range kernels {
+		output := forecastKernel(kernel, bits, start, horizon, params)
+		forecast.Outputs = append(forecast.Outputs, output)
+		miss *= 1 - output.Probability
+	}
+	forecast.Probability = 1 - miss
+
+	sort.Sort(stakeForecastSorter(forecast.Outputs))
+	return forecast
+}
+
+// stakeForecastSorter implements sort.Interface to allow a slice of output
+// forecasts to be sorted by the time the outputs become eligible to stake.
+type stakeForecastSorter []StakeForecastOutput
+
+// Len returns the number of output forecasts in the slice.  It is part of the
+// sort.Interface implementation.
+func (s stakeForecastSorter) Len() int {
+	return len(s)
+}
+
+// Swap swaps the output forecasts at the passed indices.  It is part of the
+// sort.Interface implementation.
+func (s stakeForecastSorter) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// Less returns whether the output forecast with index i should sort before the
+// output forecast with index j.  It is part of the sort.Interface
+// implementation.
+func (s stakeForecastSorter) Less(i, j int) bool {
+	return s[i].EligibleTime.Before(s[j].EligibleTime)
+}
+
+// ForecastStakes returns the staking forecast of the confirmed unspent outputs
+// of the wallet from now over the passed horizon at the current difficulty of
+// proof-of-stake blocks, using the main network parameters when params is nil.
+// The forecast is computed from RPC data only.  It can be used to plan when
+// outputs become eligible to stake and whether splitting outputs which reach
+// the maximum stake age, or merging small outputs, would increase the chances
+// of minting.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) ForecastStakes(horizon time.Duration,
+	params *StakeKernelParams) (*StakeForecast, error) {
+
+	if params == nil {
+		params = &MainNetStakeKernelParams
+	}
+	bits, err := c.GetNextRequiredTarget(true)
+	if err != nil {
+		return nil, err
+	}
+	kernels, err := c.walletStakeKernels()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Unix(time.Now().Unix(), 0)
+	return ForecastStakeKernels(kernels, bits, start, horizon, params), nil
+}
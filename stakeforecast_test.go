@@ -0,0 +1,236 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// testForecastBits is the compact representation of a target per coin-day of
+// 2^216, so each coin-day of weight has a 2^-40 chance of meeting the target
+// every second.
+const testForecastBits = 0x1c010000
+
+// mintProbability returns the probability of an output with the passed weight
+// minting within the passed number of seconds at testForecastBits.
+func mintProbability(weight int64, seconds int64) float64 {
+	p := float64(weight) / (1 << 40)
+	return -math.Expm1(float64(seconds) * math.Log1p(-p))
+}
+
+// closeProbability returns whether or not the passed probabilities are equal
+// within a relative error of 1e-9.
+func closeProbability(got, want float64) bool {
+	if want == 0 {
+		return got == 0
+	}
+	return math.Abs(got-want) <= 1e-9*want
+}
+
+// newForecastKernel returns a kernel for the passed amount created by a
+// transaction with the passed timestamp in a block with the passed timestamp.
+func newForecastKernel(index uint32, amount btcutil.Amount, txPrevTime,
+	blockFromTime time.Time) *StakeKernel {
+
+	return &StakeKernel{
+		OutPoint:      *wire.NewOutPoint(&wire.ShaHash{1}, index),
+		Amount:        amount,
+		BlockFromTime: blockFromTime,
+		TxPrevTime:    txPrevTime,
+	}
+}
+
+// TestForecastKernel ensures the forecast of an output has the expected coin
+// age, eligibility and maximum weight times, and probability of minting.
+func TestForecastKernel(t *testing.T) {
+	t.Parallel()
+
+	day := 24 * time.Hour
+	params := &MainNetStakeKernelParams
+	start := time.Unix(1400000000, 0)
+	tests := []struct {
+		name          string
+		amount        btcutil.Amount
+		txPrevAge     time.Duration
+		blockFromAge  time.Duration
+		bits          uint32
+		horizon       time.Duration
+		coinAge       int64
+		eligibleTime  time.Time
+		maxWeightTime time.Time
+		probability   float64
+	}{
+		{
+			// The weight of 100 coins is capped at 60 coin-days
+			// each.
+			name:          "max-age capped",
+			amount:        100 * peercoinCoin,
+			txPrevAge:     200 * day,
+			blockFromAge:  200 * day,
+			bits:          testForecastBits,
+			horizon:       30 * day,
+			coinAge:       20000,
+			eligibleTime:  start.Add(-170 * day),
+			maxWeightTime: start.Add(-110 * day),
+			probability:   mintProbability(6000, 30*86400),
+		},
+		{
+			name:          "beyond max age",
+			amount:        100 * peercoinCoin,
+			txPrevAge:     300 * day,
+			blockFromAge:  300 * day,
+			bits:          testForecastBits,
+			horizon:       30 * day,
+			coinAge:       30000,
+			eligibleTime:  start.Add(-270 * day),
+			maxWeightTime: start.Add(-210 * day),
+			probability:   mintProbability(6000, 30*86400),
+		},
+		{
+			name:          "partial step",
+			amount:        100 * peercoinCoin,
+			txPrevAge:     200 * day,
+			blockFromAge:  200 * day,
+			bits:          testForecastBits,
+			horizon:       25 * time.Minute,
+			coinAge:       20000,
+			eligibleTime:  start.Add(-170 * day),
+			maxWeightTime: start.Add(-110 * day),
+			probability:   mintProbability(6000, 25*60),
+		},
+		{
+			name:          "not yet eligible",
+			amount:        100 * peercoinCoin,
+			txPrevAge:     10 * day,
+			blockFromAge:  10 * day,
+			bits:          testForecastBits,
+			horizon:       day,
+			coinAge:       0,
+			eligibleTime:  start.Add(20 * day),
+			maxWeightTime: start.Add(80 * day),
+			probability:   0,
+		},
+		{
+			// The output only stakes during the second day, once
+			// its block is old enough, with its weight already
+			// capped since it was created long before.
+			name:          "eligible during forecast",
+			amount:        100 * peercoinCoin,
+			txPrevAge:     200 * day,
+			blockFromAge:  29 * day,
+			bits:          testForecastBits,
+			horizon:       2 * day,
+			coinAge:       0,
+			eligibleTime:  start.Add(day),
+			maxWeightTime: start.Add(-110 * day),
+			probability:   mintProbability(6000, 86400),
+		},
+		{
+			name:          "target beyond hash space",
+			amount:        100 * peercoinCoin,
+			txPrevAge:     200 * day,
+			blockFromAge:  200 * day,
+			bits:          0x217fffff,
+			horizon:       time.Minute,
+			coinAge:       20000,
+			eligibleTime:  start.Add(-170 * day),
+			maxWeightTime: start.Add(-110 * day),
+			probability:   1,
+		},
+	}
+
+	for i, test := range tests {
+		kernel := newForecastKernel(uint32(i), test.amount,
+			start.Add(-test.txPrevAge), start.Add(-test.blockFromAge))
+		output := forecastKernel(kernel, test.bits, start, test.horizon,
+			params)
+		if output.OutPoint != kernel.OutPoint ||
+			output.Amount != kernel.Amount {
+
+			t.Errorf("%s: got output %v of %v, want %v of %v",
+				test.name, output.OutPoint, output.Amount,
+				kernel.OutPoint, kernel.Amount)
+		}
+		if output.CoinAge != test.coinAge {
+			t.Errorf("%s: got coin age %d, want %d", test.name,
+				output.CoinAge, test.coinAge)
+		}
+		if !output.EligibleTime.Equal(test.eligibleTime) {
+			t.Errorf("%s: got eligible time %v, want %v", test.name,
+				output.EligibleTime, test.eligibleTime)
+		}
+		if !output.MaxWeightTime.Equal(test.maxWeightTime) {
+			t.Errorf("%s: got max weight time %v, want %v",
+				test.name, output.MaxWeightTime,
+				test.maxWeightTime)
+		}
+		if !closeProbability(output.Probability, test.probability) {
+			t.Errorf("%s: got probability %v, want %v", test.name,
+				output.Probability, test.probability)
+		}
+	}
+}
+
+// TestForecastStakeKernels ensures the outputs of a forecast are ordered by the
+// time they become eligible and the probability of minting combines theirs.
+func TestForecastStakeKernels(t *testing.T) {
+	t.Parallel()
+
+	day := 24 * time.Hour
+	params := &MainNetStakeKernelParams
+	start := time.Unix(1400000000, 0)
+	amount := btcutil.Amount(100 * peercoinCoin)
+	young := newForecastKernel(0, amount, start.Add(-10*day),
+		start.Add(-10*day))
+	soon := newForecastKernel(1, amount, start.Add(-200*day),
+		start.Add(-29*day))
+	old := newForecastKernel(2, amount, start.Add(-200*day),
+		start.Add(-200*day))
+
+	forecast := ForecastStakeKernels([]*StakeKernel{young, soon, old},
+		testForecastBits, start, 2*day, params)
+	if !forecast.Start.Equal(start) || forecast.Horizon != 2*day ||
+		forecast.Bits != testForecastBits {
+
+		t.Errorf("got forecast from %v over %v at %08x, want from %v "+
+			"over %v at %08x", forecast.Start, forecast.Horizon,
+			forecast.Bits, start, 2*day, testForecastBits)
+	}
+	if want := CompactToDifficulty(testForecastBits); forecast.Difficulty != want {
+		t.Errorf("got difficulty %v, want %v", forecast.Difficulty, want)
+	}
+
+	wantOrder := []*StakeKernel{old, soon, young}
+	wantProbabilities := []float64{
+		mintProbability(6000, 2*86400),
+		mintProbability(6000, 86400),
+		0,
+	}
+	if len(forecast.Outputs) != len(wantOrder) {
+		t.Fatalf("got %d outputs, want %d", len(forecast.Outputs),
+			len(wantOrder))
+	}
+	miss := 1.0
+	for i, output := range forecast.Outputs {
+		if output.OutPoint != wantOrder[i].OutPoint {
+			t.Errorf("output %d: got %v, want %v", i,
+				output.OutPoint, wantOrder[i].OutPoint)
+		}
+		if !closeProbability(output.Probability, wantProbabilities[i]) {
+			t.Errorf("output %d: got probability %v, want %v", i,
+				output.Probability, wantProbabilities[i])
+		}
+		miss *= 1 - wantProbabilities[i]
+	}
+	if !closeProbability(forecast.Probability, 1-miss) {
+		t.Errorf("got probability %v, want %v", forecast.Probability,
+			1-miss)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+const (
+	// peercoinCent is the number of base units in one hundredth of a
+	// peercoin.
+	peercoinCent = 10000
+
+	// rewardCoinYear is the proof-of-stake reward for one coin-year, which
+	// is one percent.
+	rewardCoinYear = peercoinCent
+)
+
+// centSeconds returns the value of an output with the passed amount multiplied
+// by the seconds between the passed times, in hundredths of a peercoin, which
+// is how the Peercoin reference implementation accumulates coin age.
+func centSeconds(amount btcutil.Amount, from, to time.Time) *big.Int {
+	seconds := int64(to.Sub(from) / time.Second)
+	cs := big.NewInt(int64(amount))
+	cs.Mul(cs, big.NewInt(seconds))
+	return cs.Quo(cs, big.NewInt(peercoinCent))
+}
+
+// centSecondsToCoinDays converts the passed cent-seconds to coin-days.
+func centSecondsToCoinDays(cs *big.Int) int64 {
+	coinDays := new(big.Int).Mul(cs, big.NewInt(peercoinCent))
+	coinDays.Quo(coinDays, big.NewInt(peercoinCoin*secondsPerDay))
+	return coinDays.Int64()
+}
+
+// CoinAge returns the age in coin-days of the output described by the kernel
+// when spent by a coinstake transaction with the passed timestamp, following
+// the rules of the Peercoin reference implementation.  Outputs which are too
+// young to stake have no coin age.
+func (k *StakeKernel) CoinAge(txTime time.Time, params *StakeKernelParams) int64 {
+	if !k.Eligible(txTime, params) {
+		return 0
+	}
+	return centSecondsToCoinDays(centSeconds(k.Amount, k.TxPrevTime, txTime))
+}
+
+// ProofOfStakeReward returns the proof-of-stake reward for the passed coin-days,
+// which is one percent a year.  Like the Peercoin reference implementation, the
+// coin-days are truncated to whole coin-years before the reward is applied.
+func ProofOfStakeReward(coinDays int64) btcutil.Amount {
+	return btcutil.Amount(coinDays * 33 / (365*33 + 8) * rewardCoinYear)
+}
+
+// OutPointCoinAge houses the coin age of an output.
+type OutPointCoinAge struct {
+	OutPoint wire.OutPoint
+	Amount   btcutil.Amount
+
+	// TxTime is the timestamp of the transaction creating the output and
+	// BlockTime is the timestamp of the block it is mined in.
+	TxTime    time.Time
+	BlockTime time.Time
+
+	// CoinDays is the age of the output in coin-days and Reward is the
+	// proof-of-stake reward for it if it were staked on its own.  Both are
+	// zero while the output is younger than the minimum stake age.
+	CoinDays int64
+	Reward   btcutil.Amount
+}
+
+// StakeRewardEstimate houses the estimated proof-of-stake reward for staking a
+// set of outputs together.
+type StakeRewardEstimate struct {
+	Time    time.Time
+	Outputs []OutPointCoinAge
+
+	// CoinDays is the total age of the outputs in coin-days and Reward is
+	// the proof-of-stake reward for them.  Since the coin age of the
+	// outputs is accumulated before it is converted to coin-days, Reward
+	// may exceed the sum of the rewards of the outputs.
+	CoinDays int64
+	Reward   btcutil.Amount
+
+	// LastProofOfWorkReward is the reward of the last proof-of-work block
+	// and RewardRatio is the proof-of-stake reward relative to it.
+	LastProofOfWorkReward btcutil.Amount
+	RewardRatio           float64
+}
+
+// getOutPointCoinAge returns the coin age of the passed outpoint at the passed
+// time along with its cent-seconds.
+func (c *Client) getOutPointCoinAge(op *wire.OutPoint, at time.Time,
+	params *StakeKernelParams) (*OutPointCoinAge, *big.Int, error) {
+
+	rawTx, err := c.GetRawTransactionVerbose(&op.Hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rawTx.BlockHash == "" {
+		return nil, nil, fmt.Errorf("transaction %v is not mined",
+			op.Hash)
+	}
+	tx, err := parseHexTx(rawTx.Hex)
+	if err != nil {
+		return nil, nil, err
+	}
+	txOuts := tx.MsgTx().TxOut
+	if int(op.Index) >= len(txOuts) {
+		return nil, nil, fmt.Errorf("transaction %v has no output %d",
+			op.Hash, op.Index)
+	}
+
+	age := &OutPointCoinAge{
+		OutPoint:  *op,
+		Amount:    btcutil.Amount(txOuts[op.Index].Value),
+		TxTime:    tx.MsgTx().Time,
+		BlockTime: time.Unix(rawTx.Blocktime, 0),
+	}
+
+	// Like the reference implementation, only outputs mined at least the
+	// minimum stake age ago count.
+	cs := new(big.Int)
+	if !at.Before(age.TxTime) && !age.BlockTime.Add(params.StakeMinAge).After(at) {
+		cs = centSeconds(age.Amount, age.TxTime, at)
+	}
+	age.CoinDays = centSecondsToCoinDays(cs)
+	age.Reward = ProofOfStakeReward(age.CoinDays)
+	return age, cs, nil
+}
+
+// GetCoinAge returns the coin age of the passed outpoints at the passed time,
+// using the main network parameters when params is nil.  The outputs do not
+// need to be unspent or to belong to the wallet.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) GetCoinAge(ops []*wire.OutPoint, at time.Time,
+	params *StakeKernelParams) ([]OutPointCoinAge, error) {
+
+	if params == nil {
+		params = &MainNetStakeKernelParams
+	}
+	ages := make([]OutPointCoinAge, 0, len(ops))
+	for _, op := range ops {
+		age, _, err := c.getOutPointCoinAge(op, at, params)
+		if err != nil {
+			return nil, err
+		}
+		ages = append(ages, *age)
+	}
+	return ages, nil
+}
+
+// EstimateStakeReward returns the estimated proof-of-stake reward for staking
+// the passed outpoints together in a coinstake transaction with the passed
+// timestamp, using the main network parameters when params is nil, along with
+// the reward of the last proof-of-work block for comparison.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) EstimateStakeReward(ops []*wire.OutPoint, txTime time.Time,
+	params *StakeKernelParams) (*StakeRewardEstimate, error) {
+
+	if params == nil {
+		params = &MainNetStakeKernelParams
+	}
+	powReward := c.GetLastProofOfWorkRewardAsync()
+
+	estimate := &StakeRewardEstimate{
+		Time:    txTime,
+		Outputs: make([]OutPointCoinAge, 0, len(ops)),
+	}
+	total := new(big.Int)
+	for _, op := range ops {
+		age, cs, err := c.getOutPointCoinAge(op, txTime, params)
+		if err != nil {
+			return nil, err
+		}
+		estimate.Outputs = append(estimate.Outputs, *age)
+		total.Add(total, cs)
+	}
+	estimate.CoinDays = centSecondsToCoinDays(total)
+	estimate.Reward = ProofOfStakeReward(estimate.CoinDays)
+
+	subsidy, err := powReward.Receive()
+	if err != nil {
+		return nil, err
+	}
+	estimate.LastProofOfWorkReward = btcutil.Amount(subsidy)
+	if subsidy > 0 {
+		estimate.RewardRatio = float64(estimate.Reward) / float64(subsidy)
+	}
+	return estimate, nil
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ppcsuite/btcutil"
+)
+
+// TestCoinDays ensures coin age is accumulated in cent-seconds and converted to
+// whole coin-days like the Peercoin reference implementation.
+func TestCoinDays(t *testing.T) {
+	t.Parallel()
+
+	from := time.Unix(1400000000, 0)
+	day := 24 * time.Hour
+	tests := []struct {
+		name     string
+		amount   btcutil.Amount
+		age      time.Duration
+		coinDays int64
+	}{
+		{"one coin for one day", peercoinCoin, day, 1},
+		{"hundred coins for ten days", 100 * peercoinCoin, 10 * day, 1000},
+		{"half coin for three days", peercoinCoin / 2, 3 * day, 1},
+		{"one coin for almost a day", peercoinCoin, day - time.Second, 0},
+		{"below one coin for a day", peercoinCoin - 1, day, 0},
+		{"no age", 1000 * peercoinCoin, 0, 0},
+	}
+
+	for _, test := range tests {
+		cs := centSeconds(test.amount, from, from.Add(test.age))
+		coinDays := centSecondsToCoinDays(cs)
+		if coinDays != test.coinDays {
+			t.Errorf("%s: got %d coin-days, want %d", test.name,
+				coinDays, test.coinDays)
+		}
+	}
+}
+
+// TestProofOfStakeReward ensures the proof-of-stake reward truncates the
+// coin-days to whole coin-years before applying the reward per coin-year.
+func TestProofOfStakeReward(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		coinDays int64
+		reward   btcutil.Amount
+	}{
+		{0, 0},
+		{365, 0},
+		{366, 1 * peercoinCent},
+		{730, 1 * peercoinCent},
+		{731, 2 * peercoinCent},
+		{1000, 2 * peercoinCent},
+		{36525, 100 * peercoinCent},
+	}
+
+	for _, test := range tests {
+		reward := ProofOfStakeReward(test.coinDays)
+		if reward != test.reward {
+			t.Errorf("ProofOfStakeReward(%d): got %d, want %d",
+				test.coinDays, reward, test.reward)
+		}
+	}
+}
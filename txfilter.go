@@ -0,0 +1,405 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/txscript"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// These constants are the methods of the transaction filter commands and
+// notifications, which are not known to btcjson.
+const (
+	loadTxFilterMethod                  = "loadtxfilter"
+	rescanBlocksMethod                  = "rescanblocks"
+	relevantTxAcceptedNtfnMethod        = "relevanttxaccepted"
+	filteredBlockConnectedNtfnMethod    = "filteredblockconnected"
+	filteredBlockDisconnectedNtfnMethod = "filteredblockdisconnected"
+)
+
+// loadTxFilterCmd describes a loadtxfilter request.  It is associated with the
+// request so the loaded filter can be tracked in the notification state once
+// the server accepts it.
+type loadTxFilterCmd struct {
+	Reload    bool
+	Addresses []string
+	OutPoints []btcjson.OutPoint
+}
+
+// txFilterSupport is used to remember whether or not the RPC server supports
+// the transaction filter commands so the server only needs to be probed once.
+type txFilterSupport struct {
+	sync.Mutex
+	checked   bool
+	supported bool
+}
+
+// TxFilterSupported returns whether or not the RPC server supports the
+// loadtxfilter and rescanblocks commands.  The server is only queried the first
+// time this function is called and the result is cached afterwards.  False is
+// always returned when the client is configured to run in HTTP POST mode since
+// the commands require a websocket connection.
+func (c *Client) TxFilterSupported() (bool, error) {
+	if c.config.HTTPPostMode {
+		return false, nil
+	}
+
+	c.txFilterSupport.Lock()
+	defer c.txFilterSupport.Unlock()
+
+	if c.txFilterSupport.checked {
+		return c.txFilterSupport.supported, nil
+	}
+
+	// Probe the server with an empty rescanblocks request, which does not
+	// modify any state on the server.  Any error other than the method
+	// being unknown means the command is supported, such as the server
+	// complaining about no filter being loaded yet.  Unimplemented methods
+	// can not be told apart from that since btcd reports both with the
+	// same code as miscellaneous errors.
+	_, err := c.RescanBlocksAsync(nil).Receive()
+	if err != nil {
+		if _, ok := err.(*btcjson.RPCError); !ok {
+			return false, err
+		}
+	}
+	c.txFilterSupport.checked = true
+	c.txFilterSupport.supported = !isMethodNotFoundError(err)
+	log.Debugf("Transaction filter supported by %s: %v", c.config.Host,
+		c.txFilterSupport.supported)
+
+	return c.txFilterSupport.supported, nil
+}
+
+// FutureLoadTxFilterResult is a future promise to deliver the result of a
+// LoadTxFilterAsync RPC invocation (or an applicable error).
+type FutureLoadTxFilterResult chan *response
+
+// Receive waits for the response promised by the future and returns an error
+// if the filter could not be loaded.
+func (r FutureLoadTxFilterResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// loadTxFilterInternal is the same as LoadTxFilterAsync except it accepts the
+// converted addresses and outpoints as parameters so the client can more
+// efficiently reload the previous filter on reconnect.
+func (c *Client) loadTxFilterInternal(reload bool, addresses []string,
+	outpoints []btcjson.OutPoint) FutureLoadTxFilterResult {
+
+	// Not supported in HTTP POST mode.
+	if c.config.HTTPPostMode {
+		return newFutureError(ErrNotificationsNotSupported)
+	}
+
+	// Ignore the notification if the client is not interested in
+	// notifications.
+	if c.ntfnHandlers == nil {
+		return newNilFutureResult()
+	}
+
+	// The server expects arrays rather than null for empty filters.
+	if addresses == nil {
+		addresses = []string{}
+	}
+	if outpoints == nil {
+		outpoints = []btcjson.OutPoint{}
+	}
+	cmd := &loadTxFilterCmd{
+		Reload:    reload,
+		Addresses: addresses,
+		OutPoints: outpoints,
+	}
+	return c.sendRawCmd(loadTxFilterMethod, cmd, reload, addresses,
+		outpoints)
+}
+
+// LoadTxFilterAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See LoadTxFilter for the blocking version and more details.
+//
+// NOTE: Unlike LoadTxFilter, this function does not fall back to the legacy
+// notification commands when the server does not support the transaction
+// filter.
+func (c *Client) LoadTxFilterAsync(reload bool, addresses []btcutil.Address,
+	outpoints []*wire.OutPoint) FutureLoadTxFilterResult {
+
+	addrs := make([]string, 0, len(addresses))
+	for _, addr := range addresses {
+		addrs = append(addrs, addr.String())
+	}
+	ops := make([]btcjson.OutPoint, 0, len(outpoints))
+	for _, op := range outpoints {
+		ops = append(ops, newOutPointFromWire(op))
+	}
+	return c.loadTxFilterInternal(reload, addrs, ops)
+}
+
+// LoadTxFilter loads the passed addresses and outpoints into the transaction
+// filter of the RPC server.  When reload is true, the passed addresses and
+// outpoints replace the previously loaded filter, otherwise they are added to
+// it.  Unlike NotifyReceived and NotifySpent, the filter is reloaded with a
+// single command on reconnect and can be shrunk by reloading it.  Calling this
+// function has no effect if there are no notification handlers and will result
+// in an error if the client is configured to run in HTTP POST mode.
+//
+// The notifications delivered as a result of this call will be via
+// OnRelevantTxAccepted for transactions accepted into the memory pool and, when
+// registered with NotifyBlocks, OnFilteredBlockConnected for blocks.  The
+// server automatically adds the outputs paying to a filtered address to the
+// filter, and the client does the same so they are kept on reconnect.
+//
+// When the server does not support the transaction filter (see
+// TxFilterSupported), the addresses and outpoints are registered with
+// NotifyReceived and NotifySpent instead, in which case the notifications are
+// delivered via the OnRecvTx and OnRedeemingTx handlers and previously
+// registered addresses and outpoints can not be removed by a reload.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func (c *Client) LoadTxFilter(reload bool, addresses []btcutil.Address,
+	outpoints []*wire.OutPoint) error {
+
+	supported, err := c.TxFilterSupported()
+	if err != nil {
+		return err
+	}
+	if supported {
+		return c.LoadTxFilterAsync(reload, addresses, outpoints).Receive()
+	}
+
+	if reload {
+		log.Debugf("Transaction filter not supported by %s, unable "+
+			"to remove previously registered addresses and "+
+			"outpoints", c.config.Host)
+	}
+	if len(addresses) > 0 {
+		if err := c.NotifyReceived(addresses); err != nil {
+			return err
+		}
+	}
+	if len(outpoints) > 0 {
+		if err := c.NotifySpent(outpoints); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trackTxFilterMatches updates the tracked transaction filter the same way the
+// server updates its filter when it notifies about the passed transaction.
+// Outpoints spent by the transaction are removed and outputs paying to a
+// filtered address are added.
+func (c *Client) trackTxFilterMatches(tx *btcutil.Tx) {
+	// Nothing to do if the caller is not interested in notifications.
+	if c.ntfnHandlers == nil {
+		return
+	}
+
+	c.ntfnState.Lock()
+	defer c.ntfnState.Unlock()
+
+	if !c.ntfnState.txFilterLoaded {
+		return
+	}
+
+	changed := false
+	msgTx := tx.MsgTx()
+	for _, txIn := range msgTx.TxIn {
+		op := newOutPointFromWire(&txIn.PreviousOutPoint)
+		if _, ok := c.ntfnState.txFilterOutPoints[op]; ok {
+			delete(c.ntfnState.txFilterOutPoints, op)
+			changed = true
+		}
+	}
+
+	params := c.chainParams()
+	for i, txOut := range msgTx.TxOut {
+		// Outputs with non-standard scripts can not pay to a filtered
+		// address.
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(txOut.PkScript,
+			params)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			_, ok := c.ntfnState.txFilterAddrs[addr.EncodeAddress()]
+			if !ok {
+				_, ok = c.ntfnState.txFilterAddrs[addr.String()]
+			}
+			if !ok {
+				continue
+			}
+			op := wire.NewOutPoint(tx.Sha(), uint32(i))
+			c.ntfnState.txFilterOutPoints[newOutPointFromWire(op)] = struct{}{}
+			changed = true
+			break
+		}
+	}
+	if changed {
+		c.ntfnStateChanged()
+	}
+}
+
+// RescannedBlock houses a block along with the transactions in it which matched
+// the transaction filter during a RescanBlocks invocation.
+type RescannedBlock struct {
+	Hash         *wire.ShaHash
+	Transactions []*btcutil.Tx
+}
+
+// rescannedBlockResult models the data returned from the rescanblocks command
+// for each block with matching transactions.
+type rescannedBlockResult struct {
+	Hash         string   `json:"hash"`
+	Transactions []string `json:"transactions"`
+}
+
+// FutureRescanBlocksResult is a future promise to deliver the result of a
+// RescanBlocksAsync RPC invocation (or an applicable error).
+type FutureRescanBlocksResult chan *response
+
+// Receive waits for the response promised by the future and returns the blocks
+// with transactions that matched the transaction filter.
+func (r FutureRescanBlocksResult) Receive() ([]RescannedBlock, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as an array of rescanned block objects.
+	var results []rescannedBlockResult
+	err = json.Unmarshal(res, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make([]RescannedBlock, 0, len(results))
+	for _, result := range results {
+		hash, err := wire.NewShaHashFromStr(result.Hash)
+		if err != nil {
+			return nil, err
+		}
+		txs := make([]*btcutil.Tx, 0, len(result.Transactions))
+		for _, txHex := range result.Transactions {
+			// Hex decode and deserialize the transaction.
+			serializedTx, err := hex.DecodeString(txHex)
+			if err != nil {
+				return nil, err
+			}
+			var msgTx wire.MsgTx
+			err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+			if err != nil {
+				return nil, err
+			}
+			txs = append(txs, btcutil.NewTx(&msgTx))
+		}
+		blocks = append(blocks, RescannedBlock{
+			Hash:         hash,
+			Transactions: txs,
+		})
+	}
+	return blocks, nil
+}
+
+// RescanBlocksAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See RescanBlocks for the blocking version and more details.
+//
+// NOTE: Unlike RescanBlocks, this function does not fall back to scanning the
+// blocks on the client when the server does not support the transaction
+// filter, and does not add the outputs paying to filtered addresses to the
+// filter tracked by the client.
+func (c *Client) RescanBlocksAsync(blockHashes []*wire.ShaHash) FutureRescanBlocksResult {
+	// Not supported in HTTP POST mode.
+	if c.config.HTTPPostMode {
+		return newFutureError(ErrNotificationsNotSupported)
+	}
+
+	hashes := make([]string, 0, len(blockHashes))
+	for _, hash := range blockHashes {
+		hashes = append(hashes, hash.String())
+	}
+	return c.sendRawCmd(rescanBlocksMethod, nil, hashes)
+}
+
+// RescanBlocks rescans the passed blocks, which must be part of the main chain
+// and ordered by increasing height, for transactions matching the transaction
+// filter loaded with LoadTxFilter and returns the blocks with matching
+// transactions along with those transactions.  Unlike Rescan, the matches are
+// returned directly rather than delivered as notifications.
+//
+// When the server does not support the transaction filter (see
+// TxFilterSupported), the blocks are fetched with GetBlock and scanned by the
+// client for transactions paying to or spending from the addresses and
+// outpoints registered with NotifyReceived and NotifySpent instead.
+//
+// NOTE: This is a btcd extension and requires a websocket connection unless
+// the server does not support the transaction filter.
+func (c *Client) RescanBlocks(blockHashes []*wire.ShaHash) ([]RescannedBlock, error) {
+	supported, err := c.TxFilterSupported()
+	if err != nil {
+		return nil, err
+	}
+	if !supported {
+		return c.rescanBlocksLocally(blockHashes)
+	}
+
+	blocks, err := c.RescanBlocksAsync(blockHashes).Receive()
+	if err != nil {
+		return nil, err
+	}
+	for _, block := range blocks {
+		for _, tx := range block.Transactions {
+			c.trackTxFilterMatches(tx)
+		}
+	}
+	return blocks, nil
+}
+
+// rescanBlocksLocally fetches each of the passed blocks and returns the blocks
+// with transactions which pay to or spend from the addresses and outpoints
+// watched by the client.  Like the server, outputs paying to a watched address
+// are watched for spends in the blocks that follow.
+func (c *Client) rescanBlocksLocally(blockHashes []*wire.ShaHash) ([]RescannedBlock, error) {
+	var blocks []RescannedBlock
+	for _, hash := range blockHashes {
+		block, err := c.GetBlock(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		var txs []*btcutil.Tx
+		for _, tx := range block.Transactions() {
+			ntfn, err := c.newChainTxNtfn(tx, nil)
+			if err != nil {
+				return nil, err
+			}
+			if len(ntfn.Inputs) == 0 && len(ntfn.Outputs) == 0 {
+				continue
+			}
+			txs = append(txs, tx)
+		}
+		if len(txs) == 0 {
+			continue
+		}
+		blocks = append(blocks, RescannedBlock{
+			Hash:         hash,
+			Transactions: txs,
+		})
+	}
+	return blocks, nil
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ppcsuite/ppcd/btcjson"
+)
+
+// TestTxFilterProbeErrors ensures only the server reporting an unknown method
+// in reply to the transaction filter probe is treated as the transaction filter
+// not being supported.
+func TestTxFilterProbeErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		err         error
+		unsupported bool
+	}{
+		{
+			name:        "method not found",
+			err:         btcjson.ErrRPCMethodNotFound,
+			unsupported: true,
+		},
+		{
+			name: "no filter loaded",
+			err: &btcjson.RPCError{
+				Code: btcjson.ErrRPCMisc,
+				Message: "Transaction filter must be loaded " +
+					"before rescanning",
+			},
+		},
+		{
+			name: "unimplemented",
+			err: &btcjson.RPCError{
+				Code:    btcjson.ErrRPCUnimplemented,
+				Message: "Command unimplemented",
+			},
+		},
+		{
+			name: "invalid parameter",
+			err: &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "invalid parameter",
+			},
+		},
+		{
+			name: "transport error",
+			err:  errors.New("connection refused"),
+		},
+	}
+
+	for _, test := range tests {
+		got := isMethodNotFoundError(test.err)
+		if got != test.unsupported {
+			t.Errorf("%s: got unsupported %v, want %v", test.name,
+				got, test.unsupported)
+		}
+	}
+}
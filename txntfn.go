@@ -0,0 +1,361 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ppcsuite/btcutil"
+	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/chaincfg"
+	"github.com/ppcsuite/ppcd/txscript"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// WatchedTxOut describes a transaction output which pays to an address
+// registered with NotifyReceived or passed to a rescan.
+type WatchedTxOut struct {
+	Index   uint32
+	Amount  btcutil.Amount
+	Address btcutil.Address
+}
+
+// WatchedTxIn describes a transaction input which spends an outpoint
+// registered with NotifySpent, passed to a rescan, or automatically watched by
+// the server after funds were received to a registered address.
+type WatchedTxIn struct {
+	Index            uint32
+	PreviousOutPoint wire.OutPoint
+}
+
+// ChainTxNtfn houses the details of a recvtx or redeemingtx notification.
+type ChainTxNtfn struct {
+	Tx *btcutil.Tx
+
+	// Outputs holds the outputs of the transaction which pay to watched
+	// addresses, ordered by output index.
+	Outputs []WatchedTxOut
+
+	// Inputs holds the inputs of the transaction which spend watched
+	// outpoints, ordered by input index.
+	Inputs []WatchedTxIn
+
+	// Mempool is true when the transaction was accepted into the memory
+	// pool and false when it is mined in a block.  The block fields are
+	// only set in the latter case.
+	Mempool     bool
+	BlockHash   *wire.ShaHash
+	BlockHeight int32
+	BlockTime   time.Time
+	BlockIndex  int
+
+	// Delivery describes how the notification relates to previous ones
+	// for the same transaction when the NtfnDedupWindow config option is
+	// set.
+	Delivery ChainTxDelivery
+}
+
+const (
+	// maxCachedTxOutValues is the maximum number of transactions accepted
+	// into the memory pool for which the client remembers the output
+	// values in order to determine the fees of the transactions spending
+	// them.
+	maxCachedTxOutValues = 5000
+)
+
+// TxAcceptedOutput describes an output of a transaction accepted into the
+// memory pool.
+type TxAcceptedOutput struct {
+	Index       uint32
+	Amount      btcutil.Amount
+	ScriptClass txscript.ScriptClass
+	Addresses   []btcutil.Address
+}
+
+// TxAcceptedInput describes an input of a transaction accepted into the memory
+// pool.  The value of the spent output is only set when ValueKnown is true.
+type TxAcceptedInput struct {
+	PreviousOutPoint wire.OutPoint
+	Value            btcutil.Amount
+	ValueKnown       bool
+}
+
+// TxAcceptedNtfn houses the details of a txacceptedverbose notification.
+type TxAcceptedNtfn struct {
+	TxHash   wire.ShaHash
+	Tx       *wire.MsgTx
+	Inputs   []TxAcceptedInput
+	Outputs  []TxAcceptedOutput
+	TotalOut btcutil.Amount
+
+	// Fee is the fee paid by the transaction.  It is only set when
+	// FeeKnown is true, which is the case when the values of all inputs
+	// are known.  See ResolveTxAcceptedFee to look up the values of the
+	// remaining inputs.
+	Fee      btcutil.Amount
+	FeeKnown bool
+}
+
+// updateFee sets the fee of the notification when the values of all inputs
+// are known.
+func (n *TxAcceptedNtfn) updateFee() {
+	var totalIn btcutil.Amount
+	for _, txIn := range n.Inputs {
+		if !txIn.ValueKnown {
+			return
+		}
+		totalIn += txIn.Value
+	}
+	n.Fee = totalIn - n.TotalOut
+	n.FeeKnown = true
+}
+
+// txOutValueCache is a bounded cache of the output values of recently accepted
+// transactions.  The oldest transactions are evicted first.
+type txOutValueCache struct {
+	sync.Mutex
+	values  map[wire.ShaHash][]btcutil.Amount
+	order   []wire.ShaHash
+	maxSize int
+}
+
+// newTxOutValueCache returns a new cache which remembers the output values of
+// up to maxSize transactions.
+func newTxOutValueCache(maxSize int) *txOutValueCache {
+	return &txOutValueCache{
+		values:  make(map[wire.ShaHash][]btcutil.Amount),
+		maxSize: maxSize,
+	}
+}
+
+// add remembers the output values of the passed transaction.
+//
+// This function is safe for concurrent access.
+func (c *txOutValueCache) add(txHash *wire.ShaHash, values []btcutil.Amount) {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.values[*txHash]; ok {
+		return
+	}
+	if len(c.order) >= c.maxSize {
+		delete(c.values, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.values[*txHash] = values
+	c.order = append(c.order, *txHash)
+}
+
+// lookup returns the value of the passed outpoint, if known.
+//
+// This function is safe for concurrent access.
+func (c *txOutValueCache) lookup(op *wire.OutPoint) (btcutil.Amount, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	values, ok := c.values[op.Hash]
+	if !ok || int(op.Index) >= len(values) {
+		return 0, false
+	}
+	return values[op.Index], true
+}
+
+// newTxAcceptedNtfn returns the details of a txacceptedverbose notification for
+// the passed raw transaction result.  The values of the inputs spending outputs
+// of previously accepted transactions are resolved from the client cache.
+func (c *Client) newTxAcceptedNtfn(rawTx *btcjson.TxRawResult) (*TxAcceptedNtfn, error) {
+	// Hex decode and deserialize the transaction.
+	serializedTx, err := hex.DecodeString(rawTx.Hex)
+	if err != nil {
+		return nil, err
+	}
+	var msgTx wire.MsgTx
+	err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+	if err != nil {
+		return nil, err
+	}
+
+	ntfn := &TxAcceptedNtfn{
+		TxHash:  msgTx.TxSha(),
+		Tx:      &msgTx,
+		Inputs:  make([]TxAcceptedInput, 0, len(msgTx.TxIn)),
+		Outputs: make([]TxAcceptedOutput, 0, len(msgTx.TxOut)),
+	}
+
+	params := c.chainParams()
+	values := make([]btcutil.Amount, 0, len(msgTx.TxOut))
+	for i, txOut := range msgTx.TxOut {
+		// Non-standard scripts are reported without addresses, so the
+		// error is intentionally ignored.
+		class, addrs, _, _ := txscript.ExtractPkScriptAddrs(txOut.PkScript,
+			params)
+		amount := btcutil.Amount(txOut.Value)
+		ntfn.Outputs = append(ntfn.Outputs, TxAcceptedOutput{
+			Index:       uint32(i),
+			Amount:      amount,
+			ScriptClass: class,
+			Addresses:   addrs,
+		})
+		ntfn.TotalOut += amount
+		values = append(values, amount)
+	}
+	c.txOutValues.add(&ntfn.TxHash, values)
+
+	for _, txIn := range msgTx.TxIn {
+		value, ok := c.txOutValues.lookup(&txIn.PreviousOutPoint)
+		ntfn.Inputs = append(ntfn.Inputs, TxAcceptedInput{
+			PreviousOutPoint: txIn.PreviousOutPoint,
+			Value:            value,
+			ValueKnown:       ok,
+		})
+	}
+	ntfn.updateFee()
+
+	return ntfn, nil
+}
+
+// ResolveTxAcceptedFee looks up the values of the inputs of the passed
+// notification which are not already known and then sets the fee of the
+// notification.  The values are looked up with GetTxOut, which does not require
+// the transaction index.  Only inputs whose spent output is no longer found
+// that way, such as when the spending transaction is already mined, fall back
+// to fetching the spent transaction with GetRawTransaction, which typically
+// requires the transaction index to be enabled for confirmed transactions.
+//
+// NOTE: This function issues blocking requests, so it must NOT be called
+// directly from a notification handler.
+func (c *Client) ResolveTxAcceptedFee(ntfn *TxAcceptedNtfn) error {
+	if ntfn.FeeKnown {
+		return nil
+	}
+
+	// Request the values of all unknown inputs at once.
+	futures := make([]FutureGetTxOutResult, len(ntfn.Inputs))
+	for i := range ntfn.Inputs {
+		txIn := &ntfn.Inputs[i]
+		if txIn.ValueKnown {
+			continue
+		}
+		op := &txIn.PreviousOutPoint
+		futures[i] = c.GetTxOutAsync(&op.Hash, op.Index, true)
+	}
+
+	for i, future := range futures {
+		if future == nil {
+			continue
+		}
+		txIn := &ntfn.Inputs[i]
+		txOut, err := future.Receive()
+		if err != nil {
+			return err
+		}
+		if txOut != nil {
+			txIn.Value = btcutil.Amount(txOut.Value)
+			txIn.ValueKnown = true
+			continue
+		}
+
+		op := &txIn.PreviousOutPoint
+		prevTx, err := c.GetRawTransaction(&op.Hash)
+		if err != nil {
+			return err
+		}
+		txOuts := prevTx.MsgTx().TxOut
+		if int(op.Index) >= len(txOuts) {
+			return fmt.Errorf("transaction %v has no output %d",
+				op.Hash, op.Index)
+		}
+		txIn.Value = btcutil.Amount(txOuts[op.Index].Value)
+		txIn.ValueKnown = true
+	}
+	ntfn.updateFee()
+
+	return nil
+}
+
+// chainParams returns the network parameters the client uses to decode
+// addresses.
+func (c *Client) chainParams() *chaincfg.Params {
+	if c.config.ChainParams != nil {
+		return c.config.ChainParams
+	}
+	return &chaincfg.MainNetParams
+}
+
+// newChainTxNtfn returns the details of a recvtx or redeemingtx notification
+// for the passed transaction and optional block details.  The outputs paying to
+// watched addresses are added to the watched outpoints since the server
+// automatically watches them for spends.
+func (c *Client) newChainTxNtfn(tx *btcutil.Tx, block *btcjson.BlockDetails) (*ChainTxNtfn, error) {
+	ntfn := &ChainTxNtfn{
+		Tx:      tx,
+		Mempool: block == nil,
+	}
+	if block != nil {
+		blockHash, err := wire.NewShaHashFromStr(block.Hash)
+		if err != nil {
+			return nil, err
+		}
+		ntfn.BlockHash = blockHash
+		ntfn.BlockHeight = block.Height
+		ntfn.BlockTime = time.Unix(block.Time, 0)
+		ntfn.BlockIndex = block.Index
+	}
+
+	params := c.chainParams()
+	msgTx := tx.MsgTx()
+
+	c.ntfnState.Lock()
+	defer c.ntfnState.Unlock()
+
+	for i, txIn := range msgTx.TxIn {
+		op := newOutPointFromWire(&txIn.PreviousOutPoint)
+		if !c.ntfnState.isWatchedOutPoint(op) {
+			continue
+		}
+		ntfn.Inputs = append(ntfn.Inputs, WatchedTxIn{
+			Index:            uint32(i),
+			PreviousOutPoint: txIn.PreviousOutPoint,
+		})
+
+		// Outpoints spent in a block no longer need to be watched.
+		// Those spent in the memory pool are kept so the notification
+		// delivered once the spend is mined still matches.
+		if block != nil {
+			c.ntfnState.watchedSpent.remove(op)
+		}
+	}
+
+	for i, txOut := range msgTx.TxOut {
+		// Outputs with non-standard scripts can not pay to a watched
+		// address.
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(txOut.PkScript,
+			params)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if !c.ntfnState.isWatchedAddress(addr.EncodeAddress()) &&
+				!c.ntfnState.isWatchedAddress(addr.String()) {
+				continue
+			}
+			ntfn.Outputs = append(ntfn.Outputs, WatchedTxOut{
+				Index:   uint32(i),
+				Amount:  btcutil.Amount(txOut.Value),
+				Address: addr,
+			})
+
+			op := wire.NewOutPoint(tx.Sha(), uint32(i))
+			c.ntfnState.watchedSpent.add(newOutPointFromWire(op))
+			break
+		}
+	}
+
+	return ntfn, nil
+}
@@ -6,7 +6,6 @@ package btcrpcclient
 
 import (
 	"encoding/json"
-	"strconv"
 
 	"github.com/ppcsuite/btcutil"
 	"github.com/ppcsuite/ppcd/btcjson"
@@ -18,20 +17,54 @@ import (
 // Transaction Listing Functions
 // *****************************
 
+// GetTransactionDetailsResult models the details of a wallet transaction
+// returned by the gettransaction command, with the amount and fee decoded
+// exactly.  The float64 amounts of the embedded result are decoded as well for
+// compatibility.
+type GetTransactionDetailsResult struct {
+	btcjson.GetTransactionDetailsResult
+	Amount Amount  `json:"amount"`
+	Fee    *Amount `json:"fee,omitempty"`
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface and unmarshals both
+// the exact amounts and the embedded result.
+func (r *GetTransactionDetailsResult) UnmarshalJSON(data []byte) error {
+	type result GetTransactionDetailsResult
+	return unmarshalAmounts(data, (*result)(r), &r.GetTransactionDetailsResult)
+}
+
+// GetTransactionResult models the data from the gettransaction command, with
+// the amount, fee, and details of the transaction decoded exactly.  The float64
+// amounts of the embedded result are decoded as well for compatibility.
+type GetTransactionResult struct {
+	btcjson.GetTransactionResult
+	Amount  Amount                        `json:"amount"`
+	Fee     Amount                        `json:"fee,omitempty"`
+	Details []GetTransactionDetailsResult `json:"details"`
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface and unmarshals both
+// the exact amounts and the embedded result.
+func (r *GetTransactionResult) UnmarshalJSON(data []byte) error {
+	type result GetTransactionResult
+	return unmarshalAmounts(data, (*result)(r), &r.GetTransactionResult)
+}
+
 // FutureGetTransactionResult is a future promise to deliver the result
 // of a GetTransactionAsync RPC invocation (or an applicable error).
 type FutureGetTransactionResult chan *response
 
 // Receive waits for the response promised by the future and returns detailed
 // information about a wallet transaction.
-func (r FutureGetTransactionResult) Receive() (*btcjson.GetTransactionResult, error) {
+func (r FutureGetTransactionResult) Receive() (*GetTransactionResult, error) {
 	res, err := receiveFuture(r)
 	if err != nil {
 		return nil, err
 	}
 
 	// Unmarshal result as a gettransaction result object
-	var getTx btcjson.GetTransactionResult
+	var getTx GetTransactionResult
 	err = json.Unmarshal(res, &getTx)
 	if err != nil {
 		return nil, err
@@ -58,10 +91,27 @@ func (c *Client) GetTransactionAsync(txHash *wire.ShaHash) FutureGetTransactionR
 // GetTransaction returns detailed information about a wallet transaction.
 //
 // See GetRawTransaction to return the raw transaction instead.
-func (c *Client) GetTransaction(txHash *wire.ShaHash) (*btcjson.GetTransactionResult, error) {
+func (c *Client) GetTransaction(txHash *wire.ShaHash) (*GetTransactionResult, error) {
 	return c.GetTransactionAsync(txHash).Receive()
 }
 
+// ListTransactionsResult models the data of a wallet transaction returned by
+// the listtransactions and listsinceblock commands, with the amount and fee
+// decoded exactly.  The float64 amounts of the embedded result are decoded as
+// well for compatibility.
+type ListTransactionsResult struct {
+	btcjson.ListTransactionsResult
+	Amount Amount  `json:"amount"`
+	Fee    *Amount `json:"fee,omitempty"`
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface and unmarshals both
+// the exact amounts and the embedded result.
+func (r *ListTransactionsResult) UnmarshalJSON(data []byte) error {
+	type result ListTransactionsResult
+	return unmarshalAmounts(data, (*result)(r), &r.ListTransactionsResult)
+}
+
 // FutureListTransactionsResult is a future promise to deliver the result of a
 // ListTransactionsAsync, ListTransactionsCountAsync, or
 // ListTransactionsCountFromAsync RPC invocation (or an applicable error).
@@ -69,14 +119,14 @@ type FutureListTransactionsResult chan *response
 
 // Receive waits for the response promised by the future and returns a list of
 // the most recent transactions.
-func (r FutureListTransactionsResult) Receive() ([]btcjson.ListTransactionsResult, error) {
+func (r FutureListTransactionsResult) Receive() ([]ListTransactionsResult, error) {
 	res, err := receiveFuture(r)
 	if err != nil {
 		return nil, err
 	}
 
 	// Unmarshal result as an array of listtransaction result objects.
-	var transactions []btcjson.ListTransactionsResult
+	var transactions []ListTransactionsResult
 	err = json.Unmarshal(res, &transactions)
 	if err != nil {
 		return nil, err
@@ -99,7 +149,7 @@ func (c *Client) ListTransactionsAsync(account string) FutureListTransactionsRes
 //
 // See the ListTransactionsCount and ListTransactionsCountFrom to control the
 // number of transactions returned and starting point, respectively.
-func (c *Client) ListTransactions(account string) ([]btcjson.ListTransactionsResult, error) {
+func (c *Client) ListTransactions(account string) ([]ListTransactionsResult, error) {
 	return c.ListTransactionsAsync(account).Receive()
 }
 
@@ -118,7 +168,7 @@ func (c *Client) ListTransactionsCountAsync(account string, count int) FutureLis
 //
 // See the ListTransactions and ListTransactionsCountFrom functions for
 // different options.
-func (c *Client) ListTransactionsCount(account string, count int) ([]btcjson.ListTransactionsResult, error) {
+func (c *Client) ListTransactionsCount(account string, count int) ([]ListTransactionsResult, error) {
 	return c.ListTransactionsCountAsync(account, count).Receive()
 }
 
@@ -136,10 +186,26 @@ func (c *Client) ListTransactionsCountFromAsync(account string, count, from int)
 // to the passed count while skipping the first 'from' transactions.
 //
 // See the ListTransactions and ListTransactionsCount functions to use defaults.
-func (c *Client) ListTransactionsCountFrom(account string, count, from int) ([]btcjson.ListTransactionsResult, error) {
+func (c *Client) ListTransactionsCountFrom(account string, count, from int) ([]ListTransactionsResult, error) {
 	return c.ListTransactionsCountFromAsync(account, count, from).Receive()
 }
 
+// ListUnspentResult models the data of an unspent wallet transaction output
+// returned by the listunspent command, with the amount of the output decoded
+// exactly.  The float64 Amount of the embedded result is decoded as well for
+// compatibility.
+type ListUnspentResult struct {
+	btcjson.ListUnspentResult
+	Amount Amount `json:"amount"`
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface and unmarshals both
+// the exact amount and the embedded result.
+func (r *ListUnspentResult) UnmarshalJSON(data []byte) error {
+	type result ListUnspentResult
+	return unmarshalAmounts(data, (*result)(r), &r.ListUnspentResult)
+}
+
 // FutureListUnspentResult is a future promise to deliver the result of a
 // ListUnspentAsync, ListUnspentMinAsync, ListUnspentMinMaxAsync, or
 // ListUnspentMinMaxAddressesAsync RPC invocation (or an applicable error).
@@ -150,14 +216,14 @@ type FutureListUnspentResult chan *response
 // future wac returnd by a call to ListUnspentMinAsync, ListUnspentMinMaxAsync,
 // or ListUnspentMinMaxAddressesAsync, the range may be limited by the
 // parameters of the RPC invocation.
-func (r FutureListUnspentResult) Receive() ([]btcjson.ListUnspentResult, error) {
+func (r FutureListUnspentResult) Receive() ([]ListUnspentResult, error) {
 	res, err := receiveFuture(r)
 	if err != nil {
 		return nil, err
 	}
 
 	// Unmarshal result as an array of listunspent results.
-	var unspent []btcjson.ListUnspentResult
+	var unspent []ListUnspentResult
 	err = json.Unmarshal(res, &unspent)
 	if err != nil {
 		return nil, err
@@ -214,31 +280,46 @@ func (c *Client) ListUnspentMinMaxAddressesAsync(minConf, maxConf int, addrs []b
 // ListUnspent returns all unspent transaction outputs known to a wallet, using
 // the default number of minimum and maximum number of confirmations as a
 // filter (1 and 999999, respectively).
-func (c *Client) ListUnspent() ([]btcjson.ListUnspentResult, error) {
+func (c *Client) ListUnspent() ([]ListUnspentResult, error) {
 	return c.ListUnspentAsync().Receive()
 }
 
 // ListUnspentMin returns all unspent transaction outputs known to a wallet,
 // using the specified number of minimum conformations and default number of
 // maximum confiramtions (999999) as a filter.
-func (c *Client) ListUnspentMin(minConf int) ([]btcjson.ListUnspentResult, error) {
+func (c *Client) ListUnspentMin(minConf int) ([]ListUnspentResult, error) {
 	return c.ListUnspentMinAsync(minConf).Receive()
 }
 
 // ListUnspentMinMax returns all unspent transaction outputs known to a wallet,
 // using the specified number of minimum and maximum number of confirmations as
 // a filter.
-func (c *Client) ListUnspentMinMax(minConf, maxConf int) ([]btcjson.ListUnspentResult, error) {
+func (c *Client) ListUnspentMinMax(minConf, maxConf int) ([]ListUnspentResult, error) {
 	return c.ListUnspentMinMaxAsync(minConf, maxConf).Receive()
 }
 
 // ListUnspentMinMaxAddresses returns all unspent transaction outputs that pay
 // to any of specified addresses in a wallet using the specified number of
 // minimum and maximum number of confirmations as a filter.
-func (c *Client) ListUnspentMinMaxAddresses(minConf, maxConf int, addrs []btcutil.Address) ([]btcjson.ListUnspentResult, error) {
+func (c *Client) ListUnspentMinMaxAddresses(minConf, maxConf int, addrs []btcutil.Address) ([]ListUnspentResult, error) {
 	return c.ListUnspentMinMaxAddressesAsync(minConf, maxConf, addrs).Receive()
 }
 
+// ListSinceBlockResult models the data from the listsinceblock command, with
+// the amounts of the transactions decoded exactly.  The transactions of the
+// embedded result are decoded as well for compatibility.
+type ListSinceBlockResult struct {
+	btcjson.ListSinceBlockResult
+	Transactions []ListTransactionsResult `json:"transactions"`
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface and unmarshals both
+// the exact transactions and the embedded result.
+func (r *ListSinceBlockResult) UnmarshalJSON(data []byte) error {
+	type result ListSinceBlockResult
+	return unmarshalAmounts(data, (*result)(r), &r.ListSinceBlockResult)
+}
+
 // FutureListSinceBlockResult is a future promise to deliver the result of a
 // ListSinceBlockAsync or ListSinceBlockMinConfAsync RPC invocation (or an
 // applicable error).
@@ -247,14 +328,14 @@ type FutureListSinceBlockResult chan *response
 // Receive waits for the response promised by the future and returns all
 // transactions added in blocks since the specified block hash, or all
 // transactions if it is nil.
-func (r FutureListSinceBlockResult) Receive() (*btcjson.ListSinceBlockResult, error) {
+func (r FutureListSinceBlockResult) Receive() (*ListSinceBlockResult, error) {
 	res, err := receiveFuture(r)
 	if err != nil {
 		return nil, err
 	}
 
 	// Unmarshal result as a listsinceblock result object.
-	var listResult btcjson.ListSinceBlockResult
+	var listResult ListSinceBlockResult
 	err = json.Unmarshal(res, &listResult)
 	if err != nil {
 		return nil, err
@@ -283,7 +364,7 @@ func (c *Client) ListSinceBlockAsync(blockHash *wire.ShaHash) FutureListSinceBlo
 // minimum confirmations as a filter.
 //
 // See ListSinceBlockMinConf to override the minimum number of confirmations.
-func (c *Client) ListSinceBlock(blockHash *wire.ShaHash) (*btcjson.ListSinceBlockResult, error) {
+func (c *Client) ListSinceBlock(blockHash *wire.ShaHash) (*ListSinceBlockResult, error) {
 	return c.ListSinceBlockAsync(blockHash).Receive()
 }
 
@@ -307,7 +388,7 @@ func (c *Client) ListSinceBlockMinConfAsync(blockHash *wire.ShaHash, minConfirms
 // number of minimum confirmations as a filter.
 //
 // See ListSinceBlock to use the default minimum number of confirmations.
-func (c *Client) ListSinceBlockMinConf(blockHash *wire.ShaHash, minConfirms int) (*btcjson.ListSinceBlockResult, error) {
+func (c *Client) ListSinceBlockMinConf(blockHash *wire.ShaHash, minConfirms int) (*ListSinceBlockResult, error) {
 	return c.ListSinceBlockMinConfAsync(blockHash, minConfirms).Receive()
 }
 
@@ -435,7 +516,7 @@ func (r FutureSetTxFeeResult) Receive() error {
 //
 // See SetTxFee for the blocking version and more details.
 func (c *Client) SetTxFeeAsync(fee btcutil.Amount) FutureSetTxFeeResult {
-	cmd := btcjson.NewSetTxFeeCmd(fee.ToBTC())
+	cmd := btcjson.NewSetTxFeeCmd(Amount(fee).ToPPC())
 	return c.sendCmd(cmd)
 }
 
@@ -474,7 +555,8 @@ func (r FutureSendToAddressResult) Receive() (*wire.ShaHash, error) {
 // See SendToAddress for the blocking version and more details.
 func (c *Client) SendToAddressAsync(address btcutil.Address, amount btcutil.Amount) FutureSendToAddressResult {
 	addr := address.EncodeAddress()
-	cmd := btcjson.NewSendToAddressCmd(addr, amount.ToBTC(), nil, nil)
+	cmd := btcjson.NewSendToAddressCmd(addr, Amount(amount).ToPPC(), nil,
+		nil)
 	return c.sendCmd(cmd)
 }
 
@@ -500,8 +582,8 @@ func (c *Client) SendToAddressCommentAsync(address btcutil.Address,
 	commentTo string) FutureSendToAddressResult {
 
 	addr := address.EncodeAddress()
-	cmd := btcjson.NewSendToAddressCmd(addr, amount.ToBTC(), &comment,
-		&commentTo)
+	cmd := btcjson.NewSendToAddressCmd(addr, Amount(amount).ToPPC(),
+		&comment, &commentTo)
 	return c.sendCmd(cmd)
 }
 
@@ -553,8 +635,8 @@ func (r FutureSendFromResult) Receive() (*wire.ShaHash, error) {
 // See SendFrom for the blocking version and more details.
 func (c *Client) SendFromAsync(fromAccount string, toAddress btcutil.Address, amount btcutil.Amount) FutureSendFromResult {
 	addr := toAddress.EncodeAddress()
-	cmd := btcjson.NewSendFromCmd(fromAccount, addr, amount.ToBTC(), nil,
-		nil, nil)
+	cmd := btcjson.NewSendFromCmd(fromAccount, addr, Amount(amount).ToPPC(),
+		nil, nil, nil)
 	return c.sendCmd(cmd)
 }
 
@@ -577,7 +659,7 @@ func (c *Client) SendFrom(fromAccount string, toAddress btcutil.Address, amount
 // See SendFromMinConf for the blocking version and more details.
 func (c *Client) SendFromMinConfAsync(fromAccount string, toAddress btcutil.Address, amount btcutil.Amount, minConfirms int) FutureSendFromResult {
 	addr := toAddress.EncodeAddress()
-	cmd := btcjson.NewSendFromCmd(fromAccount, addr, amount.ToBTC(),
+	cmd := btcjson.NewSendFromCmd(fromAccount, addr, Amount(amount).ToPPC(),
 		&minConfirms, nil, nil)
 	return c.sendCmd(cmd)
 }
@@ -606,7 +688,7 @@ func (c *Client) SendFromCommentAsync(fromAccount string,
 	comment, commentTo string) FutureSendFromResult {
 
 	addr := toAddress.EncodeAddress()
-	cmd := btcjson.NewSendFromCmd(fromAccount, addr, amount.ToBTC(),
+	cmd := btcjson.NewSendFromCmd(fromAccount, addr, Amount(amount).ToPPC(),
 		&minConfirms, &comment, &commentTo)
 	return c.sendCmd(cmd)
 }
@@ -662,7 +744,7 @@ func (r FutureSendManyResult) Receive() (*wire.ShaHash, error) {
 func (c *Client) SendManyAsync(fromAccount string, amounts map[btcutil.Address]btcutil.Amount) FutureSendManyResult {
 	convertedAmounts := make(map[string]float64, len(amounts))
 	for addr, amount := range amounts {
-		convertedAmounts[addr.EncodeAddress()] = amount.ToBTC()
+		convertedAmounts[addr.EncodeAddress()] = Amount(amount).ToPPC()
 	}
 	cmd := btcjson.NewSendManyCmd(fromAccount, convertedAmounts, nil, nil)
 	return c.sendCmd(cmd)
@@ -691,7 +773,7 @@ func (c *Client) SendManyMinConfAsync(fromAccount string,
 
 	convertedAmounts := make(map[string]float64, len(amounts))
 	for addr, amount := range amounts {
-		convertedAmounts[addr.EncodeAddress()] = amount.ToBTC()
+		convertedAmounts[addr.EncodeAddress()] = Amount(amount).ToPPC()
 	}
 	cmd := btcjson.NewSendManyCmd(fromAccount, convertedAmounts,
 		&minConfirms, nil)
@@ -725,7 +807,7 @@ func (c *Client) SendManyCommentAsync(fromAccount string,
 
 	convertedAmounts := make(map[string]float64, len(amounts))
 	for addr, amount := range amounts {
-		convertedAmounts[addr.EncodeAddress()] = amount.ToBTC()
+		convertedAmounts[addr.EncodeAddress()] = Amount(amount).ToPPC()
 	}
 	cmd := btcjson.NewSendManyCmd(fromAccount, convertedAmounts,
 		&minConfirms, &comment)
@@ -1134,8 +1216,8 @@ func (r FutureMoveResult) Receive() (bool, error) {
 //
 // See Move for the blocking version and more details.
 func (c *Client) MoveAsync(fromAccount, toAccount string, amount btcutil.Amount) FutureMoveResult {
-	cmd := btcjson.NewMoveCmd(fromAccount, toAccount, amount.ToBTC(), nil,
-		nil)
+	cmd := btcjson.NewMoveCmd(fromAccount, toAccount, Amount(amount).ToPPC(),
+		nil, nil)
 	return c.sendCmd(cmd)
 }
 
@@ -1155,7 +1237,7 @@ func (c *Client) Move(fromAccount, toAccount string, amount btcutil.Amount) (boo
 func (c *Client) MoveMinConfAsync(fromAccount, toAccount string,
 	amount btcutil.Amount, minConfirms int) FutureMoveResult {
 
-	cmd := btcjson.NewMoveCmd(fromAccount, toAccount, amount.ToBTC(),
+	cmd := btcjson.NewMoveCmd(fromAccount, toAccount, Amount(amount).ToPPC(),
 		&minConfirms, nil)
 	return c.sendCmd(cmd)
 }
@@ -1178,7 +1260,7 @@ func (c *Client) MoveMinConf(fromAccount, toAccount string, amount btcutil.Amoun
 func (c *Client) MoveCommentAsync(fromAccount, toAccount string,
 	amount btcutil.Amount, minConfirms int, comment string) FutureMoveResult {
 
-	cmd := btcjson.NewMoveCmd(fromAccount, toAccount, amount.ToBTC(),
+	cmd := btcjson.NewMoveCmd(fromAccount, toAccount, Amount(amount).ToPPC(),
 		&minConfirms, &comment)
 	return c.sendCmd(cmd)
 }
@@ -1330,7 +1412,7 @@ func (r FutureListAccountsResult) Receive() (map[string]btcutil.Amount, error) {
 	}
 
 	// Unmarshal result as a json object.
-	var accounts map[string]float64
+	var accounts map[string]Amount
 	err = json.Unmarshal(res, &accounts)
 	if err != nil {
 		return nil, err
@@ -1338,12 +1420,7 @@ func (r FutureListAccountsResult) Receive() (map[string]btcutil.Amount, error) {
 
 	accountsMap := make(map[string]btcutil.Amount)
 	for k, v := range accounts {
-		amount, err := btcutil.NewAmount(v)
-		if err != nil {
-			return nil, err
-		}
-
-		accountsMap[k] = amount
+		accountsMap[k] = btcutil.Amount(v)
 	}
 
 	return accountsMap, nil
@@ -1398,19 +1475,14 @@ func (r FutureGetBalanceResult) Receive() (btcutil.Amount, error) {
 		return 0, err
 	}
 
-	// Unmarshal result as a floating point number.
-	var balance float64
-	err = json.Unmarshal(res, &balance)
+	// Unmarshal result as an exact amount.
+	var amount Amount
+	err = json.Unmarshal(res, &amount)
 	if err != nil {
 		return 0, err
 	}
 
-	amount, err := btcutil.NewAmount(balance)
-	if err != nil {
-		return 0, err
-	}
-
-	return amount, nil
+	return btcutil.Amount(amount), nil
 }
 
 // GetBalanceAsync returns an instance of a type that can be used to get the
@@ -1435,16 +1507,12 @@ func (r FutureGetBalanceParseResult) Receive() (btcutil.Amount, error) {
 		return 0, err
 	}
 
-	balance, err := strconv.ParseFloat(balanceString, 64)
-	if err != nil {
-		return 0, err
-	}
-	amount, err := btcutil.NewAmount(balance)
+	amount, err := ParseAmount(balanceString, AmountPPC)
 	if err != nil {
 		return 0, err
 	}
 
-	return amount, nil
+	return btcutil.Amount(amount), nil
 }
 
 // GetBalanceAsync returns an instance of a type that can be used to get the
@@ -1502,19 +1570,14 @@ func (r FutureGetReceivedByAccountResult) Receive() (btcutil.Amount, error) {
 		return 0, err
 	}
 
-	// Unmarshal result as a floating point number.
-	var balance float64
-	err = json.Unmarshal(res, &balance)
+	// Unmarshal result as an exact amount.
+	var amount Amount
+	err = json.Unmarshal(res, &amount)
 	if err != nil {
 		return 0, err
 	}
 
-	amount, err := btcutil.NewAmount(balance)
-	if err != nil {
-		return 0, err
-	}
-
-	return amount, nil
+	return btcutil.Amount(amount), nil
 }
 
 // GetReceivedByAccountAsync returns an instance of a type that can be used to
@@ -1567,19 +1630,14 @@ func (r FutureGetUnconfirmedBalanceResult) Receive() (btcutil.Amount, error) {
 		return 0, err
 	}
 
-	// Unmarshal result as a floating point number.
-	var balance float64
-	err = json.Unmarshal(res, &balance)
+	// Unmarshal result as an exact amount.
+	var amount Amount
+	err = json.Unmarshal(res, &amount)
 	if err != nil {
 		return 0, err
 	}
 
-	amount, err := btcutil.NewAmount(balance)
-	if err != nil {
-		return 0, err
-	}
-
-	return amount, nil
+	return btcutil.Amount(amount), nil
 }
 
 // GetUnconfirmedBalanceAsync returns an instance of a type that can be used to
@@ -1611,19 +1669,14 @@ func (r FutureGetReceivedByAddressResult) Receive() (btcutil.Amount, error) {
 		return 0, err
 	}
 
-	// Unmarshal result as a floating point number.
-	var balance float64
-	err = json.Unmarshal(res, &balance)
+	// Unmarshal result as an exact amount.
+	var amount Amount
+	err = json.Unmarshal(res, &amount)
 	if err != nil {
 		return 0, err
 	}
 
-	amount, err := btcutil.NewAmount(balance)
-	if err != nil {
-		return 0, err
-	}
-
-	return amount, nil
+	return btcutil.Amount(amount), nil
 }
 
 // GetReceivedByAddressAsync returns an instance of a type that can be used to
@@ -1666,6 +1719,21 @@ func (c *Client) GetReceivedByAddressMinConf(address btcutil.Address, minConfirm
 	return c.GetReceivedByAddressMinConfAsync(address, minConfirms).Receive()
 }
 
+// ListReceivedByAccountResult models the data of an account returned by the
+// listreceivedbyaccount command, with the amount received decoded exactly.  The
+// float64 Amount of the embedded result is decoded as well for compatibility.
+type ListReceivedByAccountResult struct {
+	btcjson.ListReceivedByAccountResult
+	Amount Amount `json:"amount"`
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface and unmarshals both
+// the exact amount and the embedded result.
+func (r *ListReceivedByAccountResult) UnmarshalJSON(data []byte) error {
+	type result ListReceivedByAccountResult
+	return unmarshalAmounts(data, (*result)(r), &r.ListReceivedByAccountResult)
+}
+
 // FutureListReceivedByAccountResult is a future promise to deliver the result
 // of a ListReceivedByAccountAsync, ListReceivedByAccountMinConfAsync, or
 // ListReceivedByAccountIncludeEmptyAsync RPC invocation (or an applicable
@@ -1674,14 +1742,14 @@ type FutureListReceivedByAccountResult chan *response
 
 // Receive waits for the response promised by the future and returns a list of
 // balances by account.
-func (r FutureListReceivedByAccountResult) Receive() ([]btcjson.ListReceivedByAccountResult, error) {
+func (r FutureListReceivedByAccountResult) Receive() ([]ListReceivedByAccountResult, error) {
 	res, err := receiveFuture(r)
 	if err != nil {
 		return nil, err
 	}
 
 	// Unmarshal as an array of listreceivedbyaccount result objects.
-	var received []btcjson.ListReceivedByAccountResult
+	var received []ListReceivedByAccountResult
 	err = json.Unmarshal(res, &received)
 	if err != nil {
 		return nil, err
@@ -1707,7 +1775,7 @@ func (c *Client) ListReceivedByAccountAsync() FutureListReceivedByAccountResult
 // See ListReceivedByAccountMinConf to override the minimum number of
 // confirmations and ListReceivedByAccountIncludeEmpty to filter accounts that
 // haven't received any payments from the results.
-func (c *Client) ListReceivedByAccount() ([]btcjson.ListReceivedByAccountResult, error) {
+func (c *Client) ListReceivedByAccount() ([]ListReceivedByAccountResult, error) {
 	return c.ListReceivedByAccountAsync().Receive()
 }
 
@@ -1728,7 +1796,7 @@ func (c *Client) ListReceivedByAccountMinConfAsync(minConfirms int) FutureListRe
 // See ListReceivedByAccount to use the default minimum number of confirmations
 // and ListReceivedByAccountIncludeEmpty to also filter accounts that haven't
 // received any payments from the results.
-func (c *Client) ListReceivedByAccountMinConf(minConfirms int) ([]btcjson.ListReceivedByAccountResult, error) {
+func (c *Client) ListReceivedByAccountMinConf(minConfirms int) ([]ListReceivedByAccountResult, error) {
 	return c.ListReceivedByAccountMinConfAsync(minConfirms).Receive()
 }
 
@@ -1748,7 +1816,7 @@ func (c *Client) ListReceivedByAccountIncludeEmptyAsync(minConfirms int, include
 // haven't received any payments depending on specified flag.
 //
 // See ListReceivedByAccount and ListReceivedByAccountMinConf to use defaults.
-func (c *Client) ListReceivedByAccountIncludeEmpty(minConfirms int, includeEmpty bool) ([]btcjson.ListReceivedByAccountResult, error) {
+func (c *Client) ListReceivedByAccountIncludeEmpty(minConfirms int, includeEmpty bool) ([]ListReceivedByAccountResult, error) {
 	return c.ListReceivedByAccountIncludeEmptyAsync(minConfirms,
 		includeEmpty).Receive()
 }
@@ -1757,19 +1825,34 @@ func (c *Client) ListReceivedByAccountIncludeEmpty(minConfirms int, includeEmpty
 // Wallet Locking Functions
 // ************************
 
+// ListReceivedByAddressResult models the data of an address returned by the
+// listreceivedbyaddress command, with the amount received decoded exactly.  The
+// float64 Amount of the embedded result is decoded as well for compatibility.
+type ListReceivedByAddressResult struct {
+	btcjson.ListReceivedByAddressResult
+	Amount Amount `json:"amount"`
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface and unmarshals both
+// the exact amount and the embedded result.
+func (r *ListReceivedByAddressResult) UnmarshalJSON(data []byte) error {
+	type result ListReceivedByAddressResult
+	return unmarshalAmounts(data, (*result)(r), &r.ListReceivedByAddressResult)
+}
+
 type FutureListReceivedByAddressResult chan *response
 
 // FutureWalletLockResult is a future promise to deliver the result of a
 // WalletLockAsync RPC invocation (or an applicable error).
 // balances by address.
-func (r FutureListReceivedByAddressResult) Receive() ([]btcjson.ListReceivedByAddressResult, error) {
+func (r FutureListReceivedByAddressResult) Receive() ([]ListReceivedByAddressResult, error) {
 	res, err := receiveFuture(r)
 	if err != nil {
 		return nil, err
 	}
 
 	// Unmarshal as an array of listreceivedbyaddress result objects.
-	var received []btcjson.ListReceivedByAddressResult
+	var received []ListReceivedByAddressResult
 	err = json.Unmarshal(res, &received)
 	if err != nil {
 		return nil, err
@@ -1795,7 +1878,7 @@ func (c *Client) ListReceivedByAddressAsync() FutureListReceivedByAddressResult
 // See ListReceivedByAddressMinConf to override the minimum number of
 // confirmations and ListReceivedByAddressIncludeEmpty to also include addresses
 // that haven't received any payments in the results.
-func (c *Client) ListReceivedByAddress() ([]btcjson.ListReceivedByAddressResult, error) {
+func (c *Client) ListReceivedByAddress() ([]ListReceivedByAddressResult, error) {
 	return c.ListReceivedByAddressAsync().Receive()
 }
 
@@ -1816,7 +1899,7 @@ func (c *Client) ListReceivedByAddressMinConfAsync(minConfirms int) FutureListRe
 // See ListReceivedByAddress to use the default minimum number of confirmations
 // and ListReceivedByAddressIncludeEmpty to also include addresses that haven't
 // received any payments in the results.
-func (c *Client) ListReceivedByAddressMinConf(minConfirms int) ([]btcjson.ListReceivedByAddressResult, error) {
+func (c *Client) ListReceivedByAddressMinConf(minConfirms int) ([]ListReceivedByAddressResult, error) {
 	return c.ListReceivedByAddressMinConfAsync(minConfirms).Receive()
 }
 
@@ -1836,7 +1919,7 @@ func (c *Client) ListReceivedByAddressIncludeEmptyAsync(minConfirms int, include
 // haven't received any payments depending on specified flag.
 //
 // See ListReceivedByAddress and ListReceivedByAddressMinConf to use defaults.
-func (c *Client) ListReceivedByAddressIncludeEmpty(minConfirms int, includeEmpty bool) ([]btcjson.ListReceivedByAddressResult, error) {
+func (c *Client) ListReceivedByAddressIncludeEmpty(minConfirms int, includeEmpty bool) ([]ListReceivedByAddressResult, error) {
 	return c.ListReceivedByAddressIncludeEmptyAsync(minConfirms,
 		includeEmpty).Receive()
 }
@@ -0,0 +1,104 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+// maxWatchedEntries is the maximum number of addresses or outpoints tracked as
+// watched by the server without being part of the registered notification
+// state.  Once it is reached, the least recently added entries are forgotten,
+// which only means detailed transaction notifications no longer report them
+// as matched.
+const maxWatchedEntries = 100000
+
+// watchedEntry houses a key of a watchedSet along with the sequence number it
+// was added with.
+type watchedEntry struct {
+	key interface{}
+	seq uint64
+}
+
+// watchedSet is a set of addresses or outpoints bounded to a maximum number of
+// entries.  The least recently added entries are evicted first.  It is not safe
+// for concurrent access.
+type watchedSet struct {
+	entries map[interface{}]uint64
+	order   []watchedEntry
+	seq     uint64
+	max     int
+}
+
+// newWatchedSet returns a new empty set which holds at most max entries.
+func newWatchedSet(max int) *watchedSet {
+	return &watchedSet{
+		entries: make(map[interface{}]uint64),
+		max:     max,
+	}
+}
+
+// add adds the passed key to the set, evicting the least recently added key
+// when the set is full.  Adding a key which is already in the set makes it the
+// most recently added one.
+func (s *watchedSet) add(key interface{}) {
+	s.seq++
+	s.entries[key] = s.seq
+	s.order = append(s.order, watchedEntry{key: key, seq: s.seq})
+
+	for len(s.entries) > s.max {
+		oldest := s.order[0]
+		s.order = s.order[1:]
+		if seq, ok := s.entries[oldest.key]; ok && seq == oldest.seq {
+			delete(s.entries, oldest.key)
+		}
+	}
+
+	// The order also holds entries for keys which were removed or added
+	// again since, so compact it once they make up most of it.
+	if len(s.order) > 2*len(s.entries)+s.max/2 {
+		s.compact()
+	}
+}
+
+// remove removes the passed key from the set.
+func (s *watchedSet) remove(key interface{}) {
+	delete(s.entries, key)
+}
+
+// contains returns whether or not the passed key is in the set.
+func (s *watchedSet) contains(key interface{}) bool {
+	_, ok := s.entries[key]
+	return ok
+}
+
+// len returns the number of keys in the set.
+func (s *watchedSet) len() int {
+	return len(s.entries)
+}
+
+// compact drops the entries of the order which no longer refer to the current
+// entries of the set.
+func (s *watchedSet) compact() {
+	order := make([]watchedEntry, 0, len(s.entries))
+	for _, entry := range s.order {
+		if seq, ok := s.entries[entry.key]; ok && seq == entry.seq {
+			order = append(order, entry)
+		}
+	}
+	s.order = order
+}
+
+// copy returns a deep copy of the set.
+func (s *watchedSet) copy() *watchedSet {
+	s.compact()
+	setCopy := &watchedSet{
+		entries: make(map[interface{}]uint64, len(s.entries)),
+		order:   make([]watchedEntry, len(s.order)),
+		seq:     s.seq,
+		max:     s.max,
+	}
+	for key, seq := range s.entries {
+		setCopy.entries[key] = seq
+	}
+	copy(setCopy.order, s.order)
+	return setCopy
+}
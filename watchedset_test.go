@@ -0,0 +1,97 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestWatchedSet ensures the bounded set of watched addresses and outpoints
+// evicts the least recently added keys and keeps its order compact.
+func TestWatchedSet(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		max     int
+		add     []string
+		remove  []string
+		present []string
+		absent  []string
+	}{
+		{
+			name:    "below bound",
+			max:     3,
+			add:     []string{"a", "b", "c"},
+			present: []string{"a", "b", "c"},
+		},
+		{
+			name:    "evicts oldest",
+			max:     2,
+			add:     []string{"a", "b", "c"},
+			present: []string{"b", "c"},
+			absent:  []string{"a"},
+		},
+		{
+			name:    "re-add refreshes",
+			max:     2,
+			add:     []string{"a", "b", "a", "c"},
+			present: []string{"a", "c"},
+			absent:  []string{"b"},
+		},
+		{
+			name:    "removed keys free space",
+			max:     2,
+			add:     []string{"a", "b"},
+			remove:  []string{"a"},
+			present: []string{"b"},
+			absent:  []string{"a"},
+		},
+	}
+
+	for _, test := range tests {
+		s := newWatchedSet(test.max)
+		for _, key := range test.add {
+			s.add(key)
+		}
+		for _, key := range test.remove {
+			s.remove(key)
+		}
+		for _, key := range test.present {
+			if !s.contains(key) {
+				t.Errorf("%s: missing key %q", test.name, key)
+			}
+		}
+		for _, key := range test.absent {
+			if s.contains(key) {
+				t.Errorf("%s: unexpected key %q", test.name, key)
+			}
+		}
+		if s.len() > test.max {
+			t.Errorf("%s: %d keys exceed bound of %d", test.name,
+				s.len(), test.max)
+		}
+	}
+
+	// Adding and removing many keys must not grow the order unbounded.
+	s := newWatchedSet(10)
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprint(i)
+		s.add(key)
+		s.remove(key)
+	}
+	if len(s.order) > 2*s.len()+s.max/2+1 {
+		t.Errorf("order not compacted: %d entries", len(s.order))
+	}
+
+	// Copies must be independent of the original.
+	s.add("x")
+	sCopy := s.copy()
+	s.remove("x")
+	if !sCopy.contains("x") {
+		t.Error("copy changed with the original")
+	}
+}
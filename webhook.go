@@ -0,0 +1,714 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ppcsuite/ppcd/btcjson"
+)
+
+const (
+	// WebhookBlockConnected is the event name of the webhooks delivered
+	// for block connected notifications.
+	WebhookBlockConnected = "blockconnected"
+
+	// WebhookRecvTx is the event name of the webhooks delivered for recvtx
+	// notifications.
+	WebhookRecvTx = "recvtx"
+
+	// WebhookAccountBalance is the event name of the webhooks delivered
+	// for accountbalance notifications.
+	WebhookAccountBalance = "accountbalance"
+
+	// WebhookWalletLockState is the event name of the webhooks delivered
+	// for walletlockstate notifications.
+	WebhookWalletLockState = "walletlockstate"
+)
+
+const (
+	// WebhookSignatureHeader is the HTTP header which carries the
+	// hex-encoded HMAC-SHA256 signature of the request body, prefixed with
+	// "sha256=", when the endpoint has a secret.
+	WebhookSignatureHeader = "X-Webhook-Signature"
+
+	// WebhookEventHeader is the HTTP header which carries the event name.
+	WebhookEventHeader = "X-Webhook-Event"
+
+	// WebhookIDHeader is the HTTP header which carries the unique id of the
+	// event, which is the same across retries so receivers can detect
+	// duplicate deliveries.
+	WebhookIDHeader = "X-Webhook-Id"
+
+	// defaultWebhookInitialBackoff and defaultWebhookMaxBackoff are the
+	// delays used between delivery attempts when none are configured.
+	defaultWebhookInitialBackoff = time.Second
+	defaultWebhookMaxBackoff     = 5 * time.Minute
+
+	// defaultWebhookMaxOutboxSize is the maximum number of webhooks kept
+	// in the outbox of an endpoint when none is configured.
+	defaultWebhookMaxOutboxSize = 10000
+
+	// webhookRequestTimeout is the timeout of the delivery requests issued
+	// with the default HTTP client.
+	webhookRequestTimeout = 30 * time.Second
+
+	// webhookOutboxExt is the file extension of the deliveries stored in
+	// the outbox.
+	webhookOutboxExt = ".json"
+)
+
+// ErrNoWebhookEndpoints is an error to describe the condition where a webhook
+// dispatcher is created without any endpoints.
+var ErrNoWebhookEndpoints = errors.New("no webhook endpoints configured")
+
+// WebhookEndpoint describes a URL webhooks are delivered to.
+type WebhookEndpoint struct {
+	// URL is the URL the webhooks are posted to.
+	URL string
+
+	// Secret is the key used to sign the webhooks with HMAC-SHA256.  The
+	// webhooks are not signed when it is empty.
+	Secret []byte
+
+	// Events are the names of the events delivered to the endpoint.  All
+	// events are delivered when it is empty.
+	Events []string
+}
+
+// WebhookConfig describes the configuration of a WebhookDispatcher.
+type WebhookConfig struct {
+	// Endpoints are the endpoints the webhooks are delivered to.
+	Endpoints []WebhookEndpoint
+
+	// OutboxDir is the directory in which the webhooks are stored until
+	// they are delivered, so they are not lost when the process exits.  A
+	// separate subdirectory is used for each endpoint.  The webhooks are
+	// only kept in memory when it is empty.
+	OutboxDir string
+
+	// MaxOutboxSize is the maximum number of webhooks kept in the outbox
+	// of each endpoint.  The oldest webhooks are dropped to make room for
+	// new ones once it is reached, such as when an endpoint is down for a
+	// long time.  It defaults to 10000 when it is not positive.
+	MaxOutboxSize int
+
+	// MaxAttempts is the number of delivery attempts after which a webhook
+	// is dropped.  Delivery is retried until it succeeds when it is not
+	// positive.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry, which doubles
+	// after every failed attempt up to MaxBackoff.  They default to one
+	// second and five minutes respectively.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// HTTPClient is the client used to deliver the webhooks.  A client
+	// with a 30 second timeout is used when it is nil.
+	HTTPClient *http.Client
+}
+
+// WebhookPayload is the JSON body of a webhook.  Data holds the event
+// specific details, which are a WebhookBlockConnectedData,
+// WebhookRecvTxData, WebhookAccountBalanceData, or WebhookWalletLockStateData
+// depending on the event.
+type WebhookPayload struct {
+	ID    string      `json:"id"`
+	Event string      `json:"event"`
+	Time  int64       `json:"time"`
+	Data  interface{} `json:"data"`
+}
+
+// WebhookBlockConnectedData houses the details of a blockconnected webhook.
+type WebhookBlockConnectedData struct {
+	Hash   string `json:"hash"`
+	Height int32  `json:"height"`
+	Time   int64  `json:"time"`
+}
+
+// WebhookRecvTxData houses the details of a recvtx webhook.  Block is nil for
+// transactions in the memory pool.
+type WebhookRecvTxData struct {
+	TxID  string                `json:"txid"`
+	Hex   string                `json:"hex"`
+	Block *btcjson.BlockDetails `json:"block,omitempty"`
+}
+
+// WebhookAccountBalanceData houses the details of an accountbalance webhook.
+type WebhookAccountBalanceData struct {
+	Account   string `json:"account"`
+	Balance   Amount `json:"balance"`
+	Confirmed bool   `json:"confirmed"`
+}
+
+// WebhookWalletLockStateData houses the details of a walletlockstate webhook.
+type WebhookWalletLockStateData struct {
+	Account string `json:"account"`
+	Locked  bool   `json:"locked"`
+}
+
+// SignWebhook returns the value of the WebhookSignatureHeader for the passed
+// request body and secret.  Receivers can use it to verify the signature of a
+// webhook with hmac.Equal.
+func SignWebhook(body, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookDelivery is a webhook waiting to be delivered to an endpoint.
+type webhookDelivery struct {
+	seq   uint64
+	id    string
+	event string
+	body  []byte
+}
+
+// webhookEndpoint houses the outbox of an endpoint.
+type webhookEndpoint struct {
+	WebhookEndpoint
+	events map[string]struct{}
+	dir    string
+	signal chan struct{}
+
+	maxSize int
+
+	mtx     sync.Mutex
+	pending []*webhookDelivery
+	nextSeq uint64
+	dropped uint64
+}
+
+// wants returns whether or not the passed event is delivered to the endpoint.
+func (e *webhookEndpoint) wants(event string) bool {
+	if len(e.events) == 0 {
+		return true
+	}
+	_, ok := e.events[event]
+	return ok
+}
+
+// outboxPath returns the path of the file storing the passed delivery.
+func (e *webhookEndpoint) outboxPath(d *webhookDelivery) string {
+	return filepath.Join(e.dir, fmt.Sprintf("%020d%s", d.seq,
+		webhookOutboxExt))
+}
+
+// loadOutbox loads the deliveries stored in the outbox directory of the
+// endpoint, creating it when it does not exist.
+func (e *webhookEndpoint) loadOutbox() error {
+	if err := os.MkdirAll(e.dir, 0700); err != nil {
+		return err
+	}
+	files, err := ioutil.ReadDir(e.dir)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(files))
+	for _, fi := range files {
+		if !fi.IsDir() && strings.HasSuffix(fi.Name(), webhookOutboxExt) {
+			names = append(names, fi.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name,
+			webhookOutboxExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		body, err := ioutil.ReadFile(filepath.Join(e.dir, name))
+		if err != nil {
+			return err
+		}
+		var payload WebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			log.Warnf("Ignoring invalid webhook %s: %v",
+				filepath.Join(e.dir, name), err)
+			continue
+		}
+		e.pending = append(e.pending, &webhookDelivery{
+			seq:   seq,
+			id:    payload.ID,
+			event: payload.Event,
+			body:  body,
+		})
+		if seq >= e.nextSeq {
+			e.nextSeq = seq + 1
+		}
+	}
+	e.discard(e.dropOldest(e.maxSize))
+	return nil
+}
+
+// dropOldest removes the oldest pending deliveries of the endpoint so no more
+// than the passed number remain, and returns them.  It must be called with the
+// mutex held.
+func (e *webhookEndpoint) dropOldest(maxPending int) []*webhookDelivery {
+	if len(e.pending) <= maxPending {
+		return nil
+	}
+	n := len(e.pending) - maxPending
+	dropped := make([]*webhookDelivery, n)
+	copy(dropped, e.pending[:n])
+	e.pending = e.pending[n:]
+	e.dropped += uint64(n)
+	return dropped
+}
+
+// discard removes the passed deliveries, which were dropped from the outbox
+// since it was full, from the outbox directory and logs them.
+func (e *webhookEndpoint) discard(dropped []*webhookDelivery) {
+	if len(dropped) == 0 {
+		return
+	}
+	e.mtx.Lock()
+	total := e.dropped
+	e.mtx.Unlock()
+	log.Warnf("Dropped %d webhooks to %s since its outbox is full (%d "+
+		"dropped in total)", len(dropped), e.URL, total)
+
+	if e.dir == "" {
+		return
+	}
+	for _, d := range dropped {
+		err := os.Remove(e.outboxPath(d))
+		if err != nil && !os.IsNotExist(err) {
+			log.Errorf("Unable to remove dropped webhook %s: %v",
+				d.id, err)
+		}
+	}
+}
+
+// add stores the passed webhook in the outbox of the endpoint and signals the
+// endpoint goroutine.  The oldest webhooks are dropped when the outbox is full.
+//
+// This function is safe for concurrent access.
+func (e *webhookEndpoint) add(id, event string, body []byte) {
+	e.mtx.Lock()
+	dropped := e.dropOldest(e.maxSize - 1)
+	d := &webhookDelivery{seq: e.nextSeq, id: id, event: event, body: body}
+	e.nextSeq++
+
+	// Store the webhook before it becomes visible to the endpoint
+	// goroutine so a delivered webhook is never stored afterwards.
+	if e.dir != "" {
+		if err := writeFileAtomic(e.outboxPath(d), body); err != nil {
+			log.Errorf("Unable to store webhook %s: %v", id, err)
+		}
+	}
+	e.pending = append(e.pending, d)
+	e.mtx.Unlock()
+	e.discard(dropped)
+
+	select {
+	case e.signal <- struct{}{}:
+	default:
+	}
+}
+
+// next returns the oldest pending delivery of the endpoint, if any.
+//
+// This function is safe for concurrent access.
+func (e *webhookEndpoint) next() *webhookDelivery {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if len(e.pending) == 0 {
+		return nil
+	}
+	return e.pending[0]
+}
+
+// remove removes the passed delivery from the outbox of the endpoint unless it
+// was dropped in the meantime.
+//
+// This function is safe for concurrent access.
+func (e *webhookEndpoint) remove(d *webhookDelivery) {
+	e.mtx.Lock()
+	for i, pending := range e.pending {
+		if pending == d {
+			e.pending = append(e.pending[:i], e.pending[i+1:]...)
+			break
+		}
+	}
+	e.mtx.Unlock()
+
+	if e.dir != "" {
+		err := os.Remove(e.outboxPath(d))
+		if err != nil && !os.IsNotExist(err) {
+			log.Errorf("Unable to remove delivered webhook %s: %v",
+				d.id, err)
+		}
+	}
+}
+
+// WebhookDispatcher delivers the block connected, recvtx, account balance, and
+// wallet lock state notifications received by a client as HTTP POST requests
+// to a set of endpoints, which allows services which are not written in Go to
+// react to them.
+//
+// Each webhook is stored in the outbox of every endpoint which wants its event
+// and delivered to the endpoints independently and in order.  A delivery
+// succeeds when the endpoint responds with a 2xx status code.  Otherwise it is
+// retried with exponential backoff, and the webhooks following it wait.  The
+// outboxes are bounded, so the oldest webhooks are dropped while an endpoint is
+// unreachable for too long.  When an outbox directory is configured, the
+// webhooks which have not been delivered when the dispatcher is stopped are
+// delivered by the next dispatcher using the same directory.
+//
+// The dispatcher only re-publishes notifications, so the client must register
+// for them as usual, such as with NotifyBlocks and NotifyReceived.
+type WebhookDispatcher struct {
+	client     *Client
+	config     WebhookConfig
+	httpClient *http.Client
+	endpoints  []*webhookEndpoint
+	queue      *ntfnQueue
+	quit       chan struct{}
+	wg         sync.WaitGroup
+
+	quitOnce sync.Once
+}
+
+// Enforce WebhookDispatcher satisfies the ntfnObserver interface.
+var _ ntfnObserver = (*WebhookDispatcher)(nil)
+
+// NewWebhookDispatcher returns a new dispatcher which delivers the
+// notifications received by the client to the configured endpoints.  The
+// client must have been created with notification handlers, although none of
+// the handlers need to be set.
+func (c *Client) NewWebhookDispatcher(config *WebhookConfig) (*WebhookDispatcher, error) {
+	if c.ntfnHandlers == nil {
+		return nil, ErrNoNotificationHandlers
+	}
+	if len(config.Endpoints) == 0 {
+		return nil, ErrNoWebhookEndpoints
+	}
+
+	d := &WebhookDispatcher{
+		client:     c,
+		config:     *config,
+		httpClient: config.HTTPClient,
+		queue:      newNtfnQueue(),
+		quit:       make(chan struct{}),
+	}
+	if d.httpClient == nil {
+		d.httpClient = &http.Client{Timeout: webhookRequestTimeout}
+	}
+	if d.config.InitialBackoff <= 0 {
+		d.config.InitialBackoff = defaultWebhookInitialBackoff
+	}
+	if d.config.MaxBackoff <= 0 {
+		d.config.MaxBackoff = defaultWebhookMaxBackoff
+	}
+	if d.config.MaxOutboxSize <= 0 {
+		d.config.MaxOutboxSize = defaultWebhookMaxOutboxSize
+	}
+
+	for _, endpoint := range config.Endpoints {
+		e := &webhookEndpoint{
+			WebhookEndpoint: endpoint,
+			events:          make(map[string]struct{}),
+			signal:          make(chan struct{}, 1),
+			maxSize:         d.config.MaxOutboxSize,
+		}
+		for _, event := range endpoint.Events {
+			e.events[event] = struct{}{}
+		}
+
+		// The outbox of each endpoint is named after its URL so it is
+		// found again regardless of the order of the endpoints.
+		if config.OutboxDir != "" {
+			urlHash := sha256.Sum256([]byte(endpoint.URL))
+			e.dir = filepath.Join(config.OutboxDir,
+				hex.EncodeToString(urlHash[:8]))
+			if err := e.loadOutbox(); err != nil {
+				return nil, err
+			}
+		}
+		d.endpoints = append(d.endpoints, e)
+	}
+
+	for _, e := range d.endpoints {
+		d.wg.Add(1)
+		go d.deliverer(e)
+	}
+	c.ntfnObservers.add(d)
+	d.wg.Add(1)
+	go d.handler()
+	return d, nil
+}
+
+// Stop stops delivering webhooks.  A delivery in progress is completed first.
+func (d *WebhookDispatcher) Stop() {
+	d.quitOnce.Do(func() {
+		d.client.ntfnObservers.remove(d)
+		close(d.quit)
+	})
+	d.wg.Wait()
+}
+
+// Pending returns the number of webhooks which have not been delivered yet,
+// summed over all endpoints.
+//
+// This function is safe for concurrent access.
+func (d *WebhookDispatcher) Pending() int {
+	var pending int
+	for _, e := range d.endpoints {
+		e.mtx.Lock()
+		pending += len(e.pending)
+		e.mtx.Unlock()
+	}
+	return pending
+}
+
+// observeNotification queues the notifications which are delivered as webhooks
+// for processing by the dispatcher goroutine.  It is part of the ntfnObserver
+// interface implementation.
+func (d *WebhookDispatcher) observeNotification(ntfn *rawNotification) {
+	switch ntfn.Method {
+	case btcjson.BlockConnectedNtfnMethod, btcjson.RecvTxNtfnMethod,
+		btcjson.AccountBalanceNtfnMethod, btcjson.WalletLockStateNtfnMethod:
+
+		d.queue.push(ntfn)
+	}
+}
+
+// handler converts the queued notifications to webhooks and adds them to the
+// outboxes of the endpoints.  It must be run as a goroutine.
+func (d *WebhookDispatcher) handler() {
+out:
+	for {
+		select {
+		case <-d.queue.signal:
+			// The webhooks of dropped notifications are lost
+			// since the events they describe can not be fetched
+			// again.
+			ntfns, dropped := d.queue.popAll()
+			if dropped > 0 {
+				log.Warnf("Webhook dispatcher dropped %d "+
+					"notifications", dropped)
+			}
+			for _, ntfn := range ntfns {
+				d.dispatch(ntfn)
+			}
+
+		case <-d.quit:
+			break out
+
+		case <-d.client.shutdown:
+			break out
+		}
+	}
+	d.wg.Done()
+}
+
+// newWebhookID returns a new random webhook id.
+func newWebhookID() (string, error) {
+	var id [16]byte
+	if _, err := io.ReadFull(rand.Reader, id[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id[:]), nil
+}
+
+// webhookData returns the event name and details of the webhook for the passed
+// notification.
+func webhookData(ntfn *rawNotification) (string, interface{}, error) {
+	switch ntfn.Method {
+	case btcjson.BlockConnectedNtfnMethod:
+		hash, height, t, err := parseChainNtfnParams(ntfn.Params)
+		if err != nil {
+			return "", nil, err
+		}
+		return WebhookBlockConnected, &WebhookBlockConnectedData{
+			Hash:   hash.String(),
+			Height: height,
+			Time:   t.Unix(),
+		}, nil
+
+	case btcjson.RecvTxNtfnMethod:
+		tx, block, err := parseChainTxNtfnParams(ntfn.Params)
+		if err != nil {
+			return "", nil, err
+		}
+		var buf bytes.Buffer
+		buf.Grow(tx.MsgTx().SerializeSize())
+		if err := tx.MsgTx().Serialize(&buf); err != nil {
+			return "", nil, err
+		}
+		return WebhookRecvTx, &WebhookRecvTxData{
+			TxID:  tx.Sha().String(),
+			Hex:   hex.EncodeToString(buf.Bytes()),
+			Block: block,
+		}, nil
+
+	case btcjson.AccountBalanceNtfnMethod:
+		account, balance, confirmed, err :=
+			parseAccountBalanceNtfnParams(ntfn.Params)
+		if err != nil {
+			return "", nil, err
+		}
+		return WebhookAccountBalance, &WebhookAccountBalanceData{
+			Account:   account,
+			Balance:   Amount(balance),
+			Confirmed: confirmed,
+		}, nil
+
+	case btcjson.WalletLockStateNtfnMethod:
+		account, locked, err := parseWalletLockStateNtfnParams(ntfn.Params)
+		if err != nil {
+			return "", nil, err
+		}
+		return WebhookWalletLockState, &WebhookWalletLockStateData{
+			Account: account,
+			Locked:  locked,
+		}, nil
+	}
+	return "", nil, fmt.Errorf("unsupported notification %s", ntfn.Method)
+}
+
+// dispatch adds the webhook for the passed notification to the outboxes of the
+// endpoints which want it.
+func (d *WebhookDispatcher) dispatch(ntfn *rawNotification) {
+	event, data, err := webhookData(ntfn)
+	if err != nil {
+		log.Warnf("Received invalid %s notification: %v", ntfn.Method,
+			err)
+		return
+	}
+	id, err := newWebhookID()
+	if err != nil {
+		log.Errorf("Unable to create webhook id: %v", err)
+		return
+	}
+	body, err := json.Marshal(&WebhookPayload{
+		ID:    id,
+		Event: event,
+		Time:  time.Now().Unix(),
+		Data:  data,
+	})
+	if err != nil {
+		log.Errorf("Unable to marshal %s webhook: %v", event, err)
+		return
+	}
+
+	for _, e := range d.endpoints {
+		if e.wants(event) {
+			e.add(id, event, body)
+		}
+	}
+}
+
+// deliver posts the passed webhook to the endpoint.
+func (d *WebhookDispatcher) deliver(e *webhookEndpoint, wh *webhookDelivery) error {
+	req, err := http.NewRequest("POST", e.URL, bytes.NewReader(wh.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, wh.event)
+	req.Header.Set(WebhookIDHeader, wh.id)
+	if len(e.Secret) != 0 {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(wh.body,
+			e.Secret))
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+// deliverer delivers the webhooks in the outbox of the passed endpoint in
+// order, retrying failed deliveries with exponential backoff.  It must be run
+// as a goroutine.
+func (d *WebhookDispatcher) deliverer(e *webhookEndpoint) {
+	defer d.wg.Done()
+
+	var last *webhookDelivery
+	attempts := 0
+	backoff := d.config.InitialBackoff
+	for {
+		wh := e.next()
+		if wh == nil {
+			select {
+			case <-e.signal:
+				continue
+			case <-d.quit:
+				return
+			case <-d.client.shutdown:
+				return
+			}
+		}
+
+		// Reset the backoff when moving on to another webhook, which
+		// includes the case where the webhook being retried was
+		// dropped since the outbox is full.
+		if wh != last {
+			last = wh
+			attempts = 0
+			backoff = d.config.InitialBackoff
+		}
+
+		err := d.deliver(e, wh)
+		if err == nil {
+			log.Tracef("Delivered %s webhook %s to %s", wh.event,
+				wh.id, e.URL)
+			e.remove(wh)
+			continue
+		}
+
+		attempts++
+		if d.config.MaxAttempts > 0 && attempts >= d.config.MaxAttempts {
+			log.Errorf("Dropping %s webhook %s to %s after %d "+
+				"attempts: %v", wh.event, wh.id, e.URL, attempts,
+				err)
+			e.remove(wh)
+			continue
+		}
+
+		log.Warnf("Unable to deliver %s webhook %s to %s (attempt %d), "+
+			"retrying in %v: %v", wh.event, wh.id, e.URL, attempts,
+			backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-d.quit:
+			return
+		case <-d.client.shutdown:
+			return
+		}
+		backoff *= 2
+		if backoff > d.config.MaxBackoff {
+			backoff = d.config.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright (c) 2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcrpcclient
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ppcsuite/ppcd/btcjson"
+	"github.com/ppcsuite/ppcd/wire"
+)
+
+// webhookTestTimeout is the time a webhook test waits for expected deliveries.
+const webhookTestTimeout = 5 * time.Second
+
+// webhookTestRequest is a webhook received by a webhookTestReceiver.
+type webhookTestRequest struct {
+	id        string
+	event     string
+	signature string
+	body      []byte
+	status    int
+}
+
+// webhookTestReceiver is an HTTP server which records the webhooks it receives
+// and responds with a configurable status code.
+type webhookTestReceiver struct {
+	server *httptest.Server
+
+	mtx      sync.Mutex
+	statuses []int // status codes of the next responses, then 200
+	requests []webhookTestRequest
+}
+
+// newWebhookTestReceiver starts a new receiver which responds with the passed
+// status codes before responding with 200 to all further requests.
+func newWebhookTestReceiver(statuses ...int) *webhookTestReceiver {
+	r := &webhookTestReceiver{statuses: statuses}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serve))
+	return r
+}
+
+// serve records the passed webhook.
+func (r *webhookTestReceiver) serve(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.mtx.Lock()
+	status := http.StatusOK
+	if len(r.statuses) != 0 {
+		status = r.statuses[0]
+		r.statuses = r.statuses[1:]
+	}
+	r.requests = append(r.requests, webhookTestRequest{
+		id:        req.Header.Get(WebhookIDHeader),
+		event:     req.Header.Get(WebhookEventHeader),
+		signature: req.Header.Get(WebhookSignatureHeader),
+		body:      body,
+		status:    status,
+	})
+	r.mtx.Unlock()
+
+	w.WriteHeader(status)
+}
+
+// waitRequests waits until the receiver received the passed number of
+// webhooks and returns them.
+func (r *webhookTestReceiver) waitRequests(t *testing.T, n int) []webhookTestRequest {
+	deadline := time.Now().Add(webhookTestTimeout)
+	for {
+		r.mtx.Lock()
+		requests := append([]webhookTestRequest(nil), r.requests...)
+		r.mtx.Unlock()
+		if len(requests) >= n {
+			return requests
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d webhooks, want %d", len(requests), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// newWebhookTestClient returns a client which is not connected, but can feed
+// notifications to a webhook dispatcher.
+func newWebhookTestClient() *Client {
+	return &Client{
+		config:        &ConnConfig{},
+		ntfnHandlers:  &NotificationHandlers{},
+		ntfnObservers: newNtfnObservers(),
+		shutdown:      make(chan struct{}),
+	}
+}
+
+// notifyWebhookTestBlock delivers a block connected notification for the
+// passed height to the observers of the passed client.
+func notifyWebhookTestBlock(t *testing.T, c *Client, height int32) {
+	hash := wire.ShaHash{byte(height)}
+	ntfn, err := newRawNotification(btcjson.BlockConnectedNtfnMethod,
+		hash.String(), height, 1400000000)
+	if err != nil {
+		t.Fatalf("newRawNotification: %v", err)
+	}
+	c.ntfnObservers.notify(ntfn)
+}
+
+// webhookTestHeight returns the height of the passed blockconnected webhook.
+func webhookTestHeight(t *testing.T, r webhookTestRequest) int32 {
+	var payload struct {
+		ID    string                    `json:"id"`
+		Event string                    `json:"event"`
+		Data  WebhookBlockConnectedData `json:"data"`
+	}
+	if err := json.Unmarshal(r.body, &payload); err != nil {
+		t.Fatalf("invalid webhook %s: %v", r.body, err)
+	}
+	if payload.ID != r.id || payload.Event != r.event {
+		t.Fatalf("got webhook %s with id %q and event %q in the headers",
+			r.body, r.id, r.event)
+	}
+	return payload.Data.Height
+}
+
+// waitPending waits until the passed dispatcher has the passed number of
+// pending webhooks.
+func waitPending(t *testing.T, d *WebhookDispatcher, n int) {
+	deadline := time.Now().Add(webhookTestTimeout)
+	for d.Pending() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d pending webhooks, want %d", d.Pending(),
+				n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestWebhookDispatcher ensures webhooks are signed and retried with the same
+// id until the endpoint accepts them.
+func TestWebhookDispatcher(t *testing.T) {
+	t.Parallel()
+
+	receiver := newWebhookTestReceiver(http.StatusInternalServerError)
+	defer receiver.server.Close()
+	secret := []byte("secret")
+	c := newWebhookTestClient()
+	d, err := c.NewWebhookDispatcher(&WebhookConfig{
+		Endpoints: []WebhookEndpoint{{
+			URL:    receiver.server.URL,
+			Secret: secret,
+		}},
+		InitialBackoff: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewWebhookDispatcher: %v", err)
+	}
+	defer d.Stop()
+
+	notifyWebhookTestBlock(t, c, 7)
+	requests := receiver.waitRequests(t, 2)
+	waitPending(t, d, 0)
+	if len(requests) != 2 {
+		t.Fatalf("got %d webhooks, want 2", len(requests))
+	}
+	first, retry := requests[0], requests[1]
+	if first.status != http.StatusInternalServerError ||
+		retry.status != http.StatusOK {
+		t.Fatalf("got statuses %d and %d, want 500 and 200",
+			first.status, retry.status)
+	}
+	if first.id == "" || retry.id != first.id {
+		t.Fatalf("got ids %q and %q, want the same id", first.id,
+			retry.id)
+	}
+	for _, r := range requests {
+		want := SignWebhook(r.body, secret)
+		if !hmac.Equal([]byte(r.signature), []byte(want)) {
+			t.Fatalf("got signature %q, want %q", r.signature, want)
+		}
+		if r.event != WebhookBlockConnected {
+			t.Fatalf("got event %q, want %q", r.event,
+				WebhookBlockConnected)
+		}
+		if height := webhookTestHeight(t, r); height != 7 {
+			t.Fatalf("got height %d, want 7", height)
+		}
+	}
+}
+
+// TestWebhookOutbox ensures the webhooks which were not delivered are delivered
+// by the next dispatcher using the same outbox directory, and that the oldest
+// webhooks are dropped once the outbox is full.
+func TestWebhookOutbox(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "webhook")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The first dispatcher fails to deliver the first webhook and waits
+	// before retrying while more webhooks are added.  Only the newest
+	// webhooks are kept once the outbox is full.
+	receiver := newWebhookTestReceiver(http.StatusInternalServerError)
+	defer receiver.server.Close()
+	config := &WebhookConfig{
+		Endpoints:      []WebhookEndpoint{{URL: receiver.server.URL}},
+		OutboxDir:      dir,
+		MaxOutboxSize:  3,
+		InitialBackoff: time.Hour,
+	}
+	c := newWebhookTestClient()
+	d, err := c.NewWebhookDispatcher(config)
+	if err != nil {
+		t.Fatalf("NewWebhookDispatcher: %v", err)
+	}
+	notifyWebhookTestBlock(t, c, 1)
+	failed := receiver.waitRequests(t, 1)[0]
+	for height := int32(2); height <= 5; height++ {
+		notifyWebhookTestBlock(t, c, height)
+	}
+	waitPending(t, d, 3)
+	d.Stop()
+	if webhookTestHeight(t, failed) != 1 {
+		t.Fatalf("got first webhook %s, want height 1", failed.body)
+	}
+
+	// The second dispatcher delivers the stored webhooks in order.
+	d, err = newWebhookTestClient().NewWebhookDispatcher(config)
+	if err != nil {
+		t.Fatalf("NewWebhookDispatcher: %v", err)
+	}
+	defer d.Stop()
+	requests := receiver.waitRequests(t, 4)
+	waitPending(t, d, 0)
+	if len(requests) != 4 {
+		t.Fatalf("got %d webhooks, want 4", len(requests))
+	}
+	for i, r := range requests[1:] {
+		if height := webhookTestHeight(t, r); height != int32(i+3) {
+			t.Fatalf("webhook %d: got height %d, want %d", i,
+				height, i+3)
+		}
+	}
+
+	// Delivered and dropped webhooks are removed from the outbox.
+	endpointDirs, err := ioutil.ReadDir(dir)
+	if err != nil || len(endpointDirs) != 1 {
+		t.Fatalf("got outbox directories %v (err %v), want one",
+			endpointDirs, err)
+	}
+	files, err := ioutil.ReadDir(dir + "/" + endpointDirs[0].Name())
+	if err != nil || len(files) != 0 {
+		t.Fatalf("got %d webhooks in outbox (err %v), want none",
+			len(files), err)
+	}
+}